@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"document-approval/api/response"
+	"document-approval/middleware"
+	"document-approval/services/apikey"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *apikey.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *apikey.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// @Summary Список API ключей
+// @Description Возвращает API ключи текущего пользователя
+// @Tags auth
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.APIKey}
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, keys)
+}
+
+// @Summary Создать API ключ
+// @Description Выпускает именованный ключ с областями действия read, upload, approve. Значение ключа возвращается только один раз.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param key body object{name=string,scopes=[]string,expires_at=string} true "Параметры ключа"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Выпускать новые ключи можно только из интерактивной сессии
+	if middleware.GetAPIKeyFromContext(r.Context()) != nil {
+		response.Error(w, http.StatusForbidden, "Нельзя создавать ключи с помощью API ключа")
+		return
+	}
+
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	key, plain, err := h.apiKeyService.CreateKey(user.ID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"key":     plain,
+		"api_key": key,
+	})
+}
+
+// @Summary Отозвать API ключ
+// @Tags auth
+// @Produce json
+// @Param id path integer true "ID ключа"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID ключа")
+		return
+	}
+
+	err = h.apiKeyService.RevokeKey(user.ID, id)
+	if errors.Is(err, apikey.ErrKeyNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
@@ -2,22 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"document-approval/api/response"
+	"document-approval/middleware"
+	"document-approval/models"
 	"document-approval/services/approval"
+	"document-approval/services/signature"
 
 	"github.com/gorilla/mux"
 )
 
 type ApprovalHandler struct {
-	approvalService *approval.ApprovalService
+	approvalService  *approval.ApprovalService
+	signatureService *signature.SignatureService
 }
 
-func NewApprovalHandler(approvalService *approval.ApprovalService) *ApprovalHandler {
+func NewApprovalHandler(approvalService *approval.ApprovalService, signatureService *signature.SignatureService) *ApprovalHandler {
 	return &ApprovalHandler{
-		approvalService: approvalService,
+		approvalService:  approvalService,
+		signatureService: signatureService,
 	}
 }
 
@@ -32,10 +39,21 @@ func (h *ApprovalHandler) GetApprovals(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, processes)
 }
 
-// StartApprovalProcess запускает процесс утверждения
+// StartApprovalProcess запускает процесс утверждения. Если утверждающие
+// не указаны, маршрут строится по шаблону типа документа.
 func (h *ApprovalHandler) StartApprovalProcess(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	var req struct {
-		ApproverIds []int64 `json:"approverIds"`
+		ApproverIds  []int64                `json:"approverIds"`
+		Stages       []models.ApprovalStage `json:"stages"`
+		DecisionRule string                 `json:"decision_rule"`
+		Quorum       int                    `json:"quorum"`
+		TemplateID   *int64                 `json:"template_id"`
+		Overrides    models.RouteOverrides  `json:"overrides"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -50,16 +68,142 @@ func (h *ApprovalHandler) StartApprovalProcess(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	err = h.approvalService.StartApprovalProcess(documentId, req.ApproverIds)
+	route := models.ApprovalRoute{
+		DecisionRule: req.DecisionRule,
+		Quorum:       req.Quorum,
+		Stages:       req.Stages,
+	}
+
+	switch {
+	case len(route.Stages) > 0:
+	case len(req.ApproverIds) > 0:
+		// Простой список утверждающих - это маршрут из одного параллельного этапа
+		route.Stages = []models.ApprovalStage{{ApproverIDs: req.ApproverIds}}
+	default:
+		route, err = h.approvalService.BuildRouteFromTemplate(documentId, req.TemplateID, req.Overrides)
+		if errors.Is(err, approval.ErrTemplateNotFound) {
+			response.Error(w, http.StatusBadRequest, "Не указаны утверждающие, и для типа документа нет шаблона маршрута")
+			return
+		}
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	processID, err := h.approvalService.StartApprovalProcess(documentId, user.ID, route)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+		writeApprovalError(w, err)
+		return
+	}
+
+	response.Success(w, map[string]int64{"process_id": processID})
+}
+
+// @Summary Отменить согласование
+// @Description Отменяет идущее согласование. Доступно инициатору и администратору, документ возвращается в черновик.
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID процесса"
+// @Param body body object{reason=string} true "Причина отмены"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/cancel [post]
+func (h *ApprovalHandler) CancelProcess(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	isAdmin := middleware.HasPermission(user, middleware.PermApprovalManage)
+	err = h.approvalService.CancelProcess(processID, user.ID, isAdmin, req.Reason)
+	if err != nil {
+		writeApprovalError(w, err)
 		return
 	}
 
 	response.Success(w, nil)
 }
 
-// ApproveDocument обрабатывает утверждение или отклонение документа
+// @Summary Повторно отправить на согласование
+// @Description Запускает новый круг согласования по маршруту отклоненного или отмененного процесса
+// @Tags approvals
+// @Produce json
+// @Param id path integer true "ID процесса"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/resubmit [post]
+func (h *ApprovalHandler) ResubmitProcess(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	isAdmin := middleware.HasPermission(user, middleware.PermApprovalManage)
+	newID, err := h.approvalService.ResubmitProcess(processID, user.ID, isAdmin)
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+
+	response.Success(w, map[string]int64{"process_id": newID})
+}
+
+// @Summary Круги согласования документа
+// @Description Все процессы согласования документа по порядку, включая решения прошлых кругов
+// @Tags approvals
+// @Produce json
+// @Param id path integer true "ID документа"
+// @Success 200 {object} response.Response{data=[]models.ApprovalProcess}
+// @Security BearerAuth
+// @Router /documents/{id}/approvals [get]
+func (h *ApprovalHandler) GetDocumentProcesses(w http.ResponseWriter, r *http.Request) {
+	documentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID документа")
+		return
+	}
+
+	processes, err := h.approvalService.GetDocumentProcesses(documentID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, processes)
+}
+
+// ApproveDocument обрабатывает утверждение или отклонение документа.
+// Решение можно подписать: signature - отсоединенная подпись CMS в base64
+// над текстом из /approvals/{id}/signing-payload.
 func (h *ApprovalHandler) ApproveDocument(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	processID, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -68,10 +212,15 @@ func (h *ApprovalHandler) ApproveDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	var req struct {
-		Approved bool   `json:"approved"`
-		Comment  string `json:"comment"`
-		UserID   int64  `json:"user_id"`
+		Approved  bool   `json:"approved"`
+		Comment   string `json:"comment"`
+		Signature []byte `json:"signature"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -79,15 +228,19 @@ func (h *ApprovalHandler) ApproveDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Проверяем, что пользователь указан
-	if req.UserID == 0 {
-		response.Error(w, http.StatusBadRequest, "Не указан пользователь")
-		return
+	var sig *models.DecisionSignature
+	if len(req.Signature) > 0 {
+		sig, err = h.signatureService.VerifyDecision(processID, user.ID, req.Approved, req.Signature)
+		if err != nil {
+			writeSignatureError(w, err)
+			return
+		}
 	}
 
-	err = h.approvalService.ApproveDocument(processID, req.UserID, req.Approved, req.Comment)
+	// Утверждающий всегда берется из токена, а не из тела запроса
+	err = h.approvalService.ApproveDocument(processID, user.ID, req.Approved, req.Comment, sig)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+		writeApprovalError(w, err)
 		return
 	}
 
@@ -96,14 +249,17 @@ func (h *ApprovalHandler) ApproveDocument(w http.ResponseWriter, r *http.Request
 
 // GetUserApprovals возвращает список согласований для пользователя
 func (h *ApprovalHandler) GetUserApprovals(w http.ResponseWriter, r *http.Request) {
-	// Для демонстрации используем фиктивный ID пользователя
-	userID := int64(1)
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	status := r.URL.Query().Get("status")
 	if status == "" {
 		status = "pending"
 	}
 
-	processes, err := h.approvalService.GetUserApprovals(userID, status)
+	processes, err := h.approvalService.GetUserApprovals(user.ID, status)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -122,6 +278,10 @@ func (h *ApprovalHandler) GetApprovalDetails(w http.ResponseWriter, r *http.Requ
 	}
 
 	process, err := h.approvalService.GetApprovalDetails(processID)
+	if errors.Is(err, approval.ErrProcessNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -129,3 +289,202 @@ func (h *ApprovalHandler) GetApprovalDetails(w http.ResponseWriter, r *http.Requ
 
 	response.Success(w, process)
 }
+
+// @Summary История процесса согласования
+// @Description Напоминания, эскалации и другие события процесса
+// @Tags approvals
+// @Produce json
+// @Param id path integer true "ID процесса"
+// @Success 200 {object} response.Response{data=[]models.ApprovalHistoryEntry}
+// @Security BearerAuth
+// @Router /approvals/{id}/history [get]
+func (h *ApprovalHandler) GetProcessHistory(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	history, err := h.approvalService.GetProcessHistory(processID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, history)
+}
+
+// @Summary Мои замещения
+// @Description Возвращает замещения, где текущий пользователь замещаемый или заместитель
+// @Tags approvals
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.Delegation}
+// @Security BearerAuth
+// @Router /delegations [get]
+func (h *ApprovalHandler) GetDelegations(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	delegations, err := h.approvalService.GetUserDelegations(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, delegations)
+}
+
+// @Summary Назначить заместителя
+// @Description Заместитель сможет принимать решения за текущего пользователя в указанный период
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param delegation body object{substitute_id=integer,starts_at=string,ends_at=string,reason=string} true "Замещение"
+// @Success 200 {object} response.Response{data=models.Delegation}
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /delegations [post]
+func (h *ApprovalHandler) CreateDelegation(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var req struct {
+		SubstituteID int64     `json:"substitute_id"`
+		StartsAt     time.Time `json:"starts_at"`
+		EndsAt       time.Time `json:"ends_at"`
+		Reason       string    `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	delegation := &models.Delegation{
+		DelegatorID:  user.ID,
+		SubstituteID: req.SubstituteID,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		Reason:       req.Reason,
+	}
+
+	if err := h.approvalService.CreateDelegation(delegation); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, delegation)
+}
+
+// @Summary Отменить замещение
+// @Tags approvals
+// @Produce json
+// @Param id path integer true "ID замещения"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /delegations/{id} [delete]
+func (h *ApprovalHandler) RevokeDelegation(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID замещения")
+		return
+	}
+
+	err = h.approvalService.RevokeDelegation(id, user.ID)
+	if errors.Is(err, approval.ErrDelegationNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Переназначить утверждающего
+// @Description Передает ожидающее решение другому пользователю в активном процессе
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID процесса"
+// @Param approverId path integer true "ID записи утверждающего"
+// @Param body body object{user_id=integer} true "Новый утверждающий"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/approvers/{approverId}/reassign [post]
+func (h *ApprovalHandler) ReassignApprover(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	processID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+	approverID, err := strconv.ParseInt(vars["approverId"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID утверждающего")
+		return
+	}
+
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 {
+		response.Error(w, http.StatusBadRequest, "Не указан новый утверждающий")
+		return
+	}
+
+	if err := h.approvalService.ReassignApprover(processID, approverID, req.UserID, user.ID); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// writeApprovalError отвечает кодом, соответствующим ошибке движка согласования.
+// Недопустимая смена статуса - конфликт с текущим состоянием (409).
+func writeApprovalError(w http.ResponseWriter, err error) {
+	var transition *approval.TransitionError
+
+	switch {
+	case errors.As(err, &transition):
+		response.JSON(w, http.StatusConflict, response.Response{
+			Success: false,
+			Error:   err.Error(),
+			Code:    "illegal_transition",
+			Details: map[string]interface{}{
+				"entity": transition.Entity,
+				"from":   transition.From,
+				"to":     transition.To,
+			},
+		})
+	case errors.Is(err, approval.ErrProcessNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, approval.ErrNotAssigned), errors.Is(err, approval.ErrSubstituteVoted),
+		errors.Is(err, approval.ErrCancelForbidden), errors.Is(err, approval.ErrResubmitForbidden):
+		response.Error(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, approval.ErrProcessNotActive), errors.Is(err, approval.ErrProcessActive):
+		response.ErrorWithCode(w, http.StatusConflict, "process_state", err.Error())
+	default:
+		response.Error(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"document-approval/api/response"
+	"document-approval/services/audit"
+)
+
+type AuditHandler struct {
+	auditService *audit.AuditService
+}
+
+func NewAuditHandler(auditService *audit.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// @Summary Журнал аудита
+// @Description Возвращает записи журнала аудита, начиная с последних. Время - в формате RFC 3339.
+// @Tags audit
+// @Produce json
+// @Param entity_type query string false "Тип сущности (documents, users, approvals...)"
+// @Param entity_id query integer false "ID сущности"
+// @Param user_id query integer false "ID пользователя"
+// @Param from query string false "Начало периода"
+// @Param to query string false "Конец периода"
+// @Param limit query integer false "Количество записей (до 500)" default(100)
+// @Param offset query integer false "Смещение"
+// @Success 200 {object} response.Response{data=[]models.AuditEntry}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *AuditHandler) QueryLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := audit.Filter{EntityType: q.Get("entity_type")}
+
+	for name, dst := range map[string]**int64{"entity_id": &filter.EntityID, "user_id": &filter.UserID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "Неверное значение "+name)
+				return
+			}
+			*dst = &id
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "Неверный формат времени "+name)
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				response.Error(w, http.StatusBadRequest, "Неверное значение "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	entries, err := h.auditService.Query(filter)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, entries)
+}
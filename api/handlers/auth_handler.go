@@ -2,21 +2,35 @@ package handlers
 
 import (
 	"document-approval/api/response"
-	"document-approval/services/user"
+	"document-approval/middleware"
+	"document-approval/services/auth"
+	userpkg "document-approval/services/user"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
 type AuthHandler struct {
-	userService *user.UserService
+	userService *userpkg.UserService
+	authService *auth.AuthService
 }
 
-func NewAuthHandler(userService *user.UserService) *AuthHandler {
+func NewAuthHandler(userService *userpkg.UserService, authService *auth.AuthService) *AuthHandler {
 	return &AuthHandler{
 		userService: userService,
+		authService: authService,
 	}
 }
 
+// @Summary Вход по email и паролю
+// @Description Возвращает токен доступа и refresh токен
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body object{email=string,password=string} true "Учетные данные"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email    string `json:"email"`
@@ -29,16 +43,156 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := h.userService.Authenticate(req.Email, req.Password)
-	if err != nil {
+	if errors.Is(err, userpkg.ErrUserDeactivated) {
+		response.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, userpkg.ErrInvalidCredentials) {
 		response.Error(w, http.StatusUnauthorized, err.Error())
 		return
 	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// В реальном приложении здесь нужно генерировать JWT токен
-	token := "test-token"
+	tokens, err := h.authService.IssueTokens(user)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	response.Success(w, map[string]interface{}{
-		"token": token,
-		"user":  user,
+		"token":  tokens.AccessToken,
+		"tokens": tokens,
+		"user":   user,
 	})
 }
+
+// @Summary Обновить токены
+// @Description Обменивает refresh токен на новую пару токенов
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body object{refresh_token=string} true "Refresh токен"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	tokens, err := h.authService.Refresh(req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"token":  tokens.AccessToken,
+		"tokens": tokens,
+	})
+}
+
+// @Summary Выход
+// @Description Отзывает refresh токен и все токены, выпущенные в его цепочке
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body object{refresh_token=string} true "Refresh токен"
+// @Success 200 {object} response.Response
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	if err := h.authService.Revoke(req.RefreshToken); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Текущий пользователь
+// @Description Возвращает пользователя, которому принадлежит токен
+// @Tags auth
+// @Produce json
+// @Success 200 {object} response.Response{data=models.User}
+// @Failure 401 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/me [get]
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	response.Success(w, user)
+}
+
+// @Summary Сменить пароль
+// @Description Меняет пароль текущего пользователя и завершает остальные сессии
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param passwords body object{current_password=string,new_password=string} true "Текущий и новый пароль"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/password [post]
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if middleware.GetAPIKeyFromContext(r.Context()) != nil {
+		response.Error(w, http.StatusForbidden, "Смена пароля недоступна при входе по API ключу")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	err := h.userService.ChangePassword(user.ID, req.CurrentPassword, req.NewPassword)
+	if errors.Is(err, userpkg.ErrInvalidCredentials) {
+		response.Error(w, http.StatusUnauthorized, "Неверный текущий пароль")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.authService.RevokeAllForUser(user.ID); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
@@ -2,25 +2,33 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"document-approval/api/response"
 	"document-approval/config"
 	"document-approval/models"
+	"document-approval/services/approval"
 	"document-approval/services/document"
 	"github.com/gorilla/mux"
 )
 
 type DocumentHandler struct {
 	documentService *document.DocumentService
+	approvalService *approval.ApprovalService
 }
 
-func NewDocumentHandler(documentService *document.DocumentService) *DocumentHandler {
+func NewDocumentHandler(documentService *document.DocumentService, approvalService *approval.ApprovalService) *DocumentHandler {
 	return &DocumentHandler{
 		documentService: documentService,
+		approvalService: approvalService,
 	}
 }
 
@@ -37,6 +45,11 @@ func NewDocumentHandler(documentService *document.DocumentService) *DocumentHand
 // @Security BearerAuth
 // @Router /documents [post]
 func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	// Устанавливаем максимальный размер файла (10MB)
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
 		response.Error(w, http.StatusBadRequest, "Ошибка парсинга формы")
@@ -59,7 +72,7 @@ func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Создаем документ
-	if err := h.documentService.CreateDocument(&doc, file, header.Filename); err != nil {
+	if err := h.documentService.CreateDocument(&doc, file, header.Filename, user.ID); err != nil {
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -73,6 +86,8 @@ func (h *DocumentHandler) CreateDocument(w http.ResponseWriter, r *http.Request)
 // @Accept json
 // @Produce json
 // @Param q query string false "Поисковый запрос"
+// @Param status query string false "Коды статусов через запятую: draft, in_review, approved, rejected"
+// @Failure 400 {object} response.Response
 // @Success 200 {object} response.Response{data=[]models.Document}
 // @Failure 401 {object} response.Response
 // @Security BearerAuth
@@ -91,6 +106,12 @@ func (h *DocumentHandler) SearchDocuments(w http.ResponseWriter, r *http.Request
 		filters["founder"] = founder
 	}
 	if status := r.URL.Query().Get("status"); status != "" {
+		for _, code := range strings.Split(status, ",") {
+			if _, ok := models.NormalizeDocumentStatus(code); !ok {
+				response.Error(w, http.StatusBadRequest, "Неизвестный статус документа: "+code)
+				return
+			}
+		}
 		filters["status"] = status
 	}
 	if docType := r.URL.Query().Get("document_type"); docType != "" {
@@ -113,7 +134,14 @@ func (h *DocumentHandler) SearchDocuments(w http.ResponseWriter, r *http.Request
 	response.Success(w, docs)
 }
 
+// StartApprovalProcess - прежний способ запуска согласования одним этапом.
+// Выполняется тем же движком, что и /documents/{id}/approve.
 func (h *DocumentHandler) StartApprovalProcess(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	var req struct {
 		DocumentID  int64   `json:"document_id"`
 		ApproverIDs []int64 `json:"approver_ids"`
@@ -124,15 +152,28 @@ func (h *DocumentHandler) StartApprovalProcess(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if err := h.documentService.StartApprovalProcess(req.DocumentID, req.ApproverIDs); err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+	route := models.ApprovalRoute{
+		Stages: []models.ApprovalStage{{ApproverIDs: req.ApproverIDs}},
+	}
+
+	processID, err := h.approvalService.StartApprovalProcess(req.DocumentID, user.ID, route)
+	if err != nil {
+		writeApprovalError(w, err)
 		return
 	}
 
-	response.Success(w, nil)
+	response.Success(w, map[string]int64{"process_id": processID})
 }
 
+// ApproveDocument - прежний способ принять решение, ID процесса передается в теле.
+// Выполняется тем же движком, что и /approvals/{id}/approve. Подписанные
+// решения принимаются только через /approvals/{id}/approve.
 func (h *DocumentHandler) ApproveDocument(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	var req struct {
 		ProcessID int64  `json:"process_id"`
 		Approved  bool   `json:"approved"`
@@ -144,8 +185,8 @@ func (h *DocumentHandler) ApproveDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := h.documentService.ApproveDocument(req.ProcessID, 1, req.Approved, req.Comment); err != nil {
-		response.Error(w, http.StatusInternalServerError, err.Error())
+	if err := h.approvalService.ApproveDocument(req.ProcessID, user.ID, req.Approved, req.Comment, nil); err != nil {
+		writeApprovalError(w, err)
 		return
 	}
 
@@ -192,8 +233,15 @@ func (h *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
 // @Param id path integer true "ID документа"
 // @Param document body models.Document true "Данные документа"
 // @Success 200 {object} response.Response{data=models.Document}
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response "Документ на согласовании или утвержден"
 // @Router /documents/{id} [put]
 func (h *DocumentHandler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
@@ -261,8 +309,16 @@ func (h *DocumentHandler) UpdateDocument(w http.ResponseWriter, r *http.Request)
 		Metadata:       req.Metadata,
 	}
 
-	updatedDoc, err := h.documentService.UpdateDocument(doc)
+	updatedDoc, err := h.documentService.UpdateDocument(doc, user.ID)
 	if err != nil {
+		if err.Error() == "документ не найден" {
+			response.Error(w, http.StatusNotFound, "Документ не найден")
+			return
+		}
+		if errors.Is(err, document.ErrDocumentLocked) {
+			response.Error(w, http.StatusConflict, err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -275,3 +331,185 @@ func (h *DocumentHandler) GetDocumentTypes(w http.ResponseWriter, r *http.Reques
 	log.Printf("Доступные типы: %+v", config.DocumentTypes)
 	response.Success(w, config.DocumentTypes)
 }
+
+// GetStatusLabels godoc
+// @Summary Подписи статусов
+// @Description Возвращает подписи кодов статусов документов, процессов и утверждающих
+// @Tags documents
+// @Produce json
+// @Param lang query string false "Язык подписей: ru или en" default(ru)
+// @Success 200 {object} response.Response{data=map[string]map[string]string}
+// @Failure 401 {object} response.Response
+// @Security BearerAuth
+// @Router /statuses [get]
+func (h *DocumentHandler) GetStatusLabels(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, models.StatusLabelsFor(r.URL.Query().Get("lang")))
+}
+
+// ReplaceFile godoc
+// @Summary Загрузить новую версию файла
+// @Description Загружает новый файл документа следующей версией и делает ее текущей. Прежние версии остаются доступными, процессы согласования сохраняют свою версию. Файл меняется только в черновике и после отклонения.
+// @Tags documents
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path integer true "ID документа"
+// @Param file formData file true "Новый файл"
+// @Param comment formData string false "Комментарий к версии"
+// @Success 200 {object} response.Response{data=models.Document}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response "Документ на согласовании или утвержден"
+// @Security BearerAuth
+// @Router /documents/{id}/versions [post]
+// @Router /documents/{id}/file [put]
+func (h *DocumentHandler) ReplaceFile(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID документа")
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		response.Error(w, http.StatusBadRequest, "Ошибка парсинга формы")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Ошибка получения файла")
+		return
+	}
+	defer file.Close()
+
+	doc, err := h.documentService.ReplaceFile(id, file, header.Filename, r.FormValue("comment"), user.ID)
+	if err != nil {
+		if err.Error() == "документ не найден" {
+			response.Error(w, http.StatusNotFound, "Документ не найден")
+			return
+		}
+		if errors.Is(err, document.ErrDocumentLocked) {
+			response.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, doc)
+}
+
+// GetDocumentHistory godoc
+// @Summary История документа
+// @Description Хронология событий документа: создание, правки, замена файла, согласование и смена статусов
+// @Tags documents
+// @Produce json
+// @Param id path integer true "ID документа"
+// @Success 200 {object} response.Response{data=[]models.DocumentEvent}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /documents/{id}/history [get]
+func (h *DocumentHandler) GetDocumentHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID документа")
+		return
+	}
+
+	history, err := h.documentService.GetDocumentHistory(id)
+	if err != nil {
+		if err.Error() == "документ не найден" {
+			response.Error(w, http.StatusNotFound, "Документ не найден")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, history)
+}
+
+// ListVersions godoc
+// @Summary Версии файла документа
+// @Description Возвращает версии файла документа, начиная с последней: автор, комментарий, контрольная сумма SHA-256 и размер
+// @Tags documents
+// @Produce json
+// @Param id path integer true "ID документа"
+// @Success 200 {object} response.Response{data=[]models.DocumentVersion}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /documents/{id}/versions [get]
+func (h *DocumentHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID документа")
+		return
+	}
+
+	versions, err := h.documentService.ListVersions(id)
+	if err != nil {
+		if err.Error() == "документ не найден" {
+			response.Error(w, http.StatusNotFound, "Документ не найден")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, versions)
+}
+
+// DownloadVersion godoc
+// @Summary Скачать версию файла
+// @Description Отдает файл документа указанной версии
+// @Tags documents
+// @Produce application/octet-stream
+// @Param id path integer true "ID документа"
+// @Param version path integer true "Номер версии"
+// @Success 200 {file} binary
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /documents/{id}/versions/{version}/download [get]
+func (h *DocumentHandler) DownloadVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID документа")
+		return
+	}
+	number, err := strconv.Atoi(vars["version"])
+	if err != nil || number <= 0 {
+		response.Error(w, http.StatusBadRequest, "Неверный номер версии")
+		return
+	}
+
+	version, file, err := h.documentService.GetVersionFile(id, number)
+	if err != nil {
+		if errors.Is(err, document.ErrVersionNotFound) {
+			response.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	filename := version.FileName
+	if filename == "" {
+		filename = filepath.Base(version.FilePath)
+	}
+	// Имя файла задал загрузивший, поэтому заголовок собирается с экранированием
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if version.Checksum != nil {
+		w.Header().Set("X-Checksum-SHA256", *version.Checksum)
+	}
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Ошибка отправки файла: %v", err)
+	}
+}
@@ -1,31 +1,160 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
+	"log"
 	"net/http"
 
 	"document-approval/api/response"
+	"document-approval/services/auth"
+	"document-approval/services/esia"
+	userpkg "document-approval/services/user"
 )
 
+// esiaStateCookie привязывает state к браузеру, начавшему вход: callback
+// с чужим state (login CSRF) без этой cookie не принимается
+const (
+	esiaStateCookie     = "esia_state"
+	esiaStateCookiePath = "/api/auth/esia"
+)
+
+type ESIAHandler struct {
+	esiaService *esia.ESIAService
+	userService *userpkg.UserService
+	authService *auth.AuthService
+}
+
+func NewESIAHandler(esiaService *esia.ESIAService, userService *userpkg.UserService, authService *auth.AuthService) *ESIAHandler {
+	return &ESIAHandler{
+		esiaService: esiaService,
+		userService: userService,
+		authService: authService,
+	}
+}
+
+// @Summary Вход через ЕСИА
+// @Description Перенаправляет пользователя на страницу авторизации ЕСИА
+// @Tags auth
+// @Success 302
+// @Failure 500 {object} response.Response
+// @Router /auth/esia/login [get]
+func (h *ESIAHandler) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := h.esiaService.CreateState()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Lax, а не Strict: в callback браузер приходит переходом со стороны ЕСИА
+	http.SetCookie(w, &http.Cookie{
+		Name:     esiaStateCookie,
+		Value:    state,
+		Path:     esiaStateCookiePath,
+		MaxAge:   int(esia.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.esiaService.GetAuthURL(state), http.StatusFound)
+}
+
 // @Summary Callback ЕСИА
 // @Description Обработка callback от ЕСИА после авторизации
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param code query string true "Код авторизации"
+// @Param state query string true "Значение state, выданное при входе"
 // @Success 200 {object} response.Response
 // @Failure 400 {object} response.Response
-// @Router /auth/esia [get]
-func ESIACallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /auth/esia/callback [get]
+func (h *ESIAHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Cookie одноразовая, удаляем ее при любом исходе
+	stateCookie, _ := r.Cookie(esiaStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     esiaStateCookie,
+		Path:     esiaStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if esiaErr := query.Get("error"); esiaErr != "" {
+		response.Error(w, http.StatusUnauthorized, "ЕСИА отклонила авторизацию: "+esiaErr)
+		return
+	}
+
+	code := query.Get("code")
 	if code == "" {
 		response.Error(w, http.StatusBadRequest, "Missing authorization code")
 		return
 	}
 
-	// В реальном приложении здесь будет обработка кода авторизации
-	// и получение токена от ЕСИА
-	response.Success(w, map[string]string{
-		"message": "Authorization successful",
-		"code":    code,
+	state := query.Get("state")
+	if stateCookie == nil || state == "" ||
+		subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(state)) != 1 {
+		response.Error(w, http.StatusBadRequest, esia.ErrInvalidState.Error())
+		return
+	}
+
+	if err := h.esiaService.ConsumeState(state); err != nil {
+		if errors.Is(err, esia.ErrInvalidState) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	accessToken, err := h.esiaService.GetToken(code)
+	if err != nil {
+		log.Printf("Ошибка обмена кода ЕСИА: %v", err)
+		response.Error(w, http.StatusUnauthorized, "Не удалось получить токен ЕСИА")
+		return
+	}
+
+	info, err := h.esiaService.GetUserInfo(accessToken)
+	if err != nil {
+		log.Printf("Ошибка получения данных пользователя ЕСИА: %v", err)
+		response.Error(w, http.StatusUnauthorized, "Не удалось получить данные пользователя ЕСИА")
+		return
+	}
+
+	user, err := h.userService.FindOrCreateByESIA(info.ID, info.FirstName, info.LastName, info.Email)
+	if errors.Is(err, userpkg.ErrUserDeactivated) {
+		response.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, userpkg.ErrESIALinkRequired) {
+		response.ErrorWithCode(w, http.StatusConflict, "esia_link_required", err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tokens, err := h.authService.IssueTokens(user)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]interface{}{
+		"token":  tokens.AccessToken,
+		"tokens": tokens,
+		"user":   user,
 	})
 }
+
+// isHTTPS определяет, пришел ли запрос по HTTPS, в том числе через прокси
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"document-approval/services/esia"
+)
+
+func TestESIACallbackRequiresStateCookie(t *testing.T) {
+	h := NewESIAHandler(esia.NewESIAService(nil, "pomau", "secret", "https://pomau.ru/api/auth/esia/callback"), nil, nil)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{name: "нет cookie", cookie: nil},
+		{name: "state другого браузера", cookie: &http.Cookie{Name: esiaStateCookie, Value: "attacker-state"}},
+		{name: "пустая cookie", cookie: &http.Cookie{Name: esiaStateCookie, Value: ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/esia/callback?code=abc&state=victim-state", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+
+			// Сервисы не настроены: до обращения к БД и ЕСИА дойти не должно
+			h.Callback(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("ожидался 400, получен %d: %s", rec.Code, rec.Body.String())
+			}
+
+			cleared := false
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == esiaStateCookie && c.MaxAge < 0 {
+					cleared = true
+				}
+			}
+			if !cleared {
+				t.Fatal("cookie state не удалена")
+			}
+		})
+	}
+}
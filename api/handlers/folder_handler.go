@@ -139,6 +139,11 @@ func (h *FolderHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} response.Response{data=models.Document}
 // @Router /folders/{id}/files [post]
 func (h *FolderHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
 	vars := mux.Vars(r)
 	folderID, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
@@ -222,15 +227,15 @@ func (h *FolderHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		Founder:        metadata.Founder,
 		FounderINN:     metadata.FounderINN,
 		FilePath:       filepath.Join(folder.Path, header.Filename),
-		Status:         "Черновик",
+		Status:         models.StatusDraft,
 		FolderID:       folderID,
 		DocumentType:   metadata.DocumentType,
 		Metadata:       metadata.Metadata,
 	}
 
 	// Сохраняем файл и создаем документ
-	if err := h.folderService.SaveFile(doc, file); err != nil {
-		print(err.Error())
+	if err := h.folderService.SaveFile(doc, file, user.ID); err != nil {
+		log.Printf("Ошибка сохранения файла в папку %d: %v", folderID, err)
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
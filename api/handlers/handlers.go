@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"document-approval/api/response"
+	"document-approval/middleware"
+	"document-approval/models"
+)
+
+// requireUser возвращает пользователя из контекста запроса.
+// Если пользователя нет, отвечает 401 и возвращает nil.
+func requireUser(w http.ResponseWriter, r *http.Request) *models.User {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		response.Error(w, http.StatusUnauthorized, "Пользователь не авторизован")
+		return nil
+	}
+	return user
+}
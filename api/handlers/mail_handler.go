@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"document-approval/api/response"
+	"document-approval/models"
+	"document-approval/services/mail"
+)
+
+type MailHandler struct {
+	mailService *mail.MailService
+}
+
+func NewMailHandler(mailService *mail.MailService) *MailHandler {
+	return &MailHandler{
+		mailService: mailService,
+	}
+}
+
+// @Summary Настройки уведомлений
+// @Description Возвращает язык писем и события, о которых текущий пользователь получает email
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} response.Response{data=models.NotificationPreferences}
+// @Security BearerAuth
+// @Router /notification-preferences [get]
+func (h *MailHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	prefs, err := h.mailService.GetPreferences(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, prefs)
+}
+
+// @Summary Изменить настройки уведомлений
+// @Description Сохраняет язык писем (ru или en) и события, о которых текущий пользователь получает email
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param preferences body models.NotificationPreferences true "Настройки"
+// @Success 200 {object} response.Response{data=models.NotificationPreferences}
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /notification-preferences [put]
+func (h *MailHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var prefs models.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	if err := h.mailService.UpdatePreferences(user.ID, &prefs); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, prefs)
+}
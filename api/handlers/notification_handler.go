@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"document-approval/api/response"
+	"document-approval/services/notification"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	notificationService *notification.NotificationService
+}
+
+func NewNotificationHandler(notificationService *notification.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// @Summary Уведомления
+// @Description Возвращает уведомления текущего пользователя, начиная с новых, и число непрочитанных
+// @Tags notifications
+// @Produce json
+// @Param unread query boolean false "Только непрочитанные"
+// @Param limit query integer false "Количество записей (до 200)" default(50)
+// @Param offset query integer false "Смещение"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /notifications [get]
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	unreadOnly, _ := strconv.ParseBool(query.Get("unread"))
+
+	limit, offset := 50, 0
+	for name, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				response.Error(w, http.StatusBadRequest, "Неверное значение "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+	if limit == 0 || limit > 200 {
+		limit = 200
+	}
+
+	list, err := h.notificationService.List(user.ID, unreadOnly, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"notifications": list,
+		"unread":        unread,
+	})
+}
+
+// @Summary Число непрочитанных уведомлений
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /notifications/unread-count [get]
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]int{"unread": unread})
+}
+
+// @Summary Отметить уведомление прочитанным
+// @Tags notifications
+// @Param id path integer true "ID уведомления"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /notifications/{id}/read [post]
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID уведомления")
+		return
+	}
+
+	if err := h.notificationService.MarkRead(user.ID, id); err != nil {
+		if errors.Is(err, notification.ErrNotFound) {
+			response.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Отметить все уведомления прочитанными
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /notifications/read-all [post]
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	updated, err := h.notificationService.MarkAllRead(user.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]int64{"updated": updated})
+}
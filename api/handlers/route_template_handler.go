@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"document-approval/api/response"
+	"document-approval/models"
+	"document-approval/services/approval"
+
+	"github.com/gorilla/mux"
+)
+
+type RouteTemplateHandler struct {
+	approvalService *approval.ApprovalService
+}
+
+func NewRouteTemplateHandler(approvalService *approval.ApprovalService) *RouteTemplateHandler {
+	return &RouteTemplateHandler{
+		approvalService: approvalService,
+	}
+}
+
+// @Summary Шаблоны маршрутов
+// @Description Возвращает действующие шаблоны маршрутов согласования в текущей версии
+// @Tags route-templates
+// @Produce json
+// @Param document_type query string false "Тип документа"
+// @Success 200 {object} response.Response{data=[]models.RouteTemplate}
+// @Security BearerAuth
+// @Router /route-templates [get]
+func (h *RouteTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.approvalService.ListTemplates(r.URL.Query().Get("document_type"))
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, templates)
+}
+
+// @Summary Шаблон маршрута
+// @Tags route-templates
+// @Produce json
+// @Param id path integer true "ID шаблона"
+// @Success 200 {object} response.Response{data=models.RouteTemplate}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /route-templates/{id} [get]
+func (h *RouteTemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	template, err := h.approvalService.GetTemplate(id)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	response.Success(w, template)
+}
+
+// @Summary История версий шаблона
+// @Tags route-templates
+// @Produce json
+// @Param id path integer true "ID шаблона"
+// @Success 200 {object} response.Response{data=[]models.RouteTemplate}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /route-templates/{id}/versions [get]
+func (h *RouteTemplateHandler) GetTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := h.approvalService.GetTemplateVersions(id)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	response.Success(w, versions)
+}
+
+// @Summary Создать шаблон маршрута
+// @Description Создает шаблон маршрута для типа документа. На тип документа допускается один действующий шаблон.
+// @Tags route-templates
+// @Accept json
+// @Produce json
+// @Param template body models.RouteTemplate true "Шаблон"
+// @Success 200 {object} response.Response{data=models.RouteTemplate}
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /route-templates [post]
+func (h *RouteTemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var template models.RouteTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+	template.CreatedBy = &user.ID
+
+	if err := h.approvalService.CreateTemplate(&template); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, template)
+}
+
+// @Summary Изменить шаблон маршрута
+// @Description Сохраняет новую версию шаблона. Запущенные процессы остаются на прежней версии.
+// @Tags route-templates
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID шаблона"
+// @Param template body models.RouteTemplate true "Шаблон"
+// @Success 200 {object} response.Response{data=models.RouteTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /route-templates/{id} [put]
+func (h *RouteTemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	var template models.RouteTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+	template.ID = id
+	template.CreatedBy = &user.ID
+
+	if err := h.approvalService.UpdateTemplate(&template); err != nil {
+		if errors.Is(err, approval.ErrTemplateNotFound) {
+			writeTemplateError(w, err)
+			return
+		}
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, template)
+}
+
+// @Summary Удалить шаблон маршрута
+// @Description Отключает шаблон, история версий сохраняется
+// @Tags route-templates
+// @Produce json
+// @Param id path integer true "ID шаблона"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /route-templates/{id} [delete]
+func (h *RouteTemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTemplateID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.approvalService.DeleteTemplate(id); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+func parseTemplateID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID шаблона")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, approval.ErrTemplateNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"document-approval/api/response"
+	"document-approval/services/approval"
+	"document-approval/services/sheet"
+
+	"github.com/gorilla/mux"
+)
+
+type SheetHandler struct {
+	sheetService *sheet.SheetService
+}
+
+func NewSheetHandler(sheetService *sheet.SheetService) *SheetHandler {
+	return &SheetHandler{
+		sheetService: sheetService,
+	}
+}
+
+// @Summary Лист согласования
+// @Description Формирует PDF лист согласования завершенного процесса: реквизиты документа и решения утверждающих. С append=true лист дописывается в конец исходного PDF документа.
+// @Tags approvals
+// @Produce application/pdf
+// @Param id path integer true "ID процесса согласования"
+// @Param append query boolean false "Дописать лист к исходному PDF"
+// @Success 200 {file} binary
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/sheet [get]
+func (h *SheetHandler) GetApprovalSheet(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	withOriginal, _ := strconv.ParseBool(r.URL.Query().Get("append"))
+
+	data, filename, err := h.sheetService.Render(processID, withOriginal)
+	if err != nil {
+		switch {
+		case errors.Is(err, approval.ErrProcessNotFound):
+			response.Error(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, sheet.ErrNotCompleted):
+			response.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, sheet.ErrNotPDF):
+			response.Error(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
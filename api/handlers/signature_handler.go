@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"document-approval/api/response"
+	"document-approval/pkg/cms"
+	"document-approval/services/approval"
+	"document-approval/services/signature"
+
+	"github.com/gorilla/mux"
+)
+
+type SignatureHandler struct {
+	signatureService *signature.SignatureService
+}
+
+func NewSignatureHandler(signatureService *signature.SignatureService) *SignatureHandler {
+	return &SignatureHandler{
+		signatureService: signatureService,
+	}
+}
+
+// @Summary Текст решения для подписи
+// @Description Возвращает текст, который утверждающий подписывает отсоединенной подписью CMS перед отправкой решения. В текст входит SHA-256 файла документа.
+// @Tags approvals
+// @Produce json
+// @Param id path integer true "ID процесса согласования"
+// @Param decision query string true "Решение: approved или rejected"
+// @Success 200 {object} signature.SigningPayload
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/signing-payload [get]
+func (h *SignatureHandler) GetSigningPayload(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	payload, err := h.signatureService.Payload(processID, user.ID, r.URL.Query().Get("decision"))
+	if err != nil {
+		if errors.Is(err, approval.ErrProcessNotFound) {
+			response.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, payload)
+}
+
+// @Summary Комплект подписей процесса
+// @Description Zip с файлом документа, подписями решений (approver_<id>.p7s), подписанными текстами (approver_<id>.txt) и manifest.json.
+// @Tags approvals
+// @Produce application/zip
+// @Param id path integer true "ID процесса согласования"
+// @Success 200 {file} binary
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /approvals/{id}/signatures [get]
+func (h *SignatureHandler) GetSignatureBundle(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID процесса")
+		return
+	}
+
+	data, filename, err := h.signatureService.Bundle(processID)
+	if err != nil {
+		if errors.Is(err, approval.ErrProcessNotFound) {
+			response.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// writeSignatureError отвечает на ошибку проверки подписи решения
+func writeSignatureError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, approval.ErrProcessNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, signature.ErrDisabled):
+		response.ErrorWithCode(w, http.StatusConflict, "signature_disabled", err.Error())
+	case errors.Is(err, cms.ErrUntrusted):
+		response.ErrorWithCode(w, http.StatusUnprocessableEntity, "signature_untrusted", err.Error())
+	case errors.Is(err, signature.ErrInvalid), errors.Is(err, signature.ErrSignerMismatch):
+		response.ErrorWithCode(w, http.StatusUnprocessableEntity, "signature_invalid", err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
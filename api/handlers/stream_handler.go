@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"document-approval/api/response"
+	"document-approval/models"
+	"document-approval/services/stream"
+)
+
+// heartbeatInterval - как часто в простаивающий поток пишется комментарий,
+// чтобы прокси не закрывали соединение
+const heartbeatInterval = 25 * time.Second
+
+type StreamHandler struct {
+	hub *stream.Hub
+}
+
+func NewStreamHandler(hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{
+		hub: hub,
+	}
+}
+
+// @Summary Поток обновлений
+// @Description Server-Sent Events с событиями пользователя: approval.assigned, approval.decided, folder.changed. ID события - позиция в потоке; при переподключении с заголовком Last-Event-ID (или параметром last_event_id) сначала приходят пропущенные события. События фиксируются не строго по порядку ID, поэтому после переподключения часть уже полученных событий может прийти повторно - клиент отсеивает их по ID. Если пропущено слишком много, вместо них приходит stream.reload: клиент перечитывает данные целиком. Первое подключение получает событие ready с текущей позицией.
+// @Tags events
+// @Produce text/event-stream
+// @Param Last-Event-ID header integer false "ID последнего полученного события"
+// @Param last_event_id query integer false "ID последнего полученного события"
+// @Success 200 {string} string "Поток событий"
+// @Security BearerAuth
+// @Router /events/stream [get]
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.Error(w, http.StatusInternalServerError, "Поток событий не поддерживается")
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after int64 = -1
+	if lastID != "" {
+		n, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil || n < 0 {
+			response.Error(w, http.StatusBadRequest, "Неверный Last-Event-ID")
+			return
+		}
+		after = n
+	}
+
+	// Подписываемся до чтения пропущенного, чтобы не потерять события между
+	// ними; события, уже отданные из пропущенного, отсекаются по ID
+	sub := h.hub.Subscribe(user.ID)
+	defer sub.Close()
+
+	var missed []models.StreamEvent
+	var truncated bool
+	var err error
+	if after >= 0 {
+		missed, truncated, err = h.hub.Since(user.ID, after)
+	}
+	if after < 0 || truncated {
+		after, err = h.hub.LastID()
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	replayed := make(map[int64]struct{})
+	switch {
+	case lastID == "":
+		fmt.Fprintf(w, "id: %d\nevent: ready\ndata: {}\n\n", after)
+	case truncated:
+		// Все пропущенное не отдать: клиент перечитывает данные и продолжает
+		// с текущей позиции
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {}\n\n", after, models.StreamReload)
+	default:
+		for _, e := range missed {
+			if err := writeStreamEvent(w, e); err != nil {
+				return
+			}
+			replayed[e.ID] = struct{}{}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.Events():
+			// Канал закрыт - клиент отстал; он переподключится и догонит
+			if !ok {
+				return
+			}
+			// Событие уже отдано из пропущенного. Hub рассылает каждое
+			// событие один раз, так что запись больше не понадобится.
+			if _, ok := replayed[e.ID]; ok {
+				delete(replayed, e.ID)
+				continue
+			}
+			if err := writeStreamEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, e models.StreamEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"document-approval/middleware"
+	"document-approval/models"
+	"document-approval/pkg/sqltest"
+	"document-approval/services/stream"
+)
+
+var streamColumns = []string{"id", "user_id", "event_type", "data", "created_at"}
+
+func streamRow(id int64, userID any) []any {
+	return []any{id, userID, models.StreamApprovalAssigned, []byte(`{"process_id":1}`), time.Now()}
+}
+
+// serveStream открывает поток и сразу закрывает соединение: обработчик
+// успевает отдать только начало потока - пропущенное или stream.reload
+func serveStream(t *testing.T, h *StreamHandler, target, lastEventID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx = context.WithValue(ctx, middleware.UserKey, &models.User{ID: 7})
+
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rec := httptest.NewRecorder()
+	h.Stream(rec, req)
+	return rec
+}
+
+func TestStreamResumesFromLastEventID(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	h := NewStreamHandler(stream.NewHub(db, ""))
+
+	// Событие 9 зафиксировано после 10 и попало в окно повторного чтения
+	mock.ExpectQuery("FROM stream_events WHERE (user_id = $2 OR user_id IS NULL)").
+		WithArgs(int64(10), int64(7), sqltest.Any, sqltest.Any).
+		WillReturnRows(streamColumns, streamRow(9, int64(7)), streamRow(11, nil), streamRow(12, int64(7)))
+
+	rec := serveStream(t, h, "/api/events/stream", "10")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "event: ready") || strings.Contains(body, models.StreamReload) {
+		t.Fatalf("при возобновлении пришло служебное событие:\n%s", body)
+	}
+
+	var ids []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+	}
+	if strings.Join(ids, ",") != "9,11,12" {
+		t.Fatalf("отданы события %v, ожидались 9,11,12:\n%s", ids, body)
+	}
+}
+
+func TestStreamResumeFromQueryParameter(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	h := NewStreamHandler(stream.NewHub(db, ""))
+
+	mock.ExpectQuery("FROM stream_events WHERE (user_id = $2 OR user_id IS NULL)").
+		WithArgs(int64(3), int64(7), sqltest.Any, sqltest.Any).
+		WillReturnRows(streamColumns, streamRow(4, int64(7)))
+
+	rec := serveStream(t, h, "/api/events/stream?last_event_id=3", "")
+
+	if !strings.Contains(rec.Body.String(), "id: 4\nevent: "+models.StreamApprovalAssigned) {
+		t.Fatalf("пропущенное событие не отдано:\n%s", rec.Body.String())
+	}
+}
+
+func TestStreamReloadWhenReplayTruncated(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	h := NewStreamHandler(stream.NewHub(db, ""))
+
+	// Пропущено больше, чем отдается при переподключении: Since читает на
+	// одну строку больше предела, поэтому отвечаем заведомо длинным списком
+	var rows [][]any
+	for id := int64(11); id <= 1011; id++ {
+		rows = append(rows, streamRow(id, int64(7)))
+	}
+	mock.ExpectQuery("FROM stream_events WHERE (user_id = $2 OR user_id IS NULL)").
+		WithArgs(int64(10), int64(7), sqltest.Any, sqltest.Any).
+		WillReturnRows(streamColumns, rows...)
+	mock.ExpectQuery("SELECT COALESCE(MAX(id), 0) FROM stream_events").
+		WillReturnRows([]string{"max"}, []any{int64(1500)})
+
+	rec := serveStream(t, h, "/api/events/stream", "10")
+
+	body := rec.Body.String()
+	want := "id: 1500\nevent: " + models.StreamReload + "\ndata: {}\n\n"
+	if !strings.Contains(body, want) {
+		t.Fatalf("нет stream.reload с текущей позицией:\n%.300s", body)
+	}
+	if strings.Contains(body, "event: "+models.StreamApprovalAssigned) {
+		t.Fatal("вместе со stream.reload отданы пропущенные события")
+	}
+}
+
+func TestStreamFirstConnect(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	h := NewStreamHandler(stream.NewHub(db, ""))
+
+	mock.ExpectQuery("SELECT COALESCE(MAX(id), 0) FROM stream_events").
+		WillReturnRows([]string{"max"}, []any{int64(42)})
+
+	rec := serveStream(t, h, "/api/events/stream", "")
+
+	if !strings.Contains(rec.Body.String(), "id: 42\nevent: ready\n") {
+		t.Fatalf("нет события ready:\n%s", rec.Body.String())
+	}
+}
+
+func TestStreamRejectsBadLastEventID(t *testing.T) {
+	db, _ := sqltest.Open(t)
+	h := NewStreamHandler(stream.NewHub(db, ""))
+
+	rec := serveStream(t, h, "/api/events/stream", "abc")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("код %d, ожидался 400", rec.Code)
+	}
+}
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"document-approval/api/response"
+	"document-approval/models"
+	"document-approval/pkg/password"
+	"document-approval/services/auth"
+	"document-approval/services/user"
+
+	"github.com/gorilla/mux"
+)
+
+type UserHandler struct {
+	userService *user.UserService
+	authService *auth.AuthService
+}
+
+func NewUserHandler(userService *user.UserService, authService *auth.AuthService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+		authService: authService,
+	}
+}
+
+// @Summary Сбросить пароль пользователя
+// @Description Устанавливает новый пароль (или генерирует временный) и завершает сессии пользователя
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Param password body object{new_password=string} false "Новый пароль"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/password [post]
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		NewPassword string `json:"new_password"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+			return
+		}
+	}
+
+	// Если пароль не передан, генерируем временный и возвращаем его администратору
+	var err error
+	generated := req.NewPassword == ""
+	if generated {
+		req.NewPassword, err = password.Generate()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := h.userService.SetPassword(id, req.NewPassword); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := h.authService.RevokeAllForUser(id); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := map[string]interface{}{}
+	if generated {
+		result["temporary_password"] = req.NewPassword
+	}
+
+	response.Success(w, result)
+}
+
+// @Summary Список пользователей
+// @Description Поиск пользователей по имени, фамилии или email
+// @Tags users
+// @Produce json
+// @Param q query string false "Строка поиска"
+// @Param role query string false "Роль"
+// @Param include_inactive query bool false "Включать отключенных"
+// @Success 200 {object} response.Response{data=[]models.User}
+// @Security BearerAuth
+// @Router /users [get]
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := user.UserFilter{
+		Query:           query.Get("q"),
+		Role:            models.Role(query.Get("role")),
+		IncludeInactive: query.Get("include_inactive") == "true",
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		response.Error(w, http.StatusBadRequest, "Неизвестная роль")
+		return
+	}
+
+	users, err := h.userService.ListUsers(filter)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, users)
+}
+
+// @Summary Кандидаты в утверждающие
+// @Description Активные пользователи с ролью утверждающего
+// @Tags users
+// @Produce json
+// @Param q query string false "Строка поиска"
+// @Success 200 {object} response.Response{data=[]models.User}
+// @Security BearerAuth
+// @Router /users/approvers [get]
+func (h *UserHandler) ListApprovers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.userService.ListUsers(user.UserFilter{
+		Query: r.URL.Query().Get("q"),
+		Role:  models.RoleApprover,
+	})
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, users)
+}
+
+// @Summary Получить пользователя
+// @Tags users
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Success 200 {object} response.Response{data=models.User}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	u, err := h.userService.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	u.Roles, err = h.userService.GetUserRoles(id)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, u)
+}
+
+// @Summary Создать пользователя
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body object{first_name=string,last_name=string,email=string,position=string,password=string,roles=[]string} true "Данные пользователя"
+// @Success 200 {object} response.Response{data=models.User}
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /users [post]
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FirstName string        `json:"first_name"`
+		LastName  string        `json:"last_name"`
+		Email     string        `json:"email"`
+		Position  string        `json:"position"`
+		Password  string        `json:"password"`
+		Roles     []models.Role `json:"roles"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	if len(req.Roles) == 0 {
+		req.Roles = []models.Role{models.RoleEmployee}
+	}
+
+	u := &models.User{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+		Position:  req.Position,
+	}
+
+	if err := h.userService.CreateUser(u, req.Password, req.Roles); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, u)
+}
+
+// @Summary Изменить пользователя
+// @Description Меняет только переданные поля. manager_id: null снимает руководителя.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Param user body object{first_name=string,last_name=string,email=string,position=string,manager_id=integer} true "Изменяемые поля"
+// @Success 200 {object} response.Response{data=models.User}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id} [patch]
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		FirstName *string         `json:"first_name"`
+		LastName  *string         `json:"last_name"`
+		Email     *string         `json:"email"`
+		Position  *string         `json:"position"`
+		ManagerID json.RawMessage `json:"manager_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	upd := user.UserUpdate{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+		Position:  req.Position,
+	}
+
+	// Отсутствующий manager_id не меняет руководителя, null - снимает
+	if req.ManagerID != nil {
+		upd.SetManager = true
+		if err := json.Unmarshal(req.ManagerID, &upd.ManagerID); err != nil {
+			response.Error(w, http.StatusBadRequest, "Неверный manager_id")
+			return
+		}
+	}
+
+	u, err := h.userService.UpdateUser(id, upd)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	response.Success(w, u)
+}
+
+// @Summary Отключить пользователя
+// @Description Блокирует вход и скрывает пользователя из списка утверждающих
+// @Tags users
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/deactivate [post]
+func (h *UserHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if current := requireUser(w, r); current == nil {
+		return
+	} else if current.ID == id {
+		response.Error(w, http.StatusBadRequest, "Нельзя отключить собственную учетную запись")
+		return
+	}
+
+	if err := h.userService.SetActive(id, false); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := h.authService.RevokeAllForUser(id); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Включить пользователя
+// @Tags users
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/activate [post]
+func (h *UserHandler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.SetActive(id, true); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Привязать учетную запись ЕСИА
+// @Description Подтверждает вход через ЕСИА для существующего пользователя. Пустой esia_id снимает привязку.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Param esia body object{esia_id=string} true "Идентификатор пользователя в ЕСИА"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/esia [put]
+func (h *UserHandler) LinkESIA(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ESIAID string `json:"esia_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	if err := h.userService.LinkESIA(id, strings.TrimSpace(req.ESIAID)); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Назначить роль
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Param role body object{role=string} true "Роль"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/roles [post]
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role models.Role `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+	if !req.Role.Valid() {
+		response.Error(w, http.StatusBadRequest, "Неизвестная роль")
+		return
+	}
+
+	if _, err := h.userService.GetUserByID(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := h.userService.AssignRole(id, req.Role); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Снять роль
+// @Tags users
+// @Produce json
+// @Param id path integer true "ID пользователя"
+// @Param role path string true "Роль"
+// @Success 200 {object} response.Response
+// @Security BearerAuth
+// @Router /users/{id}/roles/{role} [delete]
+func (h *UserHandler) RemoveRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	role := models.Role(mux.Vars(r)["role"])
+	if !role.Valid() {
+		response.Error(w, http.StatusBadRequest, "Неизвестная роль")
+		return
+	}
+
+	if err := h.userService.RemoveRole(id, role); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+func parseUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID пользователя")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, user.ErrUserNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Error(w, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"document-approval/api/response"
+	"document-approval/models"
+	"document-approval/services/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	webhookService *webhook.WebhookService
+}
+
+func NewWebhookHandler(webhookService *webhook.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// webhookRequest - тело создания и изменения подписки. Без is_active
+// подписка включена.
+type webhookRequest struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+func (req webhookRequest) webhook() *models.Webhook {
+	w := &models.Webhook{
+		URL:         req.URL,
+		Secret:      req.Secret,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		w.IsActive = *req.IsActive
+	}
+	return w
+}
+
+// @Summary Список вебхуков
+// @Description Возвращает подписки на события без секретов
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.Webhook}
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.List()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"webhooks": webhooks,
+		"events":   models.WebhookEvents,
+	})
+}
+
+// @Summary Создать вебхук
+// @Description Регистрирует подписку на события. Если секрет не указан, он генерируется. Секрет возвращается только в этом ответе.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body webhookRequest true "Подписка"
+// @Success 200 {object} response.Response{data=models.Webhook}
+// @Failure 400 {object} response.Response
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	hook := req.webhook()
+	if err := h.webhookService.Create(hook, user.ID); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, hook)
+}
+
+// @Summary Вебхук
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "ID вебхука"
+// @Success 200 {object} response.Response{data=models.Webhook}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID вебхука")
+		return
+	}
+
+	hook, err := h.webhookService.Get(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.Success(w, hook)
+}
+
+// @Summary Изменить вебхук
+// @Description Меняет адрес, события и активность подписки. Непустой секрет заменяет прежний.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path integer true "ID вебхука"
+// @Param webhook body webhookRequest true "Подписка"
+// @Success 200 {object} response.Response{data=models.Webhook}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID вебхука")
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный формат запроса")
+		return
+	}
+
+	hook := req.webhook()
+	hook.ID = id
+	if err := h.webhookService.Update(hook); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.Success(w, hook)
+}
+
+// @Summary Удалить вебхук
+// @Description Удаляет подписку вместе с журналом доставок
+// @Tags webhooks
+// @Param id path integer true "ID вебхука"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID вебхука")
+		return
+	}
+
+	if err := h.webhookService.Delete(id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.Success(w, nil)
+}
+
+// @Summary Журнал доставок вебхука
+// @Description Возвращает доставки подписки, начиная с последних
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "ID вебхука"
+// @Param limit query integer false "Количество записей (до 500)" default(50)
+// @Param offset query integer false "Смещение"
+// @Success 200 {object} response.Response{data=[]models.WebhookDelivery}
+// @Failure 404 {object} response.Response
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID вебхука")
+		return
+	}
+
+	limit, offset := 50, 0
+	for name, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				response.Error(w, http.StatusBadRequest, "Неверное значение "+name)
+				return
+			}
+			*dst = n
+		}
+	}
+	if limit == 0 || limit > 500 {
+		limit = 500
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(id, limit, offset)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.Success(w, deliveries)
+}
+
+// @Summary Повторить доставку
+// @Description Ставит событие доставки в очередь повторно отдельной доставкой с тем же ID события
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "ID доставки"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Security BearerAuth
+// @Router /webhook-deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Неверный ID доставки")
+		return
+	}
+
+	deliveryID, err := h.webhookService.Redeliver(id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.Success(w, map[string]int64{"delivery_id": deliveryID})
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, webhook.ErrInactive):
+		response.Error(w, http.StatusConflict, err.Error())
+	default:
+		response.Error(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -9,6 +9,8 @@ type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 func JSON(w http.ResponseWriter, status int, data interface{}) {
@@ -27,6 +29,14 @@ func Error(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+func ErrorWithCode(w http.ResponseWriter, status int, code, message string) {
+	JSON(w, status, Response{
+		Success: false,
+		Error:   message,
+		Code:    code,
+	})
+}
+
 func Success(w http.ResponseWriter, data interface{}) {
 	JSON(w, http.StatusOK, Response{
 		Success: true,
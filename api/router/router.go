@@ -1,13 +1,25 @@
 package router
 
 import (
+	"net/http"
+
 	"document-approval/api/handlers"
 	_ "document-approval/docs"
 	"document-approval/middleware"
+	"document-approval/services/apikey"
 	"document-approval/services/approval"
+	"document-approval/services/audit"
+	"document-approval/services/auth"
 	"document-approval/services/document"
+	"document-approval/services/esia"
 	"document-approval/services/folder"
+	"document-approval/services/mail"
+	"document-approval/services/notification"
+	"document-approval/services/sheet"
+	"document-approval/services/signature"
+	"document-approval/services/stream"
 	"document-approval/services/user"
+	"document-approval/services/webhook"
 
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -18,6 +30,45 @@ func NewRouter(
 	userService *user.UserService,
 	approvalService *approval.ApprovalService,
 	folderService *folder.FolderService,
+	authService *auth.AuthService,
+	esiaService *esia.ESIAService,
+	apiKeyService *apikey.APIKeyService,
+	auditService *audit.AuditService,
+	sheetService *sheet.SheetService,
+	signatureService *signature.SignatureService,
+	mailService *mail.MailService,
+	webhookService *webhook.WebhookService,
+	streamHub *stream.Hub,
+	notificationService *notification.NotificationService,
+) *mux.Router {
+	return newRouter(
+		middleware.AuthMiddleware(authService, userService, apiKeyService),
+		middleware.Audit(auditService),
+		documentService, userService, approvalService, folderService, authService, esiaService,
+		apiKeyService, sheetService, signatureService, mailService, webhookService, streamHub,
+		notificationService, auditService,
+	)
+}
+
+// newRouter регистрирует маршруты. Аутентификация и аудит передаются
+// готовыми middleware, чтобы проверку прав можно было тестировать без БД.
+func newRouter(
+	authMiddleware func(http.Handler) http.Handler,
+	auditMiddleware func(http.Handler) http.Handler,
+	documentService *document.DocumentService,
+	userService *user.UserService,
+	approvalService *approval.ApprovalService,
+	folderService *folder.FolderService,
+	authService *auth.AuthService,
+	esiaService *esia.ESIAService,
+	apiKeyService *apikey.APIKeyService,
+	sheetService *sheet.SheetService,
+	signatureService *signature.SignatureService,
+	mailService *mail.MailService,
+	webhookService *webhook.WebhookService,
+	streamHub *stream.Hub,
+	notificationService *notification.NotificationService,
+	auditService *audit.AuditService,
 ) *mux.Router {
 	r := mux.NewRouter()
 
@@ -31,35 +82,141 @@ func NewRouter(
 	))
 
 	// Хендлеры
-	docHandler := handlers.NewDocumentHandler(documentService)
-	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	docHandler := handlers.NewDocumentHandler(documentService, approvalService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService, signatureService)
 	folderHandler := handlers.NewFolderHandler(folderService)
+	authHandler := handlers.NewAuthHandler(userService, authService)
+	userHandler := handlers.NewUserHandler(userService, authService)
+	esiaHandler := handlers.NewESIAHandler(esiaService, userService, authService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	routeTemplateHandler := handlers.NewRouteTemplateHandler(approvalService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	sheetHandler := handlers.NewSheetHandler(sheetService)
+	signatureHandler := handlers.NewSignatureHandler(signatureService)
+	mailHandler := handlers.NewMailHandler(mailService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	streamHandler := handlers.NewStreamHandler(streamHub)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
 
 	api := r.PathPrefix("/api").Subrouter()
 
+	// Изменяющие запросы, включая вход и выход, попадают в журнал аудита
+	api.Use(auditMiddleware)
+
+	// Аутентификация
+	api.HandleFunc("/auth/login", authHandler.Login).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/esia/login", esiaHandler.Login).Methods("GET", "OPTIONS")
+	api.HandleFunc("/auth/esia/callback", esiaHandler.Callback).Methods("GET", "OPTIONS")
+
+	// Все остальные маршруты требуют токен
+	protected := api.NewRoute().Subrouter()
+	protected.Use(authMiddleware)
+
+	// allow оборачивает хендлер проверкой права доступа
+	allow := func(perm middleware.Permission, h http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(perm)(h)
+	}
+
+	protected.HandleFunc("/auth/me", authHandler.Me).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/auth/password", authHandler.ChangePassword).Methods("POST", "OPTIONS")
+
+	// API ключи
+	protected.HandleFunc("/api-keys", apiKeyHandler.ListKeys).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/api-keys", apiKeyHandler.CreateKey).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/api-keys/{id}", apiKeyHandler.RevokeKey).Methods("DELETE", "OPTIONS")
+
+	// Пользователи
+	protected.HandleFunc("/users/approvers", userHandler.ListApprovers).Methods("GET", "OPTIONS")
+	protected.Handle("/users", allow(middleware.PermUsersManage, userHandler.ListUsers)).Methods("GET", "OPTIONS")
+	protected.Handle("/users", allow(middleware.PermUsersManage, userHandler.CreateUser)).Methods("POST", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}", allow(middleware.PermUsersManage, userHandler.GetUser)).Methods("GET", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}", allow(middleware.PermUsersManage, userHandler.UpdateUser)).Methods("PATCH", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/deactivate", allow(middleware.PermUsersManage, userHandler.DeactivateUser)).Methods("POST", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/activate", allow(middleware.PermUsersManage, userHandler.ActivateUser)).Methods("POST", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/roles", allow(middleware.PermUsersManage, userHandler.AssignRole)).Methods("POST", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/roles/{role}", allow(middleware.PermUsersManage, userHandler.RemoveRole)).Methods("DELETE", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/password", allow(middleware.PermUsersManage, userHandler.ResetPassword)).Methods("POST", "OPTIONS")
+	protected.Handle("/users/{id:[0-9]+}/esia", allow(middleware.PermUsersManage, userHandler.LinkESIA)).Methods("PUT", "OPTIONS")
+
 	// Папки
-	api.HandleFunc("/folders/tree", folderHandler.GetFolderTree).Methods("GET", "OPTIONS")
-	api.HandleFunc("/folders", folderHandler.CreateFolder).Methods("POST", "OPTIONS")
-	api.HandleFunc("/folders/{id}", folderHandler.RenameFolder).Methods("PUT", "OPTIONS")
-	api.HandleFunc("/folders/{id}", folderHandler.DeleteFolder).Methods("DELETE", "OPTIONS")
-	api.HandleFunc("/folders/{id}/files", folderHandler.UploadFile).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/folders/tree", folderHandler.GetFolderTree).Methods("GET", "OPTIONS")
+	protected.Handle("/folders", allow(middleware.PermFolderCreate, folderHandler.CreateFolder)).Methods("POST", "OPTIONS")
+	protected.Handle("/folders/{id}", allow(middleware.PermFolderEdit, folderHandler.RenameFolder)).Methods("PUT", "OPTIONS")
+	protected.Handle("/folders/{id}", allow(middleware.PermFolderDelete, folderHandler.DeleteFolder)).Methods("DELETE", "OPTIONS")
+	protected.Handle("/folders/{id}/files", allow(middleware.PermDocumentCreate, folderHandler.UploadFile)).Methods("POST", "OPTIONS")
 
 	// Файлы
-	api.HandleFunc("/files/{id}/download", folderHandler.DownloadFile).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/files/{id}/download", folderHandler.DownloadFile).Methods("GET", "OPTIONS")
 
 	// Документы
-	api.HandleFunc("/documents/types", docHandler.GetDocumentTypes).Methods("GET", "OPTIONS")
-	api.HandleFunc("/documents/search", docHandler.SearchDocuments).Methods("GET", "OPTIONS")
-	api.HandleFunc("/documents/approve/start", docHandler.StartApprovalProcess).Methods("POST", "OPTIONS")
-	api.HandleFunc("/documents/approve", docHandler.ApproveDocument).Methods("POST", "OPTIONS")
-	api.HandleFunc("/documents/{id}", docHandler.GetDocument).Methods("GET", "OPTIONS")
-	api.HandleFunc("/documents/{id}", docHandler.UpdateDocument).Methods("PUT", "OPTIONS")
-	api.HandleFunc("/documents", docHandler.CreateDocument).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/documents/types", docHandler.GetDocumentTypes).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/documents/search", docHandler.SearchDocuments).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/statuses", docHandler.GetStatusLabels).Methods("GET", "OPTIONS")
+	protected.Handle("/documents/approve/start", allow(middleware.PermApprovalStart, docHandler.StartApprovalProcess)).Methods("POST", "OPTIONS")
+	protected.Handle("/documents/approve", allow(middleware.PermApprovalDecide, docHandler.ApproveDocument)).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/documents/{id}", docHandler.GetDocument).Methods("GET", "OPTIONS")
+	protected.Handle("/documents/{id}", allow(middleware.PermDocumentEdit, docHandler.UpdateDocument)).Methods("PUT", "OPTIONS")
+	protected.Handle("/documents/{id}/file", allow(middleware.PermDocumentEdit, docHandler.ReplaceFile)).Methods("PUT", "OPTIONS")
+	protected.HandleFunc("/documents/{id}/versions", docHandler.ListVersions).Methods("GET", "OPTIONS")
+	protected.Handle("/documents/{id}/versions", allow(middleware.PermDocumentEdit, docHandler.ReplaceFile)).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/documents/{id}/versions/{version:[0-9]+}/download", docHandler.DownloadVersion).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/documents/{id}/history", docHandler.GetDocumentHistory).Methods("GET", "OPTIONS")
+	protected.Handle("/documents", allow(middleware.PermDocumentCreate, docHandler.CreateDocument)).Methods("POST", "OPTIONS")
 
 	// Согласования
-	api.HandleFunc("/approvals", approvalHandler.GetApprovals).Methods("GET", "OPTIONS")
-	api.HandleFunc("/documents/{id}/approve", approvalHandler.StartApprovalProcess).Methods("POST", "OPTIONS")
-	api.HandleFunc("/approvals/{id}/approve", approvalHandler.ApproveDocument).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/approvals", approvalHandler.GetApprovals).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/approvals/my", approvalHandler.GetUserApprovals).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/approvals/{id}", approvalHandler.GetApprovalDetails).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/approvals/{id}/history", approvalHandler.GetProcessHistory).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/approvals/{id}/sheet", sheetHandler.GetApprovalSheet).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/approvals/{id}/signatures", signatureHandler.GetSignatureBundle).Methods("GET", "OPTIONS")
+	protected.Handle("/approvals/{id}/signing-payload", allow(middleware.PermApprovalDecide, signatureHandler.GetSigningPayload)).Methods("GET", "OPTIONS")
+	protected.Handle("/documents/{id}/approve", allow(middleware.PermApprovalStart, approvalHandler.StartApprovalProcess)).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/documents/{id}/approvals", approvalHandler.GetDocumentProcesses).Methods("GET", "OPTIONS")
+	protected.Handle("/approvals/{id}/cancel", allow(middleware.PermApprovalStart, approvalHandler.CancelProcess)).Methods("POST", "OPTIONS")
+	protected.Handle("/approvals/{id}/resubmit", allow(middleware.PermApprovalStart, approvalHandler.ResubmitProcess)).Methods("POST", "OPTIONS")
+	protected.Handle("/approvals/{id}/approve", allow(middleware.PermApprovalDecide, approvalHandler.ApproveDocument)).Methods("POST", "OPTIONS")
+	protected.Handle("/approvals/{id}/approvers/{approverId}/reassign", allow(middleware.PermApprovalManage, approvalHandler.ReassignApprover)).Methods("POST", "OPTIONS")
+
+	// Шаблоны маршрутов
+	protected.HandleFunc("/route-templates", routeTemplateHandler.ListTemplates).Methods("GET", "OPTIONS")
+	protected.Handle("/route-templates", allow(middleware.PermApprovalManage, routeTemplateHandler.CreateTemplate)).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/route-templates/{id}", routeTemplateHandler.GetTemplate).Methods("GET", "OPTIONS")
+	protected.Handle("/route-templates/{id}", allow(middleware.PermApprovalManage, routeTemplateHandler.UpdateTemplate)).Methods("PUT", "OPTIONS")
+	protected.Handle("/route-templates/{id}", allow(middleware.PermApprovalManage, routeTemplateHandler.DeleteTemplate)).Methods("DELETE", "OPTIONS")
+	protected.HandleFunc("/route-templates/{id}/versions", routeTemplateHandler.GetTemplateVersions).Methods("GET", "OPTIONS")
+
+	// Журнал аудита
+	protected.Handle("/audit", allow(middleware.PermAuditView, auditHandler.QueryLog)).Methods("GET", "OPTIONS")
+
+	// Поток обновлений
+	protected.HandleFunc("/events/stream", streamHandler.Stream).Methods("GET", "OPTIONS")
+
+	// Уведомления в приложении
+	protected.HandleFunc("/notifications", notificationHandler.ListNotifications).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/notifications/unread-count", notificationHandler.UnreadCount).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/notifications/read-all", notificationHandler.MarkAllRead).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/notifications/{id:[0-9]+}/read", notificationHandler.MarkRead).Methods("POST", "OPTIONS")
+
+	// Настройки уведомлений
+	protected.HandleFunc("/notification-preferences", mailHandler.GetPreferences).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/notification-preferences", mailHandler.UpdatePreferences).Methods("PUT", "OPTIONS")
+
+	// Вебхуки
+	protected.Handle("/webhooks", allow(middleware.PermWebhooksManage, webhookHandler.ListWebhooks)).Methods("GET", "OPTIONS")
+	protected.Handle("/webhooks", allow(middleware.PermWebhooksManage, webhookHandler.CreateWebhook)).Methods("POST", "OPTIONS")
+	protected.Handle("/webhooks/{id}", allow(middleware.PermWebhooksManage, webhookHandler.GetWebhook)).Methods("GET", "OPTIONS")
+	protected.Handle("/webhooks/{id}", allow(middleware.PermWebhooksManage, webhookHandler.UpdateWebhook)).Methods("PUT", "OPTIONS")
+	protected.Handle("/webhooks/{id}", allow(middleware.PermWebhooksManage, webhookHandler.DeleteWebhook)).Methods("DELETE", "OPTIONS")
+	protected.Handle("/webhooks/{id}/deliveries", allow(middleware.PermWebhooksManage, webhookHandler.ListDeliveries)).Methods("GET", "OPTIONS")
+	protected.Handle("/webhook-deliveries/{id}/redeliver", allow(middleware.PermWebhooksManage, webhookHandler.Redeliver)).Methods("POST", "OPTIONS")
+
+	// Замещения
+	protected.HandleFunc("/delegations", approvalHandler.GetDelegations).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/delegations", approvalHandler.CreateDelegation).Methods("POST", "OPTIONS")
+	protected.HandleFunc("/delegations/{id}", approvalHandler.RevokeDelegation).Methods("DELETE", "OPTIONS")
 
 	return r
 }
@@ -0,0 +1,230 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"document-approval/middleware"
+	"document-approval/models"
+
+	"github.com/gorilla/mux"
+)
+
+// routePermissions - право, которое требует каждый маршрут API.
+// Пустая строка - маршрут доступен любому аутентифицированному пользователю.
+var routePermissions = map[string]middleware.Permission{
+	"POST /api/auth/login":        "",
+	"POST /api/auth/refresh":      "",
+	"POST /api/auth/logout":       "",
+	"GET /api/auth/esia/login":    "",
+	"GET /api/auth/esia/callback": "",
+	"GET /api/auth/me":            "",
+	"POST /api/auth/password":     "",
+
+	"GET /api/api-keys":         "",
+	"POST /api/api-keys":        "",
+	"DELETE /api/api-keys/{id}": "",
+
+	"GET /api/users/approvers":                   "",
+	"GET /api/users":                             middleware.PermUsersManage,
+	"POST /api/users":                            middleware.PermUsersManage,
+	"GET /api/users/{id:[0-9]+}":                 middleware.PermUsersManage,
+	"PATCH /api/users/{id:[0-9]+}":               middleware.PermUsersManage,
+	"POST /api/users/{id:[0-9]+}/deactivate":     middleware.PermUsersManage,
+	"POST /api/users/{id:[0-9]+}/activate":       middleware.PermUsersManage,
+	"POST /api/users/{id:[0-9]+}/roles":          middleware.PermUsersManage,
+	"DELETE /api/users/{id:[0-9]+}/roles/{role}": middleware.PermUsersManage,
+	"POST /api/users/{id:[0-9]+}/password":       middleware.PermUsersManage,
+	"PUT /api/users/{id:[0-9]+}/esia":            middleware.PermUsersManage,
+
+	"GET /api/folders/tree":        "",
+	"POST /api/folders":            middleware.PermFolderCreate,
+	"PUT /api/folders/{id}":        middleware.PermFolderEdit,
+	"DELETE /api/folders/{id}":     middleware.PermFolderDelete,
+	"POST /api/folders/{id}/files": middleware.PermDocumentCreate,
+	"GET /api/files/{id}/download": "",
+
+	"GET /api/documents/types":                                   "",
+	"GET /api/documents/search":                                  "",
+	"GET /api/statuses":                                          "",
+	"POST /api/documents/approve/start":                          middleware.PermApprovalStart,
+	"POST /api/documents/approve":                                middleware.PermApprovalDecide,
+	"GET /api/documents/{id}":                                    "",
+	"PUT /api/documents/{id}":                                    middleware.PermDocumentEdit,
+	"PUT /api/documents/{id}/file":                               middleware.PermDocumentEdit,
+	"GET /api/documents/{id}/versions":                           "",
+	"POST /api/documents/{id}/versions":                          middleware.PermDocumentEdit,
+	"GET /api/documents/{id}/versions/{version:[0-9]+}/download": "",
+	"GET /api/documents/{id}/history":                            "",
+	"POST /api/documents":                                        middleware.PermDocumentCreate,
+
+	"GET /api/approvals":                                       "",
+	"GET /api/approvals/my":                                    "",
+	"GET /api/approvals/{id}":                                  "",
+	"GET /api/approvals/{id}/history":                          "",
+	"GET /api/approvals/{id}/sheet":                            "",
+	"GET /api/approvals/{id}/signatures":                       "",
+	"GET /api/approvals/{id}/signing-payload":                  middleware.PermApprovalDecide,
+	"POST /api/documents/{id}/approve":                         middleware.PermApprovalStart,
+	"GET /api/documents/{id}/approvals":                        "",
+	"POST /api/approvals/{id}/cancel":                          middleware.PermApprovalStart,
+	"POST /api/approvals/{id}/resubmit":                        middleware.PermApprovalStart,
+	"POST /api/approvals/{id}/approve":                         middleware.PermApprovalDecide,
+	"POST /api/approvals/{id}/approvers/{approverId}/reassign": middleware.PermApprovalManage,
+
+	"GET /api/route-templates":               "",
+	"POST /api/route-templates":              middleware.PermApprovalManage,
+	"GET /api/route-templates/{id}":          "",
+	"PUT /api/route-templates/{id}":          middleware.PermApprovalManage,
+	"DELETE /api/route-templates/{id}":       middleware.PermApprovalManage,
+	"GET /api/route-templates/{id}/versions": "",
+
+	"GET /api/audit": middleware.PermAuditView,
+
+	"GET /api/events/stream": "",
+
+	"GET /api/notifications":                   "",
+	"GET /api/notifications/unread-count":      "",
+	"POST /api/notifications/read-all":         "",
+	"POST /api/notifications/{id:[0-9]+}/read": "",
+
+	"GET /api/notification-preferences": "",
+	"PUT /api/notification-preferences": "",
+
+	"GET /api/webhooks":                           middleware.PermWebhooksManage,
+	"POST /api/webhooks":                          middleware.PermWebhooksManage,
+	"GET /api/webhooks/{id}":                      middleware.PermWebhooksManage,
+	"PUT /api/webhooks/{id}":                      middleware.PermWebhooksManage,
+	"DELETE /api/webhooks/{id}":                   middleware.PermWebhooksManage,
+	"GET /api/webhooks/{id}/deliveries":           middleware.PermWebhooksManage,
+	"POST /api/webhook-deliveries/{id}/redeliver": middleware.PermWebhooksManage,
+
+	"GET /api/delegations":         "",
+	"POST /api/delegations":        "",
+	"DELETE /api/delegations/{id}": "",
+}
+
+// testRoles - наборы ролей пользователей, от имени которых идут запросы
+var testRoles = map[string][]string{
+	"admin":    {string(models.RoleAdmin)},
+	"approver": {string(models.RoleApprover)},
+	"employee": {string(models.RoleEmployee)},
+	"none":     nil,
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// newTestRouter собирает роутер без БД: пользователь с ролями из заголовка
+// X-Test-Role подставляется вместо проверки токена
+func newTestRouter() *mux.Router {
+	fakeAuth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := &models.User{ID: 1, IsActive: true, Roles: testRoles[r.Header.Get("X-Test-Role")]}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.UserKey, user)))
+		})
+	}
+	noAudit := func(next http.Handler) http.Handler { return next }
+
+	return newRouter(fakeAuth, noAudit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+}
+
+// registeredRoutes возвращает все маршруты API в виде "METHOD /path"
+func registeredRoutes(t *testing.T, r *mux.Router) []string {
+	var routes []string
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, "/api/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			if m != http.MethodOptions {
+				routes = append(routes, m+" "+tpl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("обход маршрутов: %v", err)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestRoutePermissionTableIsComplete(t *testing.T) {
+	registered := map[string]bool{}
+	for _, route := range registeredRoutes(t, newTestRouter()) {
+		registered[route] = true
+		if _, ok := routePermissions[route]; !ok {
+			t.Errorf("маршрут %s не описан в routePermissions", route)
+		}
+	}
+	for route := range routePermissions {
+		if !registered[route] {
+			t.Errorf("маршрут %s из routePermissions не зарегистрирован", route)
+		}
+	}
+}
+
+func TestRoutesEnforceRolePermissions(t *testing.T) {
+	r := newTestRouter()
+
+	for _, route := range registeredRoutes(t, r) {
+		perm := routePermissions[route]
+		method, tpl, _ := strings.Cut(route, " ")
+		path := pathParam.ReplaceAllString(tpl, "1")
+
+		for role, roles := range testRoles {
+			user := &models.User{Roles: roles}
+			wantForbidden := perm != "" && !middleware.HasPermission(user, perm)
+
+			t.Run(route+"/"+role, func(t *testing.T) {
+				code, body, reached := serve(r, method, path, role)
+
+				if wantForbidden {
+					if reached || code != http.StatusForbidden {
+						t.Fatalf("ожидался 403, получен %d (хендлер вызван: %v)", code, reached)
+					}
+					var resp struct {
+						Code string `json:"code"`
+					}
+					if err := json.Unmarshal(body, &resp); err != nil || resp.Code != "forbidden" {
+						t.Fatalf("ожидался код forbidden, тело ответа: %s", body)
+					}
+					return
+				}
+
+				if !reached && code == http.StatusForbidden && strings.Contains(string(body), `"forbidden"`) {
+					t.Fatalf("роль %s не должна получать 403 на %s", role, route)
+				}
+			})
+		}
+	}
+}
+
+// serve выполняет запрос. Хендлеры собраны без сервисов и падают при
+// обращении к ним, поэтому паника означает, что запрос прошел проверку прав.
+func serve(r http.Handler, method, path, role string) (code int, body []byte, reached bool) {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Role", role)
+	rec := httptest.NewRecorder()
+
+	defer func() {
+		if recover() != nil {
+			reached = true
+		}
+		code, body = rec.Code, rec.Body.Bytes()
+	}()
+	r.ServeHTTP(rec, req)
+	return
+}
@@ -0,0 +1,56 @@
+// Команда auditverify пересчитывает цепочку хешей журнала аудита и сообщает
+// о первой записи, которая была удалена или изменена.
+//
+// Удаление последних записей цепочка не выдает, поэтому команда печатает хеш
+// головы цепочки. Его нужно сохранить вне БД и передать при следующей
+// проверке флагом -head: запись с этим хешем должна остаться в журнале.
+//
+// Подключение к БД берется из тех же переменных DB_*, что и у сервера.
+// Код выхода 1 - цепочка нарушена, 2 - проверку выполнить не удалось.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"document-approval/pkg/database"
+	"document-approval/services/audit"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	head := flag.String("head", "", "хеш головы цепочки, напечатанный прошлой проверкой")
+	flag.Parse()
+
+	db, err := sql.Open("postgres", database.URLFromEnv())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка подключения к БД:", err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	result, err := audit.NewAuditService(db).Verify(*head)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка проверки журнала:", err)
+		os.Exit(2)
+	}
+
+	if !result.Valid {
+		if result.BrokenID != 0 {
+			fmt.Printf("Цепочка нарушена на записи %d: %s (проверено записей: %d)\n",
+				result.BrokenID, result.Reason, result.Checked)
+		} else {
+			fmt.Printf("Цепочка нарушена: %s (проверено записей: %d)\n", result.Reason, result.Checked)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("Цепочка журнала аудита цела, проверено записей: %d\n", result.Checked)
+	if result.HeadHash != "" {
+		fmt.Printf("Голова цепочки: запись %d, хеш %s\n", result.HeadID, result.HeadHash)
+		fmt.Println("Сохраните хеш и передайте его в следующую проверку: auditverify -head <хеш>")
+	}
+}
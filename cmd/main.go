@@ -1,38 +1,41 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"document-approval/api/router"
+	"document-approval/middleware"
 	"document-approval/pkg/database"
+	"document-approval/services/apikey"
 	"document-approval/services/approval"
+	"document-approval/services/audit"
+	"document-approval/services/auth"
 	"document-approval/services/document"
+	"document-approval/services/esia"
 	"document-approval/services/folder"
+	"document-approval/services/mail"
+	"document-approval/services/notification"
+	"document-approval/services/sheet"
+	"document-approval/services/signature"
 	"document-approval/services/storage"
+	"document-approval/services/stream"
 	"document-approval/services/user"
+	"document-approval/services/webhook"
 
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	// Получаем параметры подключения из переменных окружения
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-
-	// Формируем строку подключения
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
-
 	// Подключение к БД
-	db, err := sql.Open("postgres", dbURL)
+	db, err := sql.Open("postgres", database.URLFromEnv())
 	if err != nil {
 		log.Fatal("Ошибка подключения к БД:", err)
 	}
@@ -49,15 +52,85 @@ func main() {
 		log.Fatal("Ошибка применения миграций:", err)
 	}
 
+	// Адрес клиента из заголовков прокси берем только от доверенных прокси
+	if err := middleware.SetTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		log.Fatal("Ошибка настройки доверенных прокси:", err)
+	}
+
 	// Инициализация сервисов
 	storageService := storage.NewGlusterStorage("storage/documents")
 	userService := user.NewUserService(db)
 	documentService := document.NewDocumentService(db, storageService)
 	approvalService := approval.NewApprovalService(db)
 	folderService := folder.NewFolderService(db, storageService)
+	apiKeyService := apikey.NewAPIKeyService(db)
+	auditService := audit.NewAuditService(db)
+	notificationService := notification.NewNotificationService(db)
+
+	// Шрифт листа согласования должен содержать кириллицу
+	sheetFont := os.Getenv("APPROVAL_SHEET_FONT")
+	if sheetFont == "" {
+		sheetFont = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	}
+	sheetService := sheet.NewSheetService(approvalService, documentService, storageService, sheetFont)
+
+	// Корневые сертификаты для проверки подписей решений; без них подпись отключена
+	signatureService, err := signature.NewSignatureService(db, storageService, os.Getenv("SIGNATURE_TRUST_STORE"))
+	if err != nil {
+		log.Fatal("Ошибка настройки проверки подписей:", err)
+	}
+
+	authConfig, err := loadAuthConfig()
+	if err != nil {
+		log.Fatal("Ошибка настройки аутентификации:", err)
+	}
+	authService, err := auth.NewAuthService(db, userService, authConfig)
+	if err != nil {
+		log.Fatal("Ошибка инициализации аутентификации:", err)
+	}
+
+	esiaService := esia.NewESIAService(db,
+		os.Getenv("ESIA_CLIENT_ID"),
+		os.Getenv("ESIA_CLIENT_SECRET"),
+		os.Getenv("ESIA_REDIRECT_URI"),
+	)
+	if esiaURL := os.Getenv("ESIA_URL"); esiaURL != "" {
+		esiaService.SetBaseURL(esiaURL)
+	}
+
+	// Планировщик сроков согласования. Реплики согласуются через advisory lock.
+	deadlineConfig, err := loadDeadlineConfig()
+	if err != nil {
+		log.Fatal("Ошибка настройки планировщика сроков:", err)
+	}
+	go approvalService.RunDeadlineScheduler(context.Background(), deadlineConfig)
+
+	// Письма копятся в очереди всегда, отправляются - если настроен SMTP
+	mailConfig, err := loadMailConfig()
+	if err != nil {
+		log.Fatal("Ошибка настройки отправки писем:", err)
+	}
+	mailService := mail.NewMailService(db, mailConfig)
+	if mailService.Enabled() {
+		go mailService.RunSender(context.Background())
+	} else {
+		log.Println("SMTP_HOST не задан, письма не отправляются")
+	}
+
+	// Вебхуки доставляются из журнала, повторные попытки переживают перезапуск
+	webhookInterval, err := loadWebhookInterval()
+	if err != nil {
+		log.Fatal("Ошибка настройки вебхуков:", err)
+	}
+	webhookService := webhook.NewWebhookService(db)
+	go webhookService.RunDispatcher(context.Background(), webhookInterval)
+
+	// Поток обновлений клиентов: реплики узнают о событиях через LISTEN/NOTIFY
+	streamHub := stream.NewHub(db, database.URLFromEnv())
+	go streamHub.Run(context.Background())
 
 	// Создание роутера
-	r := router.NewRouter(documentService, userService, approvalService, folderService)
+	r := router.NewRouter(documentService, userService, approvalService, folderService, authService, esiaService, apiKeyService, auditService, sheetService, signatureService, mailService, webhookService, streamHub, notificationService)
 
 	// Запуск сервера
 	port := os.Getenv("PORT")
@@ -68,3 +141,131 @@ func main() {
 	log.Printf("Сервер запущен на порту %s\n", port)
 	log.Fatal(http.ListenAndServe(":"+port, r))
 }
+
+// loadAuthConfig читает настройки подписи токенов из переменных окружения
+func loadAuthConfig() (auth.Config, error) {
+	cfg := auth.Config{
+		Algorithm: os.Getenv("JWT_ALGORITHM"),
+		Secret:    []byte(os.Getenv("JWT_SECRET")),
+		Issuer:    os.Getenv("JWT_ISSUER"),
+	}
+
+	if path := os.Getenv("JWT_PRIVATE_KEY_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("ошибка чтения приватного ключа: %w", err)
+		}
+		cfg.PrivateKeyPEM = data
+	}
+
+	if path := os.Getenv("JWT_PUBLIC_KEY_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("ошибка чтения публичного ключа: %w", err)
+		}
+		cfg.PublicKeyPEM = data
+	}
+
+	if ttl := os.Getenv("JWT_ACCESS_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return cfg, fmt.Errorf("неверное значение JWT_ACCESS_TTL: %w", err)
+		}
+		cfg.AccessTTL = d
+	}
+
+	if ttl := os.Getenv("JWT_REFRESH_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return cfg, fmt.Errorf("неверное значение JWT_REFRESH_TTL: %w", err)
+		}
+		cfg.RefreshTTL = d
+	}
+
+	return cfg, nil
+}
+
+// loadDeadlineConfig читает настройки планировщика сроков согласования
+func loadDeadlineConfig() (approval.DeadlineConfig, error) {
+	cfg := approval.DeadlineConfig{
+		Interval:      5 * time.Minute,
+		RemindBefore:  24 * time.Hour,
+		EscalationDue: 24 * time.Hour,
+	}
+
+	if v := os.Getenv("APPROVAL_DEADLINE_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("неверное значение APPROVAL_DEADLINE_CHECK_INTERVAL: %s", v)
+		}
+		cfg.Interval = d
+	}
+
+	if v := os.Getenv("APPROVAL_REMINDER_BEFORE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("неверное значение APPROVAL_REMINDER_BEFORE: %s", v)
+		}
+		cfg.RemindBefore = d
+	}
+
+	if v := os.Getenv("APPROVAL_ESCALATION_DUE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("неверное значение APPROVAL_ESCALATION_DUE: %s", v)
+		}
+		cfg.EscalationDue = d
+	}
+
+	return cfg, nil
+}
+
+// loadMailConfig читает настройки SMTP и отправки писем
+func loadMailConfig() (mail.Config, error) {
+	cfg := mail.Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     25,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		BaseURL:  os.Getenv("APP_BASE_URL"),
+		Interval: 30 * time.Second,
+	}
+
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 {
+			return cfg, fmt.Errorf("неверное значение SMTP_PORT: %s", v)
+		}
+		cfg.Port = port
+	}
+
+	if v := os.Getenv("MAIL_SEND_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("неверное значение MAIL_SEND_INTERVAL: %s", v)
+		}
+		cfg.Interval = d
+	}
+
+	if cfg.Host != "" && cfg.From == "" {
+		return cfg, fmt.Errorf("не задан адрес отправителя SMTP_FROM")
+	}
+
+	return cfg, nil
+}
+
+// loadWebhookInterval читает период обхода журнала доставок вебхуков
+func loadWebhookInterval() (time.Duration, error) {
+	interval := 10 * time.Second
+
+	if v := os.Getenv("WEBHOOK_DISPATCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return interval, fmt.Errorf("неверное значение WEBHOOK_DISPATCH_INTERVAL: %s", v)
+		}
+		interval = d
+	}
+
+	return interval, nil
+}
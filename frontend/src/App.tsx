@@ -11,6 +11,8 @@ import { CreateDocumentPage } from './pages/CreateDocumentPage';
 import { ApprovalsPage } from './pages/ApprovalsPage';
 import { DocumentPage } from './pages/DocumentPage';
 import { DocumentSearchPage } from './pages/DocumentSearchPage';
+import { LoginPage } from './pages/LoginPage';
+import { PrivateRoute } from './components/PrivateRoute';
 
 dayjs.locale('ru');
 
@@ -18,17 +20,28 @@ export const App: React.FC = () => {
     return (
         <ConfigProvider locale={ruRU}>
             <HashRouter>
-                <AppLayout>
-                    <Routes>
-                        <Route path="/" element={<Navigate to="/documents" replace />} />
-                        <Route path="/documents/create" element={<CreateDocumentPage />} />
-                        <Route path="/documents/search" element={<DocumentSearchPage />} />
-                        <Route path="/documents/:id" element={<DocumentPage />} />
-                        <Route path="/documents" element={<DocumentsPage />} />
-                        <Route path="/approvals" element={<ApprovalsPage />} />
-                        <Route path="*" element={<Navigate to="/documents" replace />} />
-                    </Routes>
-                </AppLayout>
+                <Routes>
+                    <Route path="/login" element={<LoginPage />} />
+                    <Route
+                        path="*"
+                        element={
+                            // Без токена API отвечает 401: сначала вход
+                            <PrivateRoute>
+                                <AppLayout>
+                                    <Routes>
+                                        <Route path="/" element={<Navigate to="/documents" replace />} />
+                                        <Route path="/documents/create" element={<CreateDocumentPage />} />
+                                        <Route path="/documents/search" element={<DocumentSearchPage />} />
+                                        <Route path="/documents/:id" element={<DocumentPage />} />
+                                        <Route path="/documents" element={<DocumentsPage />} />
+                                        <Route path="/approvals" element={<ApprovalsPage />} />
+                                        <Route path="*" element={<Navigate to="/documents" replace />} />
+                                    </Routes>
+                                </AppLayout>
+                            </PrivateRoute>
+                        }
+                    />
+                </Routes>
             </HashRouter>
         </ConfigProvider>
     );
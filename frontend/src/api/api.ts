@@ -1,5 +1,6 @@
 import axios from 'axios';
 import { message } from 'antd';
+import { attachAuth } from './session';
 
 export const api = axios.create({
     baseURL: 'https://pomau.ru/api',
@@ -8,6 +9,8 @@ export const api = axios.create({
     },
 });
 
+attachAuth(api);
+
 // Добавляем перехватчик для обработки ошибок
 api.interceptors.response.use(
     response => response,
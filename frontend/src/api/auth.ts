@@ -1,4 +1,5 @@
 import { apiClient } from './client';
+import { SessionTokens, clearTokens, getRefreshToken, saveTokens } from './session';
 
 export interface User {
     id: number;
@@ -7,18 +8,36 @@ export interface User {
     email: string;
 }
 
+interface LoginResponse {
+    token: string;
+    tokens: SessionTokens;
+    user: User;
+}
+
+// login сохраняет пару токенов из ответа: токен доступа уходит в заголовке
+// Authorization, refresh токен продлевает сессию после его истечения
 export const login = async (email: string, password: string): Promise<{ token: string; user: User }> => {
-    const { data } = await apiClient.post('/auth/login', { email, password });
-    localStorage.setItem('auth_token', data.token);
-    return data;
+    const { data } = await apiClient.post<{ success: boolean; data: LoginResponse }>('/auth/login', { email, password });
+    saveTokens(data.data.tokens);
+    return { token: data.data.token, user: data.data.user };
 };
 
 export const getCurrentUser = async (): Promise<User> => {
-    const { data } = await apiClient.get('/auth/me');
-    return data;
+    const { data } = await apiClient.get<{ success: boolean; data: User }>('/auth/me');
+    return data.data;
 };
 
-export const logout = () => {
-    localStorage.removeItem('auth_token');
-    window.location.href = '/login';
-}; 
\ No newline at end of file
+// logout отзывает refresh токен на сервере; локальные токены удаляются,
+// даже если сервер недоступен
+export const logout = async () => {
+    const refreshToken = getRefreshToken();
+    if (refreshToken) {
+        try {
+            await apiClient.post('/auth/logout', { refresh_token: refreshToken });
+        } catch (error) {
+            console.error('Error revoking session:', error);
+        }
+    }
+    clearTokens();
+    window.location.hash = '#/login';
+};
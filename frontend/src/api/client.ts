@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { attachAuth } from './session';
 
 export const apiClient = axios.create({
 	baseURL: 'https://pomau.ru/api',
@@ -6,3 +7,5 @@ export const apiClient = axios.create({
 		'Content-Type': 'application/json',
 	},
 })
+
+attachAuth(apiClient)
@@ -1,5 +1,5 @@
 import { apiClient } from './client';
-import { Document, ApprovalProcess, DocumentType } from '../types/document';
+import { Document, ApprovalProcess, DocumentType, DocumentVersion } from '../types/document';
 
 interface ApiResponse<T> {
     success: boolean;
@@ -99,4 +99,28 @@ export const updateDocument = async (id: number, document: Partial<Document>): P
 export const getDocumentTypes = async (): Promise<DocumentType[]> => {
     const { data } = await apiClient.get<{ success: boolean; data: DocumentType[] }>('/documents/types');
     return data.data;
-}; 
\ No newline at end of file
+}; 
+export const getDocumentVersions = async (id: number): Promise<DocumentVersion[]> => {
+    const { data } = await apiClient.get<ApiResponse<DocumentVersion[]>>(`/documents/${id}/versions`);
+    return data.data;
+};
+
+export const uploadDocumentVersion = async (id: number, file: File, comment: string): Promise<Document> => {
+    const formData = new FormData();
+    formData.append('file', file);
+    formData.append('comment', comment);
+
+    const { data } = await apiClient.post<ApiResponse<Document>>(`/documents/${id}/versions`, formData, {
+        headers: {
+            'Content-Type': 'multipart/form-data',
+        },
+    });
+    return data.data;
+};
+
+export const downloadDocumentVersion = async (id: number, version: number): Promise<Blob> => {
+    const { data } = await apiClient.get<Blob>(`/documents/${id}/versions/${version}/download`, {
+        responseType: 'blob',
+    });
+    return data;
+};
@@ -0,0 +1,35 @@
+import { api } from './api'
+
+export interface AppNotification {
+	id: number
+	type: string
+	title: string
+	document_id?: number
+	process_id?: number
+	actor_id?: number
+	data?: Record<string, unknown>
+	read_at?: string
+	created_at: string
+}
+
+interface NotificationList {
+	notifications: AppNotification[]
+	unread: number
+}
+
+export const getNotifications = async (
+	unreadOnly = false,
+	limit = 20
+): Promise<NotificationList> => {
+	const response = await api.get<{ success: boolean; data: NotificationList }>(
+		'/notifications',
+		{ params: { unread: unreadOnly, limit } }
+	)
+	return response.data.data
+}
+
+export const markNotificationRead = (id: number) =>
+	api.post(`/notifications/${id}/read`)
+
+export const markAllNotificationsRead = () =>
+	api.post('/notifications/read-all')
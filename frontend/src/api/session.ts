@@ -0,0 +1,84 @@
+import axios, { AxiosError, AxiosInstance, InternalAxiosRequestConfig } from 'axios';
+
+const API_URL = 'https://pomau.ru/api';
+const ACCESS_KEY = 'auth_token';
+const REFRESH_KEY = 'refresh_token';
+
+export interface SessionTokens {
+    access_token: string;
+    refresh_token: string;
+}
+
+export const getAccessToken = () => localStorage.getItem(ACCESS_KEY);
+export const getRefreshToken = () => localStorage.getItem(REFRESH_KEY);
+
+export const saveTokens = (tokens: SessionTokens) => {
+    localStorage.setItem(ACCESS_KEY, tokens.access_token);
+    localStorage.setItem(REFRESH_KEY, tokens.refresh_token);
+};
+
+export const clearTokens = () => {
+    localStorage.removeItem(ACCESS_KEY);
+    localStorage.removeItem(REFRESH_KEY);
+};
+
+// Одно обновление на все запросы, получившие 401 одновременно: refresh токен
+// одноразовый, повторное использование отзывает всю сессию
+let refreshing: Promise<string | null> | null = null;
+
+export const refreshAccessToken = (): Promise<string | null> => {
+    const refreshToken = getRefreshToken();
+    if (!refreshToken) return Promise.resolve(null);
+
+    if (!refreshing) {
+        refreshing = axios
+            .post(`${API_URL}/auth/refresh`, { refresh_token: refreshToken })
+            .then(({ data }) => {
+                saveTokens(data.data.tokens);
+                return data.data.tokens.access_token as string;
+            })
+            .catch(() => {
+                clearTokens();
+                return null;
+            })
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+};
+
+type RetriableConfig = InternalAxiosRequestConfig & { _retried?: boolean };
+
+// attachAuth добавляет к запросам клиента токен доступа. На 401 клиент один
+// раз обновляет токены и повторяет запрос, а если сессию продлить нельзя -
+// отправляет на страницу входа.
+export const attachAuth = (client: AxiosInstance) => {
+    client.interceptors.request.use(config => {
+        const token = getAccessToken();
+        if (token) {
+            config.headers.set('Authorization', `Bearer ${token}`);
+        }
+        return config;
+    });
+
+    client.interceptors.response.use(
+        response => response,
+        async (error: AxiosError) => {
+            const config = error.config as RetriableConfig | undefined;
+            const isAuthCall = config?.url?.startsWith('/auth/login') || config?.url?.startsWith('/auth/refresh');
+            if (error.response?.status !== 401 || !config || config._retried || isAuthCall) {
+                return Promise.reject(error);
+            }
+
+            config._retried = true;
+            const token = await refreshAccessToken();
+            if (!token) {
+                window.location.hash = '#/login';
+                return Promise.reject(error);
+            }
+            config.headers.set('Authorization', `Bearer ${token}`);
+            return client(config);
+        }
+    );
+};
@@ -0,0 +1,11 @@
+import { api } from './api'
+import { StatusLabels } from '../types/status'
+
+// Подписи статусов документов, процессов и утверждающих
+export const getStatusLabels = async (lang = 'ru'): Promise<StatusLabels> => {
+	const response = await api.get<{ success: boolean; data: StatusLabels }>(
+		'/statuses',
+		{ params: { lang } }
+	)
+	return response.data.data
+}
@@ -0,0 +1,20 @@
+import { api } from './api'
+
+export interface ApproverCandidate {
+	id: number
+	first_name: string
+	last_name: string
+	email: string
+	position: string
+}
+
+// Активные пользователи с ролью утверждающего
+export const getApprovers = async (
+	query = ''
+): Promise<ApproverCandidate[]> => {
+	const response = await api.get<{
+		success: boolean
+		data: ApproverCandidate[]
+	}>('/users/approvers', { params: query ? { q: query } : undefined })
+	return response.data.data ?? []
+}
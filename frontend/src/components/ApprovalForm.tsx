@@ -1,6 +1,7 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import { Form, Select, Button, message } from 'antd';
 import { startApprovalProcess } from '../api/approvals';
+import { ApproverCandidate, getApprovers } from '../api/users';
 
 interface ApprovalFormProps {
     documentId: number;
@@ -10,14 +11,19 @@ interface ApprovalFormProps {
 export const ApprovalForm: React.FC<ApprovalFormProps> = ({ documentId, onSuccess }) => {
     const [loading, setLoading] = useState(false);
     const [form] = Form.useForm();
+    const [approvers, setApprovers] = useState<ApproverCandidate[]>([]);
+    const [approversLoading, setApproversLoading] = useState(false);
 
-    // Фиктивный список подписантов для демонстрации
-    const approvers = [
-        { id: 1, name: 'Руководитель отдела' },
-        { id: 2, name: 'Начальник управления' },
-        { id: 3, name: 'Директор' },
-        { id: 4, name: 'Юрист' },
-    ];
+    useEffect(() => {
+        setApproversLoading(true);
+        getApprovers()
+            .then(setApprovers)
+            .catch(error => {
+                console.error('Error loading approvers:', error);
+                message.error('Ошибка загрузки списка подписантов');
+            })
+            .finally(() => setApproversLoading(false));
+    }, []);
 
     const handleSubmit = async (values: { approvers: number[] }) => {
         if (!values.approvers?.length) {
@@ -49,15 +55,16 @@ export const ApprovalForm: React.FC<ApprovalFormProps> = ({ documentId, onSucces
                 <Select
                     mode="multiple"
                     placeholder="Выберите подписантов"
-                    optionFilterProp="children"
+                    optionFilterProp="label"
+                    loading={approversLoading}
                     style={{ width: '100%' }}
-                >
-                    {approvers.map(user => (
-                        <Select.Option key={user.id} value={user.id}>
-                            {user.name}
-                        </Select.Option>
-                    ))}
-                </Select>
+                    options={approvers.map(user => ({
+                        value: user.id,
+                        label: [`${user.last_name} ${user.first_name}`, user.position]
+                            .filter(Boolean)
+                            .join(' - '),
+                    }))}
+                />
             </Form.Item>
 
             <Form.Item>
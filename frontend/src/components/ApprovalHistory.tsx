@@ -1,12 +1,15 @@
 import React from 'react';
 import { Timeline, Tag } from 'antd';
 import { Approver } from '../types/document';
+import { useStatusLabels } from '../hooks/useStatusLabels';
 
 interface ApprovalHistoryProps {
     approvers: Approver[];
 }
 
 export const ApprovalHistory: React.FC<ApprovalHistoryProps> = ({ approvers }) => {
+    const { approverStatusLabel } = useStatusLabels();
+
     return (
         <Timeline>
             {approvers.map((approver) => (
@@ -17,7 +20,7 @@ export const ApprovalHistory: React.FC<ApprovalHistoryProps> = ({ approvers }) =
                     <div>
                         <strong>{approver.user.first_name} {approver.user.last_name}</strong>
                         <Tag color={getStatusColor(approver.status)} style={{ marginLeft: 8 }}>
-                            {approver.status}
+                            {approverStatusLabel(approver.status)}
                         </Tag>
                     </div>
                     {approver.comment && (
@@ -36,10 +39,12 @@ export const ApprovalHistory: React.FC<ApprovalHistoryProps> = ({ approvers }) =
 
 const getStatusColor = (status: string): string => {
     switch (status) {
-        case 'Утверждено':
+        case 'approved':
             return 'success';
-        case 'Отклонено':
+        case 'rejected':
             return 'error';
+        case 'skipped':
+            return 'default';
         default:
             return 'processing';
     }
@@ -3,6 +3,7 @@ import { Table, Button, Space, Tag, Modal } from 'antd';
 import { ApprovalProcess } from '../types/document';
 import { ApprovalForm } from './ApprovalForm';
 import { approveDocument } from '../api/documents';
+import { useStatusLabels } from '../hooks/useStatusLabels';
 
 interface ApprovalListProps {
     approvals: ApprovalProcess[];
@@ -18,6 +19,7 @@ export const ApprovalList: React.FC<ApprovalListProps> = ({
     onApprovalComplete
 }) => {
     const [selectedProcess, setSelectedProcess] = React.useState<ApprovalProcess | null>(null);
+    const { processStatusLabel } = useStatusLabels();
 
     const handleApprove = async (processId: number, approved: boolean, comment: string) => {
         try {
@@ -48,8 +50,8 @@ export const ApprovalList: React.FC<ApprovalListProps> = ({
             dataIndex: 'status',
             key: 'status',
             render: (status: string) => {
-                const color = status === 'В процессе' ? 'processing' : 'success';
-                return <Tag color={color}>{status}</Tag>;
+                const color = status === 'in_progress' ? 'processing' : 'success';
+                return <Tag color={color}>{processStatusLabel(status)}</Tag>;
             },
         },
         {
@@ -57,7 +59,7 @@ export const ApprovalList: React.FC<ApprovalListProps> = ({
             key: 'actions',
             render: (text: string, record: ApprovalProcess) => (
                 <Space>
-                    {!readonly && record.status === 'В процессе' && (
+                    {!readonly && record.status === 'in_progress' && (
                         <Button
                             type="primary"
                             onClick={() => setSelectedProcess(record)}
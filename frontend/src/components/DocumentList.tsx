@@ -4,6 +4,7 @@ import { EditOutlined, DownloadOutlined } from '@ant-design/icons';
 import { useNavigate } from 'react-router-dom';
 import { Document } from '../types/document';
 import { FileDownload } from './FileDownload';
+import { useStatusLabels } from '../hooks/useStatusLabels';
 
 interface DocumentListProps {
     documents: Document[];
@@ -12,6 +13,7 @@ interface DocumentListProps {
 
 export const DocumentList: React.FC<DocumentListProps> = ({ documents, loading }) => {
     const navigate = useNavigate();
+    const { documentStatusLabel } = useStatusLabels();
 
     const columns = [
         {
@@ -37,12 +39,12 @@ export const DocumentList: React.FC<DocumentListProps> = ({ documents, loading }
             render: (status: string) => {
                 let color = 'default';
                 switch (status) {
-                    case 'Черновик': color = 'default'; break;
-                    case 'Рассматривается': color = 'processing'; break;
-                    case 'Утвержден': color = 'success'; break;
-                    case 'Отклонен': color = 'error'; break;
+                    case 'draft': color = 'default'; break;
+                    case 'in_review': color = 'processing'; break;
+                    case 'approved': color = 'success'; break;
+                    case 'rejected': color = 'error'; break;
                 }
-                return <Tag color={color}>{status}</Tag>;
+                return <Tag color={color}>{documentStatusLabel(status)}</Tag>;
             },
         },
         {
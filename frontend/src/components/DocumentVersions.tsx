@@ -0,0 +1,149 @@
+import React, { useEffect, useState } from 'react';
+import { Button, Card, Input, Modal, Space, Table, Tag, Upload, message } from 'antd';
+import { DownloadOutlined, UploadOutlined } from '@ant-design/icons';
+import type { UploadFile } from 'antd/es/upload/interface';
+import { DocumentVersion } from '../types/document';
+import { downloadDocumentVersion, getDocumentVersions, uploadDocumentVersion } from '../api/documents';
+
+interface DocumentVersionsProps {
+    documentId: number;
+    currentVersion?: number;
+    onUploaded?: () => void;
+}
+
+export const DocumentVersions: React.FC<DocumentVersionsProps> = ({ documentId, currentVersion, onUploaded }) => {
+    const [versions, setVersions] = useState<DocumentVersion[]>([]);
+    const [loading, setLoading] = useState(false);
+    const [uploadOpen, setUploadOpen] = useState(false);
+    const [fileList, setFileList] = useState<UploadFile[]>([]);
+    const [comment, setComment] = useState('');
+    const [uploading, setUploading] = useState(false);
+
+    const loadVersions = async () => {
+        setLoading(true);
+        try {
+            setVersions(await getDocumentVersions(documentId));
+        } catch (error) {
+            console.error('Error loading versions:', error);
+            message.error('Ошибка загрузки версий');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    useEffect(() => {
+        loadVersions();
+    }, [documentId]);
+
+    const handleDownload = async (v: DocumentVersion) => {
+        try {
+            const blob = await downloadDocumentVersion(documentId, v.version);
+            const url = window.URL.createObjectURL(blob);
+            const a = document.createElement('a');
+            a.href = url;
+            a.download = v.file_name;
+            document.body.appendChild(a);
+            a.click();
+            window.URL.revokeObjectURL(url);
+            document.body.removeChild(a);
+        } catch (error) {
+            console.error('Download error:', error);
+            message.error('Ошибка при скачивании файла');
+        }
+    };
+
+    const handleUpload = async () => {
+        const file = fileList[0]?.originFileObj;
+        if (!file) {
+            message.error('Выберите файл');
+            return;
+        }
+        setUploading(true);
+        try {
+            await uploadDocumentVersion(documentId, file, comment);
+            message.success('Новая версия загружена');
+            setUploadOpen(false);
+            setFileList([]);
+            setComment('');
+            loadVersions();
+            onUploaded?.();
+        } catch (error) {
+            console.error('Upload error:', error);
+            message.error('Ошибка загрузки версии');
+        } finally {
+            setUploading(false);
+        }
+    };
+
+    const columns = [
+        {
+            title: 'Версия',
+            dataIndex: 'version',
+            key: 'version',
+            render: (version: number) => (
+                <Space>
+                    {version}
+                    {version === currentVersion && <Tag color="blue">текущая</Tag>}
+                </Space>
+            ),
+        },
+        { title: 'Файл', dataIndex: 'file_name', key: 'file_name' },
+        { title: 'Автор', dataIndex: 'created_by_name', key: 'created_by_name' },
+        {
+            title: 'Загружена',
+            dataIndex: 'created_at',
+            key: 'created_at',
+            render: (date: string) => new Date(date).toLocaleString('ru-RU'),
+        },
+        { title: 'Комментарий', dataIndex: 'comment', key: 'comment' },
+        {
+            title: '',
+            key: 'actions',
+            render: (_: unknown, v: DocumentVersion) => (
+                <Button type="link" icon={<DownloadOutlined />} onClick={() => handleDownload(v)}>
+                    Скачать
+                </Button>
+            ),
+        },
+    ];
+
+    return (
+        <Card
+            title="Версии файла"
+            extra={
+                <Button icon={<UploadOutlined />} onClick={() => setUploadOpen(true)}>
+                    Загрузить новую версию
+                </Button>
+            }
+        >
+            <Table rowKey="id" size="small" loading={loading} dataSource={versions} columns={columns} pagination={false} />
+
+            <Modal
+                title="Новая версия файла"
+                open={uploadOpen}
+                onOk={handleUpload}
+                confirmLoading={uploading}
+                onCancel={() => setUploadOpen(false)}
+                okText="Загрузить"
+                cancelText="Отмена"
+            >
+                <Space direction="vertical" style={{ width: '100%' }}>
+                    <Upload
+                        maxCount={1}
+                        fileList={fileList}
+                        beforeUpload={() => false}
+                        onChange={({ fileList }) => setFileList(fileList)}
+                    >
+                        <Button icon={<UploadOutlined />}>Выбрать файл</Button>
+                    </Upload>
+                    <Input.TextArea
+                        rows={3}
+                        placeholder="Что изменилось в этой версии"
+                        value={comment}
+                        onChange={e => setComment(e.target.value)}
+                    />
+                </Space>
+            </Modal>
+        </Card>
+    );
+};
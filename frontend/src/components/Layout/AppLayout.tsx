@@ -9,6 +9,7 @@ import {
 } from '@ant-design/icons'
 import { useAuth } from '../../hooks/useAuth'
 import { logout } from '../../api/auth'
+import { NotificationBell } from './NotificationBell'
 
 const { Header, Sider, Content } = Layout
 
@@ -102,10 +103,19 @@ export const AppLayout: React.FC<{ children: React.ReactNode }> = ({
 					enterButton={<SearchOutlined />}
 					className='header-search'
 				/>
-				<Space style={{ whiteSpace: 'nowrap' }}>
+				<Space style={{ whiteSpace: 'nowrap' }} size='large'>
+					<NotificationBell />
 					<span style={{ color: 'white' }}>
 						{user?.first_name} {user?.last_name}
 					</span>
+					<Button
+						type='text'
+						icon={<LogoutOutlined />}
+						style={{ color: 'white' }}
+						onClick={logout}
+					>
+						Выйти
+					</Button>
 				</Space>
 			</Header>
 			<Layout>
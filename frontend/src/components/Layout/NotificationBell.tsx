@@ -0,0 +1,106 @@
+import React, { useCallback, useEffect, useState } from 'react'
+import { Badge, Button, Dropdown, Empty, List, Typography } from 'antd'
+import { BellOutlined } from '@ant-design/icons'
+import { useNavigate } from 'react-router-dom'
+import {
+	AppNotification,
+	getNotifications,
+	markAllNotificationsRead,
+	markNotificationRead,
+} from '../../api/notifications'
+import { useEventStream } from '../../hooks/useEventStream'
+
+const { Text } = Typography
+
+export const NotificationBell: React.FC = () => {
+	const navigate = useNavigate()
+	const [items, setItems] = useState<AppNotification[]>([])
+	const [unread, setUnread] = useState(0)
+
+	const load = useCallback(async () => {
+		try {
+			const data = await getNotifications()
+			setItems(data.notifications)
+			setUnread(data.unread)
+		} catch (error) {
+			console.error('Error loading notifications:', error)
+		}
+	}, [])
+
+	useEffect(() => {
+		load()
+	}, [load])
+
+	// Новое уведомление приходит в поток обновлений вместе с числом непрочитанных
+	useEventStream(['notification.created'], event => {
+		const { notification, unread } = event.data as {
+			notification: AppNotification
+			unread: number
+		}
+		setItems(prev => [notification, ...prev].slice(0, 20))
+		setUnread(unread)
+	}, load)
+
+	const open = async (n: AppNotification) => {
+		if (!n.read_at) {
+			await markNotificationRead(n.id)
+			load()
+		}
+		if (n.document_id) {
+			navigate(`/documents/${n.document_id}`)
+		}
+	}
+
+	const readAll = async () => {
+		await markAllNotificationsRead()
+		load()
+	}
+
+	const panel = (
+		<div
+			style={{
+				width: 360,
+				maxHeight: 420,
+				overflowY: 'auto',
+				background: '#fff',
+				boxShadow: '0 6px 16px rgba(0, 0, 0, 0.12)',
+				borderRadius: 8,
+				padding: 8,
+			}}
+		>
+			<div style={{ display: 'flex', justifyContent: 'space-between', padding: '4px 8px' }}>
+				<Text strong>Уведомления</Text>
+				<Button type='link' size='small' disabled={unread === 0} onClick={readAll}>
+					Прочитать все
+				</Button>
+			</div>
+			{items.length === 0 ? (
+				<Empty description='Уведомлений нет' image={Empty.PRESENTED_IMAGE_SIMPLE} />
+			) : (
+				<List
+					size='small'
+					dataSource={items}
+					renderItem={n => (
+						<List.Item
+							style={{ cursor: 'pointer', background: n.read_at ? undefined : '#e6f4ff' }}
+							onClick={() => open(n)}
+						>
+							<List.Item.Meta
+								title={<Text strong={!n.read_at}>{n.title}</Text>}
+								description={new Date(n.created_at).toLocaleString('ru-RU')}
+							/>
+						</List.Item>
+					)}
+				/>
+			)}
+		</div>
+	)
+
+	return (
+		<Dropdown dropdownRender={() => panel} trigger={['click']} placement='bottomRight'>
+			<Badge count={unread} size='small'>
+				<BellOutlined style={{ color: 'white', fontSize: 18, cursor: 'pointer' }} />
+			</Badge>
+		</Dropdown>
+	)
+}
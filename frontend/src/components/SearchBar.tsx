@@ -1,5 +1,6 @@
 import React from 'react'
 import { Input, Select, DatePicker, Form, Button, Space } from 'antd'
+import { useStatusLabels } from '../hooks/useStatusLabels'
 
 const { Option } = Select
 
@@ -9,6 +10,7 @@ interface SearchBarProps {
 
 export const SearchBar: React.FC<SearchBarProps> = ({ onSearch }) => {
 	const [form] = Form.useForm()
+	const { documentStatusLabels } = useStatusLabels()
 
 	const handleSearch = (values: any) => {
 		onSearch(values)
@@ -27,10 +29,9 @@ export const SearchBar: React.FC<SearchBarProps> = ({ onSearch }) => {
 
 			<Form.Item name='status'>
 				<Select style={{ width: 150 }} placeholder='Статус'>
-					<Option value='Черновик'>Черновик</Option>
-					<Option value='Рассматривается'>Рассматривается</Option>
-					<Option value='Утвержден'>Утвержден</Option>
-					<Option value='Отклонен'>Отклонен</Option>
+					{Object.entries(documentStatusLabels).map(([code, label]) => (
+						<Option key={code} value={code}>{label}</Option>
+					))}
 				</Select>
 			</Form.Item>
 
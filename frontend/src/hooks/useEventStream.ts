@@ -0,0 +1,116 @@
+import { useEffect, useRef } from 'react';
+import { getAccessToken, refreshAccessToken } from '../api/session';
+
+const STREAM_URL = 'https://pomau.ru/api/events/stream';
+const RETRY_MS = 5000;
+const RELOAD_EVENT = 'stream.reload';
+// SEEN_LIMIT - сколько последних ID помнится для отсева повторов
+const SEEN_LIMIT = 1000;
+
+export interface StreamEvent {
+    id: number;
+    type: string;
+    data: Record<string, unknown>;
+    created_at: string;
+}
+
+// useEventStream подписывается на поток обновлений /events/stream и вызывает
+// onEvent для событий из types. EventSource не умеет передавать заголовок
+// Authorization, поэтому поток читается через fetch; после обрыва клиент
+// переподключается с Last-Event-ID и получает пропущенное. События могут
+// прийти не по порядку ID и повторно - повторы отсеиваются по ID. Если
+// пропущенное не отдать целиком, сервер присылает stream.reload, и
+// вызывается onReload: данные нужно перечитать.
+export const useEventStream = (
+    types: string[],
+    onEvent: (event: StreamEvent) => void,
+    onReload?: () => void,
+) => {
+    const handler = useRef(onEvent);
+    handler.current = onEvent;
+    const reloadHandler = useRef(onReload);
+    reloadHandler.current = onReload;
+    const typesKey = types.join(',');
+
+    useEffect(() => {
+        const wanted = new Set(typesKey.split(','));
+        const controller = new AbortController();
+        let lastEventId = 0;
+        const seen = new Set<number>();
+        let timer: ReturnType<typeof setTimeout> | undefined;
+
+        const dispatch = (block: string) => {
+            let id = '';
+            let type = 'message';
+            const data: string[] = [];
+            for (const line of block.split('\n')) {
+                if (line.startsWith('id:')) id = line.slice(3).trim();
+                else if (line.startsWith('event:')) type = line.slice(6).trim();
+                else if (line.startsWith('data:')) data.push(line.slice(5).trim());
+            }
+            const eventId = Number(id);
+            if (id && Number.isFinite(eventId)) {
+                if (type === RELOAD_EVENT) {
+                    seen.clear();
+                    lastEventId = eventId;
+                    reloadHandler.current?.();
+                    return;
+                }
+                if (seen.has(eventId)) return;
+                seen.add(eventId);
+                if (seen.size > SEEN_LIMIT) {
+                    seen.delete(seen.values().next().value as number);
+                }
+                lastEventId = Math.max(lastEventId, eventId);
+            }
+            if (!wanted.has(type) || data.length === 0) return;
+            try {
+                handler.current(JSON.parse(data.join('\n')));
+            } catch (error) {
+                console.error('Error parsing stream event:', error);
+            }
+        };
+
+        const connect = async () => {
+            const headers: Record<string, string> = {};
+            const token = getAccessToken();
+            if (token) headers['Authorization'] = `Bearer ${token}`;
+            if (lastEventId) headers['Last-Event-ID'] = String(lastEventId);
+
+            try {
+                const response = await fetch(STREAM_URL, { headers, signal: controller.signal });
+                // Токен доступа истек: обновляем сессию, переподключение
+                // пойдет уже с новым токеном
+                if (response.status === 401) await refreshAccessToken();
+                if (!response.ok || !response.body) throw new Error(`HTTP ${response.status}`);
+
+                const reader = response.body.getReader();
+                const decoder = new TextDecoder();
+                let buffer = '';
+                for (;;) {
+                    const { value, done } = await reader.read();
+                    if (done) break;
+                    buffer += decoder.decode(value, { stream: true });
+                    let end;
+                    while ((end = buffer.indexOf('\n\n')) >= 0) {
+                        dispatch(buffer.slice(0, end));
+                        buffer = buffer.slice(end + 2);
+                    }
+                }
+            } catch (error) {
+                if (controller.signal.aborted) return;
+                console.error('Event stream error:', error);
+            }
+            if (!controller.signal.aborted) {
+                timer = setTimeout(connect, RETRY_MS);
+            }
+        };
+
+        connect();
+
+        return () => {
+            controller.abort();
+            if (timer) clearTimeout(timer);
+        };
+    }, [typesKey]);
+};
@@ -0,0 +1,55 @@
+import { useState, useEffect } from 'react';
+import { getStatusLabels } from '../api/statuses';
+import { StatusLabels } from '../types/status';
+
+const emptyLabels: StatusLabels = { document: {}, process: {}, approver: {} };
+
+// Подписи загружаются один раз на всё приложение
+let cached: StatusLabels | null = null;
+let pending: Promise<StatusLabels> | null = null;
+
+const loadStatusLabels = (): Promise<StatusLabels> => {
+    if (!pending) {
+        pending = getStatusLabels()
+            .then((labels) => {
+                cached = labels;
+                return labels;
+            })
+            .catch((error) => {
+                // Следующий вызов попробует загрузить подписи снова
+                pending = null;
+                throw error;
+            });
+    }
+    return pending;
+};
+
+// useStatusLabels возвращает подписи статусов с сервера. Пока они не
+// загружены, вместо подписи показывается код статуса.
+export const useStatusLabels = () => {
+    const [labels, setLabels] = useState<StatusLabels>(cached || emptyLabels);
+
+    useEffect(() => {
+        if (cached) {
+            return;
+        }
+        let active = true;
+        loadStatusLabels()
+            .then((loaded) => {
+                if (active) {
+                    setLabels(loaded);
+                }
+            })
+            .catch((error) => console.error('Error loading status labels:', error));
+        return () => {
+            active = false;
+        };
+    }, []);
+
+    return {
+        documentStatusLabels: labels.document,
+        documentStatusLabel: (code: string): string => labels.document[code] || code,
+        processStatusLabel: (code: string): string => labels.process[code] || code,
+        approverStatusLabel: (code: string): string => labels.approver[code] || code,
+    };
+};
@@ -1,6 +1,8 @@
 import React, { useState, useEffect } from 'react';
 import { Table, Button, Space, Modal, Input, Select, message, Empty, Tag, Typography } from 'antd';
 import { getApprovals, approveDocument } from '../api/approvals';
+import { useStatusLabels } from '../hooks/useStatusLabels';
+import { useEventStream } from '../hooks/useEventStream';
 
 interface ApprovalTask {
     id: number;
@@ -21,6 +23,7 @@ export const ApprovalsPage: React.FC = () => {
     const [selectedTask, setSelectedTask] = useState<ApprovalTask | null>(null);
     const [comment, setComment] = useState('');
     const [selectedUserId, setSelectedUserId] = useState<number>(0);
+    const { processStatusLabel } = useStatusLabels();
 
     const users = [
         { id: 1, name: 'Руководитель отдела' },
@@ -33,6 +36,13 @@ export const ApprovalsPage: React.FC = () => {
         loadTasks();
     }, []);
 
+    // Новые задания и решения по своим документам приходят без перезагрузки
+    useEventStream(['approval.assigned', 'approval.decided'], () => {
+        loadTasks();
+    }, () => {
+        loadTasks();
+    });
+
     const loadTasks = async () => {
         setLoading(true);
         try {
@@ -87,11 +97,11 @@ export const ApprovalsPage: React.FC = () => {
             render: (status: string) => {
                 let color = 'default';
                 switch (status) {
-                    case 'В процессе': color = 'processing'; break;
-                    case 'Завершен': color = 'success'; break;
-                    case 'Отклонен': color = 'error'; break;
+                    case 'in_progress': color = 'processing'; break;
+                    case 'completed': color = 'success'; break;
+                    case 'canceled': color = 'error'; break;
                 }
-                return <Tag color={color}>{status}</Tag>;
+                return <Tag color={color}>{processStatusLabel(status)}</Tag>;
             }
         },
         {
@@ -102,7 +112,7 @@ export const ApprovalsPage: React.FC = () => {
         {
             title: 'Действия',
             render: (_: any, record: ApprovalTask) => {
-                if (record.status === 'В процессе') {
+                if (record.status === 'in_progress') {
                     return (
                         <Space>
                             <Button 
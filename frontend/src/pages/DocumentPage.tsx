@@ -6,6 +6,7 @@ import dayjs from 'dayjs';
 import { Document, DocumentType, FieldConfig } from '../types/document';
 import { getDocument, updateDocument, getDocumentTypes } from '../api/documents';
 import { ApprovalForm } from '../components/ApprovalForm';
+import { DocumentVersions } from '../components/DocumentVersions';
 
 export const DocumentPage: React.FC = () => {
     const { id } = useParams<{ id: string }>();
@@ -91,8 +92,9 @@ export const DocumentPage: React.FC = () => {
             await updateDocument(parseInt(id), updatedDoc);
             message.success('Документ сохранен');
             navigate('/documents');
-        } catch (error) {
-            message.error('Ошибка при сохранении документа');
+        } catch (error: any) {
+            // 409 - документ на согласовании или утвержден, сервер объясняет почему
+            message.error(error.response?.data?.error || 'Ошибка при сохранении документа');
             console.error(error);
         }
     };
@@ -193,7 +195,7 @@ export const DocumentPage: React.FC = () => {
                             <Button 
                                 type="primary"
                                 onClick={() => setIsApprovalModalVisible(true)}
-                                disabled={!document || document.status !== 'Черновик'}
+                                disabled={!document || document.status !== 'draft'}
                             >
                                 Отправить на согласование
                             </Button>
@@ -361,7 +363,7 @@ export const DocumentPage: React.FC = () => {
                                     type="primary" 
                                     icon={<SaveOutlined />} 
                                     htmlType="submit"
-                                    disabled={!selectedType}
+                                    disabled={!selectedType || !document || !['draft', 'rejected'].includes(document.status)}
                                 >
                                     Сохранить
                                 </Button>
@@ -373,6 +375,14 @@ export const DocumentPage: React.FC = () => {
                     </Form>
                 </Card>
 
+                {document && (
+                    <DocumentVersions
+                        documentId={document.id}
+                        currentVersion={document.current_version}
+                        onUploaded={loadDocument}
+                    />
+                )}
+
                 <Modal
                     title="Отправка на согласование"
                     open={isApprovalModalVisible}
@@ -20,6 +20,7 @@ import { Document, DocumentType } from '../types/document'
 import { searchDocuments, getDocumentTypes } from '../api/documents'
 import dayjs from 'dayjs'
 import { message } from 'antd'
+import { useStatusLabels } from '../hooks/useStatusLabels'
 
 const { Panel } = Collapse
 
@@ -40,6 +41,7 @@ export const DocumentSearchPage: React.FC = () => {
 	const [showFilters, setShowFilters] = useState(false)
 	const [filters, setFilters] = useState<SearchFilters>({})
 	const [documentTypes, setDocumentTypes] = useState<DocumentType[]>([])
+	const { documentStatusLabel, documentStatusLabels } = useStatusLabels()
 	const [facets, setFacets] = useState({
 		statuses: new Map<string, number>(),
 		types: new Map<string, number>(),
@@ -166,13 +168,13 @@ export const DocumentSearchPage: React.FC = () => {
 
 	const getStatusColor = (status: string) => {
 		switch (status) {
-			case 'Черновик':
+			case 'draft':
 				return 'default'
-			case 'Рассматривается':
+			case 'in_review':
 				return 'processing'
-			case 'Утвержден':
+			case 'approved':
 				return 'success'
-			case 'Отклонен':
+			case 'rejected':
 				return 'error'
 			default:
 				return 'default'
@@ -372,7 +374,7 @@ export const DocumentSearchPage: React.FC = () => {
 														: ''
 												}
 											>
-												{status} ({count})
+												{documentStatusLabel(status)} ({count})
 											</Tag>
 										</div>
 									)
@@ -457,7 +459,7 @@ export const DocumentSearchPage: React.FC = () => {
 												color={getStatusColor(doc.status)}
 												style={{ cursor: 'default' }}
 											>
-												{doc.status}
+												{documentStatusLabel(doc.status)}
 											</Tag>
 											{doc.document_type && (
 												<Tag style={{ cursor: 'default' }}>
@@ -539,12 +541,11 @@ export const DocumentSearchPage: React.FC = () => {
 							value={filters.status}
 							onChange={handleStatusChange}
 						>
-							<Select.Option value='Черновик'>Черновик</Select.Option>
-							<Select.Option value='Рассматривается'>
-								Рассматривается
-							</Select.Option>
-							<Select.Option value='Утвержден'>Утвержден</Select.Option>
-							<Select.Option value='Отклонен'>Отклонен</Select.Option>
+							{Object.entries(documentStatusLabels).map(([code, label]) => (
+								<Select.Option key={code} value={code}>
+									{label}
+								</Select.Option>
+							))}
 						</Select>
 					</Form.Item>
 
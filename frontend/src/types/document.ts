@@ -16,6 +16,21 @@ export interface Document {
     document_type: string;
     metadata: Record<string, any>;
     file_content?: string;
+    current_version?: number;
+    version_count: number;
+}
+
+export interface DocumentVersion {
+    id: number;
+    document_id: number;
+    version: number;
+    file_name: string;
+    checksum?: string;
+    size?: number;
+    comment?: string;
+    created_by?: number;
+    created_by_name?: string;
+    created_at: string;
 }
 
 export interface DocumentType {
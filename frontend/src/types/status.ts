@@ -0,0 +1,8 @@
+// Подписи статусов по кодам, которые возвращает API. Сервер отдает их
+// через /api/statuses на языке пользователя.
+
+export interface StatusLabels {
+    document: Record<string, string>;
+    process: Record<string, string>;
+    approver: Record<string, string>;
+}
@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"document-approval/models"
+	"document-approval/services/audit"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	RequestIDKey  contextKey = "request_id"
+	auditStateKey contextKey = "audit_state"
+)
+
+// auditBodyLimit - сколько байт тела запроса и ответа сохраняется для журнала
+const auditBodyLimit = 64 << 10
+
+// auditState передает аутентифицированного пользователя из AuthMiddleware
+// во внешний Audit: контекст внутреннего запроса снаружи не виден
+type auditState struct {
+	user *models.User
+	key  *models.APIKey
+}
+
+// Audit записывает каждый изменяющий запрос к API в журнал аудита и
+// назначает запросу идентификатор (заголовок X-Request-ID)
+func Audit(auditService *audit.AuditService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get("X-Request-ID")
+			if requestID == "" || len(requestID) > 64 {
+				requestID = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+			ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+
+			if isReadOnlyMethod(r.Method) {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			state := &auditState{}
+			ctx = context.WithValue(ctx, auditStateKey, state)
+
+			entityType, entityID := auditEntity(r)
+
+			var body []byte
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				body, _ = io.ReadAll(io.LimitReader(r.Body, auditBodyLimit))
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			}
+
+			entry := &models.AuditEntry{
+				RequestID:  requestID,
+				IP:         clientIP(r),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Action:     r.Method + " " + routeTemplate(r),
+				EntityType: entityType,
+				EntityID:   entityID,
+			}
+
+			if entityID != nil {
+				before, err := auditService.Snapshot(entityType, *entityID)
+				if err != nil {
+					log.Printf("Аудит: %v", err)
+				}
+				entry.Before = before
+			}
+
+			rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			entry.StatusCode = rec.status
+			if state.user != nil {
+				entry.UserID = &state.user.ID
+			}
+			if state.key != nil {
+				entry.APIKeyID = &state.key.ID
+			}
+
+			// Для созданной сущности ID берем из ответа
+			if entry.EntityID == nil && rec.status < http.StatusMultipleChoices {
+				entry.EntityID = createdID(rec.body.Bytes())
+			}
+
+			if audit.HasSnapshot(entityType) {
+				if entry.EntityID != nil {
+					after, err := auditService.Snapshot(entityType, *entry.EntityID)
+					if err != nil {
+						log.Printf("Аудит: %v", err)
+					}
+					entry.After = after
+				}
+			} else {
+				// Состояние сущности недоступно - сохраняем сам запрос без секретов
+				entry.After = redactedBody(body)
+			}
+
+			if err := auditService.Record(entry); err != nil {
+				log.Printf("Аудит: не удалось записать запрос %s: %v", requestID, err)
+			}
+		})
+	}
+}
+
+// GetRequestIDFromContext возвращает идентификатор запроса
+func GetRequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+// noteAuditUser сообщает журналу аудита, кто выполняет запрос
+func noteAuditUser(ctx context.Context, user *models.User, key *models.APIKey) {
+	if state, ok := ctx.Value(auditStateKey).(*auditState); ok {
+		state.user = user
+		state.key = key
+	}
+}
+
+// auditRecorder запоминает код ответа и начало тела ответа
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *auditRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *auditRecorder) Write(b []byte) (int, error) {
+	if room := auditBodyLimit - r.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		r.body.Write(b[:room])
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// auditEntity определяет сущность по пути: первый сегмент после /api
+// и параметр {id} маршрута
+func auditEntity(r *http.Request) (string, *int64) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/")
+	entityType, _, _ := strings.Cut(path, "/")
+
+	if id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64); err == nil {
+		return entityType, &id
+	}
+	return entityType, nil
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
+
+// createdID достает ID созданной сущности из ответа response.Success
+func createdID(body []byte) *int64 {
+	var resp struct {
+		Data struct {
+			ID        *int64 `json:"id"`
+			ProcessID *int64 `json:"process_id"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	if resp.Data.ID != nil {
+		return resp.Data.ID
+	}
+	return resp.Data.ProcessID
+}
+
+// redactedBody возвращает JSON запроса, скрывая пароли, токены и секреты
+func redactedBody(body []byte) json.RawMessage {
+	var v any
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+
+	out, err := json.Marshal(redact(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redact(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			name := strings.ToLower(k)
+			if strings.Contains(name, "password") || strings.Contains(name, "token") || strings.Contains(name, "secret") {
+				val[k] = "***"
+				continue
+			}
+			val[k] = redact(item)
+		}
+	case []any:
+		for i := range val {
+			val[i] = redact(val[i])
+		}
+	}
+	return v
+}
+
+// trustedProxies - адреса обратных прокси, которым разрешено передавать
+// адрес клиента в X-Real-IP и X-Forwarded-For
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies задает доверенные прокси списком IP-адресов и подсетей
+// через запятую, например "127.0.0.1,10.0.0.0/8". Пустой список - заголовкам
+// с адресом клиента не доверяем.
+func SetTrustedProxies(list string) error {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return fmt.Errorf("неверный адрес доверенного прокси: %s", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return fmt.Errorf("неверная подсеть доверенного прокси: %s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP возвращает адрес клиента. Заголовки прокси учитываются, только
+// если запрос пришел от доверенного прокси; в X-Forwarded-For берется
+// самый правый адрес, который не принадлежит доверенному прокси.
+func clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if !isTrustedProxy(ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+	return remote
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies("10.0.0.1, 172.16.0.0/12"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies("")
+
+	tests := []struct {
+		name      string
+		remote    string
+		realIP    string
+		forwarded string
+		want      string
+	}{
+		{"прямой запрос", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"клиент подделывает X-Real-IP", "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7"},
+		{"клиент подделывает X-Forwarded-For", "203.0.113.7:5000", "", "198.51.100.1", "203.0.113.7"},
+		{"X-Real-IP от доверенного прокси", "10.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"подсеть доверенных прокси", "172.18.0.5:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"X-Forwarded-For от доверенного прокси", "10.0.0.1:5000", "", "198.51.100.1", "198.51.100.1"},
+		{"подделанное начало X-Forwarded-For", "10.0.0.1:5000", "", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+		{"цепочка доверенных прокси", "10.0.0.1:5000", "", "198.51.100.1, 172.18.0.5", "198.51.100.1"},
+		{"мусор в X-Forwarded-For", "10.0.0.1:5000", "", "not-an-ip", "10.0.0.1"},
+		{"мусор в X-Real-IP", "10.0.0.1:5000", "<script>", "198.51.100.1", "198.51.100.1"},
+		{"прокси без заголовков", "10.0.0.1:5000", "", "", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/documents", nil)
+			r.RemoteAddr = tt.remote
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			if got := clientIP(r); got != tt.want {
+				t.Fatalf("clientIP() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxiesRejectsInvalid(t *testing.T) {
+	defer SetTrustedProxies("")
+
+	for _, list := range []string{"10.0.0", "10.0.0.0/33", "proxy.local"} {
+		if err := SetTrustedProxies(list); err == nil {
+			t.Errorf("SetTrustedProxies(%q): ожидалась ошибка", list)
+		}
+	}
+}
@@ -2,20 +2,33 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"document-approval/models"
+	"document-approval/pkg/jwt"
+	"document-approval/services/apikey"
+	"document-approval/services/auth"
 	"document-approval/services/user"
 )
 
 type contextKey string
 
-const UserKey contextKey = "user"
+const (
+	UserKey   contextKey = "user"
+	APIKeyKey contextKey = "api_key"
+)
 
-func AuthMiddleware(userService *user.UserService) func(http.Handler) http.Handler {
+func AuthMiddleware(authService *auth.AuthService, userService *user.UserService, apiKeyService *apikey.APIKeyService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Скрипты могут передавать ключ отдельным заголовком
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				serveWithAPIKey(w, r, next, key, userService, apiKeyService)
+				return
+			}
+
 			auth := r.Header.Get("Authorization")
 			if auth == "" {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -28,22 +41,71 @@ func AuthMiddleware(userService *user.UserService) func(http.Handler) http.Handl
 				return
 			}
 
-			// В реальном приложении здесь нужно проверять JWT токен
-			// и получать из него ID пользователя
-			userID := int64(1) // Для примера
+			if apikey.IsAPIKey(parts[1]) {
+				serveWithAPIKey(w, r, next, parts[1], userService, apiKeyService)
+				return
+			}
 
-			user, err := userService.GetUserByID(userID)
+			claims, err := authService.ParseAccessToken(parts[1])
+			if errors.Is(err, jwt.ErrExpiredToken) {
+				http.Error(w, "Token expired", http.StatusUnauthorized)
+				return
+			}
 			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := userService.GetUserByID(claims.UserID)
+			if err != nil || !user.IsActive {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
+			// Роли берем из БД, а не из токена: отозванная роль перестает
+			// действовать сразу, а не по истечении срока токена
+			user.Roles, err = userService.GetUserRoles(user.ID)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
 
+			noteAuditUser(r.Context(), user, nil)
 			ctx := context.WithValue(r.Context(), UserKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, plain string, userService *user.UserService, apiKeyService *apikey.APIKeyService) {
+	key, err := apiKeyService.Authenticate(plain)
+	if err != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := userService.GetUserByID(key.UserID)
+	if err != nil || !user.IsActive {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Для ключей роли берем из БД: в ключе их нет
+	user.Roles, err = userService.GetUserRoles(user.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if !apiKeyScopeAllows(w, r, key) {
+		return
+	}
+
+	noteAuditUser(r.Context(), user, key)
+	ctx := context.WithValue(r.Context(), UserKey, user)
+	ctx = context.WithValue(ctx, APIKeyKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func GetUserFromContext(ctx context.Context) *models.User {
 	user, ok := ctx.Value(UserKey).(*models.User)
 	if !ok {
@@ -51,3 +113,33 @@ func GetUserFromContext(ctx context.Context) *models.User {
 	}
 	return user
 }
+
+// GetAPIKeyFromContext возвращает ключ, которым аутентифицирован запрос,
+// или nil для обычной сессии
+func GetAPIKeyFromContext(ctx context.Context) *models.APIKey {
+	key, ok := ctx.Value(APIKeyKey).(*models.APIKey)
+	if !ok {
+		return nil
+	}
+	return key
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// apiKeyScopeAllows проверяет изменяющий запрос по ключу: маршрут должен
+// требовать право из области действия ключа. Маршруты без права (управление
+// ключами, замещения, настройки) ключам закрыты. При отказе пишет ответ 403.
+func apiKeyScopeAllows(w http.ResponseWriter, r *http.Request, key *models.APIKey) bool {
+	if isReadOnlyMethod(r.Method) {
+		return true
+	}
+
+	perm, ok := routePermission(r)
+	if !ok || !ScopeAllows(key, perm) {
+		writeScopeForbidden(w, key, perm)
+		return false
+	}
+	return true
+}
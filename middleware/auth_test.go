@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"document-approval/models"
+
+	"github.com/gorilla/mux"
+)
+
+// newScopeTestRouter повторяет устройство роутера API: проверка ключа
+// стоит в middleware подроутера, права - на отдельных маршрутах
+func newScopeTestRouter(key *models.APIKey) *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKeyScopeAllows(w, r, key) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	})
+
+	api.HandleFunc("/documents/{id}", ok).Methods("GET")
+	api.Handle("/folders/{id}/files", RequirePermission(PermDocumentCreate)(http.HandlerFunc(ok))).Methods("POST")
+	api.Handle("/approvals/{id}/approve", RequirePermission(PermApprovalDecide)(http.HandlerFunc(ok))).Methods("POST")
+	api.Handle("/users", RequirePermission(PermUsersManage)(http.HandlerFunc(ok))).Methods("POST")
+	api.HandleFunc("/delegations", ok).Methods("POST")
+	api.HandleFunc("/api-keys/{id}", ok).Methods("DELETE")
+	api.HandleFunc("/notification-preferences", ok).Methods("PUT")
+	api.HandleFunc("/notifications/read-all", ok).Methods("POST")
+	return r
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	routes := []struct {
+		method, path string
+		allowed      map[string]bool
+	}{
+		{"GET", "/api/documents/1", map[string]bool{
+			models.APIKeyScopeRead: true, models.APIKeyScopeUpload: true, models.APIKeyScopeApprove: true}},
+		{"POST", "/api/folders/1/files", map[string]bool{models.APIKeyScopeUpload: true}},
+		{"POST", "/api/approvals/1/approve", map[string]bool{models.APIKeyScopeApprove: true}},
+		{"POST", "/api/users", nil},
+		{"POST", "/api/delegations", nil},
+		{"DELETE", "/api/api-keys/1", nil},
+		{"PUT", "/api/notification-preferences", nil},
+		{"POST", "/api/notifications/read-all", nil},
+	}
+	scopes := []string{models.APIKeyScopeRead, models.APIKeyScopeUpload, models.APIKeyScopeApprove}
+
+	for _, rt := range routes {
+		for _, scope := range scopes {
+			t.Run(rt.method+" "+rt.path+"/"+scope, func(t *testing.T) {
+				key := &models.APIKey{ID: 1, Scopes: []string{scope}}
+				user := &models.User{ID: 1, Roles: []string{string(models.RoleAdmin)}}
+
+				req := httptest.NewRequest(rt.method, rt.path, nil)
+				req = req.WithContext(withUser(req, user, key))
+				rec := httptest.NewRecorder()
+				newScopeTestRouter(key).ServeHTTP(rec, req)
+
+				if rt.allowed[scope] {
+					if rec.Code != http.StatusNoContent {
+						t.Fatalf("ожидался доступ, получен %d: %s", rec.Code, rec.Body.String())
+					}
+					return
+				}
+				if rec.Code != http.StatusForbidden {
+					t.Fatalf("ожидался 403, получен %d", rec.Code)
+				}
+			})
+		}
+	}
+}
+
+func withUser(r *http.Request, user *models.User, key *models.APIKey) context.Context {
+	ctx := context.WithValue(r.Context(), UserKey, user)
+	return context.WithValue(ctx, APIKeyKey, key)
+}
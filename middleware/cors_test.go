@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORSPreflightAllowsStreamHeaders(t *testing.T) {
+	called := false
+	h := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	// Такой preflight браузер отправляет перед переподключением к потоку событий
+	req := httptest.NewRequest(http.MethodOptions, "/api/events/stream", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "authorization,last-event-id")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || called {
+		t.Fatalf("preflight: код %d, обработчик вызван: %v", rec.Code, called)
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(rec.Header().Get("Access-Control-Allow-Headers"), ",") {
+		allowed[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, name := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if !allowed[name] {
+			t.Errorf("заголовок %s не разрешен: %q", name, rec.Header().Get("Access-Control-Allow-Headers"))
+		}
+	}
+}
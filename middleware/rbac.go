@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net/http"
+
+	"document-approval/api/response"
+	"document-approval/models"
+
+	"github.com/gorilla/mux"
+)
+
+type Permission string
+
+const (
+	PermFolderCreate   Permission = "folder:create"
+	PermFolderEdit     Permission = "folder:edit"
+	PermFolderDelete   Permission = "folder:delete"
+	PermDocumentCreate Permission = "document:create"
+	PermDocumentEdit   Permission = "document:edit"
+	PermApprovalStart  Permission = "approval:start"
+	PermApprovalDecide Permission = "approval:decide"
+	PermApprovalManage Permission = "approval:manage"
+	PermUsersManage    Permission = "users:manage"
+	PermAuditView      Permission = "audit:view"
+	PermWebhooksManage Permission = "webhooks:manage"
+)
+
+// RolePermissions - таблица прав ролей. Администратору разрешено все.
+var RolePermissions = map[models.Role][]Permission{
+	models.RoleApprover: {
+		PermApprovalDecide,
+	},
+	models.RoleEmployee: {
+		PermFolderCreate,
+		PermFolderEdit,
+		PermDocumentCreate,
+		PermDocumentEdit,
+		PermApprovalStart,
+	},
+}
+
+// ScopePermissions - права, которые открывает область действия API ключа.
+// Итоговое право ключа - пересечение с правами ролей владельца. Читать
+// (GET) может ключ с любой областью, а изменять данные - только на
+// маршрутах, право которых есть в этой таблице.
+var ScopePermissions = map[string][]Permission{
+	models.APIKeyScopeUpload: {
+		PermDocumentCreate,
+	},
+	models.APIKeyScopeApprove: {
+		PermApprovalDecide,
+	},
+}
+
+// ScopeAllows проверяет, разрешает ли ключ указанное право
+func ScopeAllows(key *models.APIKey, perm Permission) bool {
+	for _, scope := range key.Scopes {
+		for _, p := range ScopePermissions[scope] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermission проверяет, дает ли хотя бы одна из ролей пользователя указанное право
+func HasPermission(user *models.User, perm Permission) bool {
+	if user == nil {
+		return false
+	}
+
+	for _, role := range user.Roles {
+		if models.Role(role) == models.RoleAdmin {
+			return true
+		}
+		for _, p := range RolePermissions[models.Role(role)] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RequirePermission пропускает запрос дальше, только если у пользователя есть право.
+// Должен стоять после AuthMiddleware.
+func RequirePermission(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return permissionHandler{perm: perm, next: next}
+	}
+}
+
+// permissionHandler - хендлер маршрута, требующего права. По его типу
+// AuthMiddleware узнает право маршрута при проверке API ключа.
+type permissionHandler struct {
+	perm Permission
+	next http.Handler
+}
+
+func (h permissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		response.ErrorWithCode(w, http.StatusUnauthorized, "unauthorized", "Пользователь не авторизован")
+		return
+	}
+
+	if key := GetAPIKeyFromContext(r.Context()); key != nil && !ScopeAllows(key, h.perm) {
+		writeScopeForbidden(w, key, h.perm)
+		return
+	}
+
+	if !HasPermission(user, h.perm) {
+		response.JSON(w, http.StatusForbidden, response.Response{
+			Success: false,
+			Error:   "Недостаточно прав для выполнения операции",
+			Code:    "forbidden",
+			Details: map[string]interface{}{
+				"permission": h.perm,
+				"roles":      user.Roles,
+			},
+		})
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// routePermission возвращает право, которое требует маршрут запроса.
+// ok = false, если маршрут не защищен RequirePermission.
+func routePermission(r *http.Request) (perm Permission, ok bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	h, ok := route.GetHandler().(permissionHandler)
+	if !ok {
+		return "", false
+	}
+	return h.perm, true
+}
+
+func writeScopeForbidden(w http.ResponseWriter, key *models.APIKey, perm Permission) {
+	details := map[string]interface{}{
+		"scopes": key.Scopes,
+	}
+	if perm != "" {
+		details["permission"] = perm
+	}
+	response.JSON(w, http.StatusForbidden, response.Response{
+		Success: false,
+		Error:   "Область действия API ключа не позволяет выполнить операцию",
+		Code:    "forbidden_scope",
+		Details: details,
+	})
+}
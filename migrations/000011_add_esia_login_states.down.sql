@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_esia_id;
+ALTER TABLE users DROP COLUMN IF EXISTS esia_id;
+
+DROP TABLE IF EXISTS esia_login_states;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS esia_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_esia_login_states_expires_at ON esia_login_states(expires_at);
+
+-- Привязка пользователя к учетной записи ЕСИА
+ALTER TABLE users ADD COLUMN IF NOT EXISTS esia_id VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_esia_id ON users(esia_id) WHERE esia_id IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_roles_role;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS deactivated_at;
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS position VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles(role);
@@ -0,0 +1,5 @@
+ALTER TABLE approvers
+    DROP COLUMN IF EXISTS acted_by,
+    DROP COLUMN IF EXISTS reassigned_from;
+
+DROP TABLE IF EXISTS approval_delegations;
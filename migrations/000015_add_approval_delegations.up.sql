@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS approval_delegations (
+    id SERIAL PRIMARY KEY,
+    delegator_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    substitute_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at),
+    CHECK (delegator_id <> substitute_id)
+);
+
+CREATE INDEX idx_approval_delegations_delegator ON approval_delegations(delegator_id);
+CREATE INDEX idx_approval_delegations_substitute ON approval_delegations(substitute_id);
+
+-- Кто фактически подписал решение (сам утверждающий или его заместитель)
+-- и от кого было переназначено согласование
+ALTER TABLE approvers
+    ADD COLUMN IF NOT EXISTS acted_by INTEGER,
+    ADD COLUMN IF NOT EXISTS reassigned_from INTEGER;
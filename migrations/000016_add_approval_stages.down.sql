@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_approvers_process_stage;
+
+ALTER TABLE approvers DROP COLUMN IF EXISTS stage;
+ALTER TABLE approval_processes DROP COLUMN IF EXISTS current_stage;
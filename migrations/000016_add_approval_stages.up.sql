@@ -0,0 +1,4 @@
+ALTER TABLE approval_processes ADD COLUMN IF NOT EXISTS current_stage INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE approvers ADD COLUMN IF NOT EXISTS stage INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_approvers_process_stage ON approvers(process_id, stage);
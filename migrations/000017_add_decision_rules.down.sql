@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS approval_stages;
+
+ALTER TABLE approval_processes
+    DROP COLUMN IF EXISTS decision_rule,
+    DROP COLUMN IF EXISTS quorum;
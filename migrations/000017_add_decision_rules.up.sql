@@ -0,0 +1,19 @@
+ALTER TABLE approval_processes
+    ADD COLUMN IF NOT EXISTS decision_rule VARCHAR(20) NOT NULL DEFAULT 'unanimous',
+    ADD COLUMN IF NOT EXISTS quorum INTEGER;
+
+-- Правило принятия решения для каждого этапа процесса
+CREATE TABLE IF NOT EXISTS approval_stages (
+    id SERIAL PRIMARY KEY,
+    process_id INTEGER NOT NULL REFERENCES approval_processes(id) ON DELETE CASCADE,
+    stage INTEGER NOT NULL,
+    decision_rule VARCHAR(20) NOT NULL DEFAULT 'unanimous',
+    quorum INTEGER,
+    UNIQUE (process_id, stage)
+);
+
+-- Для уже запущенных процессов сохраняем прежнее поведение (единогласие)
+INSERT INTO approval_stages (process_id, stage, decision_rule)
+SELECT DISTINCT process_id, stage, 'unanimous' FROM approvers
+WHERE process_id IS NOT NULL
+ON CONFLICT (process_id, stage) DO NOTHING;
@@ -0,0 +1,6 @@
+ALTER TABLE approval_processes
+    DROP COLUMN IF EXISTS template_id,
+    DROP COLUMN IF EXISTS template_version;
+
+DROP TABLE IF EXISTS route_template_versions;
+DROP TABLE IF EXISTS route_templates;
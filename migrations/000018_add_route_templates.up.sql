@@ -0,0 +1,29 @@
+-- Шаблоны маршрутов согласования для типов документов
+CREATE TABLE IF NOT EXISTS route_templates (
+    id SERIAL PRIMARY KEY,
+    document_type VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    current_version INTEGER NOT NULL DEFAULT 1,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Для типа документа действует не больше одного шаблона
+CREATE UNIQUE INDEX IF NOT EXISTS idx_route_templates_document_type
+    ON route_templates(document_type) WHERE is_active;
+
+-- Каждое изменение шаблона сохраняется новой версией, старые версии не меняются
+CREATE TABLE IF NOT EXISTS route_template_versions (
+    id SERIAL PRIMARY KEY,
+    template_id INTEGER NOT NULL REFERENCES route_templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (template_id, version)
+);
+
+ALTER TABLE approval_processes
+    ADD COLUMN IF NOT EXISTS template_id INTEGER REFERENCES route_templates(id),
+    ADD COLUMN IF NOT EXISTS template_version INTEGER;
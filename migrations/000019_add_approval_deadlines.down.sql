@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS approval_history;
+
+DROP INDEX IF EXISTS idx_approvers_pending_due;
+
+ALTER TABLE approvers
+    DROP COLUMN IF EXISTS due_at,
+    DROP COLUMN IF EXISTS reminded_at,
+    DROP COLUMN IF EXISTS escalated_at;
+
+ALTER TABLE approval_stages DROP COLUMN IF EXISTS due_hours;
+
+ALTER TABLE users DROP COLUMN IF EXISTS manager_id;
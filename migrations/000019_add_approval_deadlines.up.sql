@@ -0,0 +1,33 @@
+-- Руководитель, которому эскалируются просроченные решения
+ALTER TABLE users ADD COLUMN IF NOT EXISTS manager_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- Срок этапа в часах с момента открытия; NULL - срок документа
+ALTER TABLE approval_stages ADD COLUMN IF NOT EXISTS due_hours INTEGER;
+
+ALTER TABLE approvers
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_approvers_pending_due ON approvers(due_at) WHERE status = 'Ожидает';
+
+-- Для уже открытых этапов срок берем из документа
+UPDATE approvers a
+SET due_at = d.deadline_date
+FROM approval_processes ap
+JOIN documents d ON d.id = ap.document_id
+WHERE a.process_id = ap.id AND a.status = 'Ожидает'
+  AND ap.status = 'В процессе' AND a.stage = ap.current_stage;
+
+-- История процесса согласования: напоминания, эскалации и другие события
+CREATE TABLE IF NOT EXISTS approval_history (
+    id SERIAL PRIMARY KEY,
+    process_id INTEGER NOT NULL REFERENCES approval_processes(id) ON DELETE CASCADE,
+    approver_id INTEGER REFERENCES approvers(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL,
+    actor_id INTEGER REFERENCES users(id),
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_history_process ON approval_history(process_id, created_at);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_approval_processes_document;
+
+ALTER TABLE approval_processes
+    DROP COLUMN IF EXISTS initiated_by,
+    DROP COLUMN IF EXISTS round,
+    DROP COLUMN IF EXISTS previous_process_id,
+    DROP COLUMN IF EXISTS canceled_at,
+    DROP COLUMN IF EXISTS canceled_by,
+    DROP COLUMN IF EXISTS cancel_reason;
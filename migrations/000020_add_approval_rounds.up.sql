@@ -0,0 +1,18 @@
+ALTER TABLE approval_processes
+    ADD COLUMN IF NOT EXISTS initiated_by INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS round INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS previous_process_id INTEGER REFERENCES approval_processes(id),
+    ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS canceled_by INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_approval_processes_document ON approval_processes(document_id, round);
+
+-- Нумеруем круги уже существующих процессов по порядку создания
+UPDATE approval_processes ap
+SET round = r.round
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY document_id ORDER BY created_at, id) AS round
+    FROM approval_processes
+) r
+WHERE r.id = ap.id;
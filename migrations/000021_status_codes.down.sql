@@ -0,0 +1,27 @@
+UPDATE documents SET status = CASE status
+    WHEN 'draft' THEN 'Черновик'
+    WHEN 'in_review' THEN 'На утверждении'
+    WHEN 'approved' THEN 'Утвержден'
+    WHEN 'rejected' THEN 'Отклонен'
+    ELSE status
+END;
+
+ALTER TABLE documents ALTER COLUMN status SET DEFAULT 'Черновик';
+
+UPDATE approval_processes SET status = CASE status
+    WHEN 'in_progress' THEN 'В процессе'
+    WHEN 'completed' THEN 'Завершен'
+    WHEN 'canceled' THEN 'Отменен'
+    ELSE status
+END;
+
+UPDATE approvers SET status = CASE status
+    WHEN 'pending' THEN 'Ожидает'
+    WHEN 'approved' THEN 'Утверждено'
+    WHEN 'rejected' THEN 'Отклонено'
+    WHEN 'skipped' THEN 'Пропущено'
+    ELSE status
+END;
+
+DROP INDEX IF EXISTS idx_approvers_pending_due;
+CREATE INDEX idx_approvers_pending_due ON approvers(due_at) WHERE status = 'Ожидает';
@@ -0,0 +1,29 @@
+-- Статусы хранятся кодами, подписи отдает API (/api/statuses)
+UPDATE documents SET status = CASE status
+    WHEN 'Черновик' THEN 'draft'
+    WHEN 'Рассматривается' THEN 'in_review'
+    WHEN 'На утверждении' THEN 'in_review'
+    WHEN 'Утвержден' THEN 'approved'
+    WHEN 'Отклонен' THEN 'rejected'
+    ELSE status
+END;
+
+ALTER TABLE documents ALTER COLUMN status SET DEFAULT 'draft';
+
+UPDATE approval_processes SET status = CASE status
+    WHEN 'В процессе' THEN 'in_progress'
+    WHEN 'Завершен' THEN 'completed'
+    WHEN 'Отменен' THEN 'canceled'
+    ELSE status
+END;
+
+UPDATE approvers SET status = CASE status
+    WHEN 'Ожидает' THEN 'pending'
+    WHEN 'Утверждено' THEN 'approved'
+    WHEN 'Отклонено' THEN 'rejected'
+    WHEN 'Пропущено' THEN 'skipped'
+    ELSE status
+END;
+
+DROP INDEX IF EXISTS idx_approvers_pending_due;
+CREATE INDEX idx_approvers_pending_due ON approvers(due_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS document_events;
+DROP FUNCTION IF EXISTS document_events_append_only();
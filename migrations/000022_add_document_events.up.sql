@@ -0,0 +1,44 @@
+-- История документа: только добавление, события пишутся в транзакциях изменений
+CREATE TABLE IF NOT EXISTS document_events (
+    id BIGSERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL REFERENCES documents(id),
+    event_type VARCHAR(50) NOT NULL,
+    actor_id INTEGER REFERENCES users(id),
+    process_id INTEGER REFERENCES approval_processes(id),
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_events_document ON document_events(document_id, created_at, id);
+
+CREATE OR REPLACE FUNCTION document_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'document_events допускает только добавление записей';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER document_events_no_change
+    BEFORE UPDATE OR DELETE ON document_events
+    FOR EACH ROW EXECUTE FUNCTION document_events_append_only();
+
+-- Восстанавливаем историю уже существующих документов
+INSERT INTO document_events (document_id, event_type, details, created_at)
+SELECT id, 'created', jsonb_build_object('title', title), COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM documents;
+
+INSERT INTO document_events (document_id, event_type, actor_id, process_id, details, created_at)
+SELECT ap.document_id, h.action, h.actor_id, h.process_id,
+       h.details || jsonb_build_object('approver_id', h.approver_id), h.created_at
+FROM approval_history h
+JOIN approval_processes ap ON ap.id = h.process_id;
+
+INSERT INTO document_events (document_id, event_type, actor_id, process_id, details, created_at)
+SELECT ap.document_id, 'decided', a.acted_by, a.process_id,
+       jsonb_build_object(
+           'approver_id', a.id, 'user_id', a.user_id, 'stage', a.stage,
+           'decision', a.status, 'comment', COALESCE(a.comment, '')
+       ),
+       a.approved_at
+FROM approvers a
+JOIN approval_processes ap ON ap.id = a.process_id
+WHERE a.status IN ('approved', 'rejected') AND a.approved_at IS NOT NULL;
@@ -0,0 +1,34 @@
+-- Журнал аудита изменяющих запросов. Записи связаны в цепочку хешей:
+-- удаление или правка записи обнаруживается проверкой цепочки.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    user_id INTEGER,
+    api_key_id INTEGER,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    action TEXT NOT NULL,
+    entity_type VARCHAR(50) NOT NULL DEFAULT '',
+    entity_id BIGINT,
+    status_code INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log допускает только добавление записей';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_change
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,7 @@
+ALTER TABLE approvers
+    DROP COLUMN IF EXISTS signature,
+    DROP COLUMN IF EXISTS signature_payload,
+    DROP COLUMN IF EXISTS signature_file_hash,
+    DROP COLUMN IF EXISTS signer_subject,
+    DROP COLUMN IF EXISTS signer_serial,
+    DROP COLUMN IF EXISTS signed_at;
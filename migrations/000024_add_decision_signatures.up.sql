@@ -0,0 +1,8 @@
+-- Отсоединенная подпись CMS решения утверждающего и то, что было подписано
+ALTER TABLE approvers
+    ADD COLUMN IF NOT EXISTS signature BYTEA,
+    ADD COLUMN IF NOT EXISTS signature_payload TEXT,
+    ADD COLUMN IF NOT EXISTS signature_file_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS signer_subject TEXT,
+    ADD COLUMN IF NOT EXISTS signer_serial TEXT,
+    ADD COLUMN IF NOT EXISTS signed_at TIMESTAMP;
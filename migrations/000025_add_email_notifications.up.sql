@@ -0,0 +1,30 @@
+-- Настройки уведомлений пользователя. Нет строки - значения по умолчанию.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    language VARCHAR(2) NOT NULL DEFAULT 'ru',
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    assigned BOOLEAN NOT NULL DEFAULT TRUE,
+    reminder BOOLEAN NOT NULL DEFAULT TRUE,
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    rejected BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Очередь писем. Письмо ставится в очередь в транзакции события,
+-- а отправляется отдельно, поэтому сбой SMTP не откатывает событие.
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    event VARCHAR(50) NOT NULL,
+    language VARCHAR(2) NOT NULL,
+    recipient TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_pending ON email_outbox(next_attempt_at) WHERE status IN ('pending', 'sending');
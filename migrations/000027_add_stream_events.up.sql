@@ -0,0 +1,13 @@
+-- События для потока обновлений клиента (SSE). ID события - позиция в потоке,
+-- по ней переподключившийся клиент получает пропущенное (Last-Event-ID).
+-- user_id IS NULL - событие для всех пользователей.
+CREATE TABLE IF NOT EXISTS stream_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stream_events_user ON stream_events(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_stream_events_created_at ON stream_events(created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE approval_processes DROP COLUMN IF EXISTS document_version;
+ALTER TABLE documents DROP COLUMN IF EXISTS current_version;
+DROP TABLE IF EXISTS document_versions;
@@ -0,0 +1,43 @@
+-- Версии файла документа. documents.file_path и file_content - копия текущей
+-- версии, чтобы поиск и прежние запросы продолжали работать.
+CREATE TABLE IF NOT EXISTS document_versions (
+    id SERIAL PRIMARY KEY,
+    document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    file_path TEXT NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    checksum VARCHAR(64),
+    size BIGINT,
+    file_content TEXT,
+    comment TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (document_id, version)
+);
+
+ALTER TABLE documents
+    ADD COLUMN IF NOT EXISTS current_version INTEGER;
+
+-- Версия документа, которую согласует процесс
+ALTER TABLE approval_processes
+    ADD COLUMN IF NOT EXISTS document_version INTEGER;
+
+-- Текущий файл существующих документов становится версией 1. Контрольная
+-- сумма таких версий неизвестна, автор берется из истории документа.
+INSERT INTO document_versions (document_id, version, file_path, file_name, file_content, created_by, created_at)
+SELECT d.id, 1, d.file_path, regexp_replace(d.file_path, '^.*/', ''), d.file_content,
+       (SELECT e.actor_id FROM document_events e
+        WHERE e.document_id = d.id AND e.event_type = 'created'
+        ORDER BY e.id LIMIT 1),
+       d.created_at
+FROM documents d
+WHERE COALESCE(d.file_path, '') <> ''
+ON CONFLICT (document_id, version) DO NOTHING;
+
+UPDATE documents d SET current_version = 1
+WHERE d.current_version IS NULL
+  AND EXISTS (SELECT 1 FROM document_versions v WHERE v.document_id = d.id);
+
+UPDATE approval_processes ap SET document_version = 1
+WHERE ap.document_version IS NULL
+  AND EXISTS (SELECT 1 FROM document_versions v WHERE v.document_id = ap.document_id);
@@ -8,18 +8,135 @@ const (
 	RoleApprover Role = "approver"
 	RoleEmployee Role = "employee"
 
-	// Статусы документов
-	StatusDraft    = "Черновик"
-	StatusInReview = "Рассматривается"
-	StatusApproved = "Утвержден"
-	StatusRejected = "Отклонен"
+	// Статусы документов. В БД и API хранятся коды, подписи - в StatusLabels
+	StatusDraft    = "draft"
+	StatusInReview = "in_review"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
 
 	// Статусы процесса согласования
-	ProcessStatusInProgress = "В процессе"
-	ProcessStatusCompleted  = "Завершен"
+	ProcessStatusInProgress = "in_progress"
+	ProcessStatusCompleted  = "completed"
+	ProcessStatusCanceled   = "canceled"
 
 	// Статусы утверждающих
-	ApproverStatusPending  = "Ожидает"
-	ApproverStatusApproved = "Утверждено"
-	ApproverStatusRejected = "Отклонено"
+	ApproverStatusPending  = "pending"
+	ApproverStatusApproved = "approved"
+	ApproverStatusRejected = "rejected"
+	ApproverStatusSkipped  = "skipped"
 )
+
+// Правила принятия решения на этапе согласования
+const (
+	RuleUnanimous = "unanimous"
+	RuleMajority  = "majority"
+	RuleNOfM      = "n_of_m"
+	RuleAnyOne    = "any"
+)
+
+// Действия правил маршрутизации
+const (
+	RoutingActionAddStage  = "add_stage"
+	RoutingActionSkipStage = "skip_stage"
+)
+
+// События истории процесса согласования
+const (
+	HistoryStarted      = "started"
+	HistoryCanceled     = "canceled"
+	HistoryReminderSent = "reminder_sent"
+	HistoryEscalated    = "escalated"
+	HistoryDecided      = "decided"
+	HistoryReassigned   = "reassigned"
+)
+
+// События истории документа. События процесса согласования попадают
+// в историю документа с теми же кодами, что и в истории процесса
+const (
+	EventCreated       = "created"
+	EventEdited        = "edited"
+	EventFileReplaced  = "file_replaced"
+	EventStatusChanged = "status_changed"
+)
+
+// События, о которых пользователь получает уведомления
+const (
+	NotifyAssigned  = "assigned"  // назначено решение
+	NotifyReminder  = "reminder"  // скоро срок решения
+	NotifyCompleted = "completed" // документ инициатора утвержден
+	NotifyRejected  = "rejected"  // документ инициатора отклонен
+)
+
+// События потока обновлений клиента
+const (
+	StreamApprovalAssigned = "approval.assigned" // пользователю назначено решение
+	StreamApprovalDecided  = "approval.decided"  // решение по документу пользователя
+	StreamFolderChanged    = "folder.changed"    // изменилась папка, событие для всех
+	StreamNotification     = "notification.created"
+	StreamReload           = "stream.reload" // пропущено больше, чем отдается при переподключении
+)
+
+// Типы уведомлений в приложении
+const (
+	NotificationAssigned        = "approval_assigned"  // назначено решение
+	NotificationReminder        = "approval_reminder"  // скоро срок решения
+	NotificationDecided         = "approval_decided"   // решение по документу пользователя
+	NotificationCompleted       = "approval_completed" // согласование документа пользователя завершено
+	NotificationCanceled        = "approval_canceled"  // отменено согласование, где ждали решения пользователя
+	NotificationMentioned       = "mentioned"          // пользователя упомянули в комментарии
+	NotificationDocumentChanged = "document_changed"   // изменен документ, в согласовании которого участвовал пользователь
+	NotificationFolderFileAdded = "folder_file_added"  // в папку, где работает пользователь, загружен документ
+)
+
+// События, на которые подписываются вебхуки
+const (
+	WebhookDocumentCreated   = "document.created"
+	WebhookDocumentUpdated   = "document.updated"
+	WebhookApprovalStarted   = "approval.started"
+	WebhookApprovalDecided   = "approval.decided"
+	WebhookApprovalCompleted = "approval.completed"
+)
+
+// WebhookEvents - все события, доступные для подписки
+var WebhookEvents = []string{
+	WebhookDocumentCreated,
+	WebhookDocumentUpdated,
+	WebhookApprovalStarted,
+	WebhookApprovalDecided,
+	WebhookApprovalCompleted,
+}
+
+// ValidWebhookEvent сообщает, можно ли подписаться на событие
+func ValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Области действия API ключей
+const (
+	APIKeyScopeRead    = "read"
+	APIKeyScopeUpload  = "upload"
+	APIKeyScopeApprove = "approve"
+)
+
+// ValidAPIKeyScope сообщает, является ли значение известной областью действия ключа
+func ValidAPIKeyScope(scope string) bool {
+	switch scope {
+	case APIKeyScopeRead, APIKeyScopeUpload, APIKeyScopeApprove:
+		return true
+	}
+	return false
+}
+
+// Valid сообщает, является ли значение известной ролью
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleApprover, RoleEmployee:
+		return true
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Document struct {
 	ID             int64          `json:"id"`
@@ -22,6 +25,28 @@ type Document struct {
 	Metadata       map[string]any `json:"metadata"`
 
 	FileContent string `json:"file_content"`
+
+	// Текущая версия файла и число версий
+	CurrentVersion *int `json:"current_version,omitempty"`
+	VersionCount   int  `json:"version_count"`
+}
+
+// DocumentVersion - версия файла документа. Checksum - SHA-256 файла;
+// у версий, перенесенных из файлов до появления версий, его нет.
+type DocumentVersion struct {
+	ID            int64     `json:"id"`
+	DocumentID    int64     `json:"document_id"`
+	Version       int       `json:"version"`
+	FileName      string    `json:"file_name"`
+	Checksum      *string   `json:"checksum,omitempty"`
+	Size          *int64    `json:"size,omitempty"`
+	Comment       string    `json:"comment,omitempty"`
+	CreatedBy     *int64    `json:"created_by,omitempty"`
+	CreatedByName string    `json:"created_by_name,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	FilePath    string `json:"-"`
+	FileContent string `json:"-"`
 }
 
 type DocumentType struct {
@@ -51,12 +76,108 @@ type Option struct {
 }
 
 type ApprovalProcess struct {
-	ID         int64      `json:"id"`
-	DocumentID int64      `json:"document_id"`
-	Document   *Document  `json:"document,omitempty"` // Добавлено поле Document
-	Status     string     `json:"status"`
-	CreatedAt  time.Time  `json:"created_at"`
-	Approvers  []Approver `json:"approvers,omitempty"`
+	ID           int64      `json:"id"`
+	DocumentID   int64      `json:"document_id"`
+	Document     *Document  `json:"document,omitempty"` // Добавлено поле Document
+	Status       string     `json:"status"`
+	CurrentStage int        `json:"current_stage"`
+	DecisionRule string     `json:"decision_rule"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Approvers    []Approver `json:"approvers,omitempty"`
+
+	// Шаблон и его версия, по которым построен маршрут
+	TemplateID      *int64 `json:"template_id,omitempty"`
+	TemplateVersion *int   `json:"template_version,omitempty"`
+
+	// Круг согласования: повторная отправка после отклонения или отмены
+	// создает новый процесс, связанный с предыдущим
+	InitiatedBy       *int64     `json:"initiated_by,omitempty"`
+	Round             int        `json:"round"`
+	PreviousProcessID *int64     `json:"previous_process_id,omitempty"`
+	CanceledAt        *time.Time `json:"canceled_at,omitempty"`
+	CanceledBy        *int64     `json:"canceled_by,omitempty"`
+	CancelReason      string     `json:"cancel_reason,omitempty"`
+
+	// Версия файла документа, которую согласует процесс
+	DocumentVersion *int `json:"document_version,omitempty"`
+}
+
+// ApprovalRoute - маршрут согласования. Правило процесса применяется
+// к этапам, у которых собственное правило не задано.
+type ApprovalRoute struct {
+	DecisionRule string          `json:"decision_rule,omitempty"`
+	Quorum       int             `json:"quorum,omitempty"`
+	Stages       []ApprovalStage `json:"stages"`
+
+	// Заполняются, если маршрут построен по шаблону
+	TemplateID      *int64 `json:"template_id,omitempty"`
+	TemplateVersion int    `json:"template_version,omitempty"`
+}
+
+// ApprovalStage - этап маршрута. Этапы проходят последовательно,
+// утверждающие внутри этапа принимают решения параллельно.
+type ApprovalStage struct {
+	ApproverIDs  []int64 `json:"approver_ids"`
+	DecisionRule string  `json:"decision_rule,omitempty"`
+	Quorum       int     `json:"quorum,omitempty"`
+
+	// Срок этапа в часах с момента его открытия. Если не задан,
+	// сроком считается deadline_date документа.
+	DueHours int `json:"due_hours,omitempty"`
+}
+
+// RouteTemplate - шаблон маршрута согласования для типа документа.
+// Изменение шаблона создает новую версию, запущенные процессы остаются
+// на той версии, по которой стартовали.
+type RouteTemplate struct {
+	ID           int64                `json:"id"`
+	DocumentType string               `json:"document_type"`
+	Name         string               `json:"name"`
+	Version      int                  `json:"version"`
+	DecisionRule string               `json:"decision_rule,omitempty"`
+	Quorum       int                  `json:"quorum,omitempty"`
+	Stages       []RouteTemplateStage `json:"stages"`
+	Rules        []RoutingRule        `json:"rules,omitempty"`
+	IsActive     bool                 `json:"is_active"`
+	CreatedBy    *int64               `json:"created_by,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+// RouteTemplateStage - этап шаблона. Утверждающие этапа - перечисленные
+// пользователи и все активные пользователи с указанными ролями.
+type RouteTemplateStage struct {
+	Name         string   `json:"name,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	UserIDs      []int64  `json:"user_ids,omitempty"`
+	DecisionRule string   `json:"decision_rule,omitempty"`
+	Quorum       int      `json:"quorum,omitempty"`
+	DueHours     int      `json:"due_hours,omitempty"`
+}
+
+// RoutingRule - условное правило шаблона. Условие вычисляется по полям
+// и метаданным документа при запуске процесса; если оно истинно, правило
+// добавляет этап в маршрут или пропускает этап шаблона.
+type RoutingRule struct {
+	Name      string `json:"name,omitempty"`
+	Condition string `json:"condition"`
+	Action    string `json:"action"`
+
+	// Для skip_stage - номер пропускаемого этапа шаблона
+	Stage int `json:"stage,omitempty"`
+
+	// Для add_stage - добавляемый этап и номер этапа шаблона, после которого
+	// он встает (0 - в начало, не указан - в конец)
+	AddStage *RouteTemplateStage `json:"add_stage,omitempty"`
+	After    *int                `json:"after,omitempty"`
+}
+
+// RouteOverrides - изменения шаблона для конкретного запуска согласования.
+// Ключ Stages - номер этапа, начиная с 1.
+type RouteOverrides struct {
+	DecisionRule string                `json:"decision_rule,omitempty"`
+	Quorum       int                   `json:"quorum,omitempty"`
+	Stages       map[int]ApprovalStage `json:"stages,omitempty"`
 }
 
 type Approver struct {
@@ -64,9 +185,111 @@ type Approver struct {
 	ProcessID  int64      `json:"process_id"`
 	UserID     int64      `json:"user_id"`
 	User       *User      `json:"user,omitempty"` // Добавлено поле User
+	Stage      int        `json:"stage"`
 	Status     string     `json:"status"`
 	Comment    string     `json:"comment,omitempty"`
 	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+
+	// Кто фактически принял решение, если это был заместитель
+	ActedBy        *int64 `json:"acted_by,omitempty"`
+	ActedByUser    *User  `json:"acted_by_user,omitempty"`
+	ReassignedFrom *int64 `json:"reassigned_from,omitempty"`
+
+	// Владелец сертификата, которым подписано решение
+	SignerSubject string     `json:"signer_subject,omitempty"`
+	SignedAt      *time.Time `json:"signed_at,omitempty"`
+}
+
+// DecisionSignature - проверенная подпись решения утверждающего
+type DecisionSignature struct {
+	Signature     []byte // DER структуры CMS
+	Payload       string // подписанный текст решения
+	FileHash      string // SHA-256 файла документа в hex
+	SignerSubject string
+	SignerSerial  string
+	SignedAt      time.Time
+}
+
+// ApprovalHistoryEntry - событие в истории процесса согласования
+type ApprovalHistoryEntry struct {
+	ID         int64          `json:"id"`
+	ProcessID  int64          `json:"process_id"`
+	ApproverID *int64         `json:"approver_id,omitempty"`
+	Action     string         `json:"action"`
+	ActorID    *int64         `json:"actor_id,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// DocumentEvent - событие в истории документа
+type DocumentEvent struct {
+	ID         int64          `json:"id"`
+	DocumentID int64          `json:"document_id"`
+	EventType  string         `json:"event_type"`
+	ActorID    *int64         `json:"actor_id,omitempty"`
+	ActorName  string         `json:"actor_name,omitempty"`
+	ProcessID  *int64         `json:"process_id,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Notification - уведомление пользователя в приложении
+type Notification struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"-"`
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	DocumentID *int64         `json:"document_id,omitempty"`
+	ProcessID  *int64         `json:"process_id,omitempty"`
+	ActorID    *int64         `json:"actor_id,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+	ReadAt     *time.Time     `json:"read_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// StreamEvent - событие потока обновлений клиента
+type StreamEvent struct {
+	ID        int64          `json:"id"`
+	UserID    *int64         `json:"-"`
+	Type      string         `json:"type"`
+	Data      map[string]any `json:"data"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditEntry - запись журнала аудита изменяющего запроса к API.
+// Записи связаны в цепочку: Hash считается от PrevHash и полей записи.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	RequestID  string          `json:"request_id"`
+	UserID     *int64          `json:"user_id,omitempty"`
+	APIKeyID   *int64          `json:"api_key_id,omitempty"`
+	IP         string          `json:"ip"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   *int64          `json:"entity_id,omitempty"`
+	StatusCode int             `json:"status_code"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// Delegation - замещение утверждающего на период отсутствия
+type Delegation struct {
+	ID           int64      `json:"id"`
+	DelegatorID  int64      `json:"delegator_id"`
+	Delegator    *User      `json:"delegator,omitempty"`
+	SubstituteID int64      `json:"substitute_id"`
+	Substitute   *User      `json:"substitute,omitempty"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       time.Time  `json:"ends_at"`
+	Reason       string     `json:"reason,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 type User struct {
@@ -75,7 +298,69 @@ type User struct {
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
 	Email     string    `json:"email"`
+	Position  string    `json:"position,omitempty"`
+	ManagerID *int64    `json:"manager_id,omitempty"`
+	IsActive  bool      `json:"is_active"`
 	CreatedAt time.Time `json:"created_at"`
+	Roles     []string  `json:"roles,omitempty"`
+}
+
+// NotificationPreferences - настройки уведомлений пользователя: язык писем,
+// email в целом и отдельные события
+type NotificationPreferences struct {
+	Language     string     `json:"language"`
+	EmailEnabled bool       `json:"email_enabled"`
+	Assigned     bool       `json:"assigned"`
+	Reminder     bool       `json:"reminder"`
+	Completed    bool       `json:"completed"`
+	Rejected     bool       `json:"rejected"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// Webhook - подписка внешней системы на события. Секрет подписи
+// показывается только при создании и смене.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery - попытка доставить событие подписчику
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	EventID        string          `json:"event_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// APIKey - персональный ключ для неинтерактивного доступа к API.
+// Сам ключ показывается только при создании, в БД хранится его хеш.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
 
 // Добавим структуры для работы с папками
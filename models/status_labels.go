@@ -0,0 +1,81 @@
+package models
+
+import "strings"
+
+// Языки подписей статусов
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// StatusLabels - подписи статусов по языку и сущности. Ключи второго уровня
+// совпадают с сущностями автомата согласования: document, process, approver.
+var StatusLabels = map[string]map[string]map[string]string{
+	LangRU: {
+		"document": {
+			StatusDraft:    "Черновик",
+			StatusInReview: "На утверждении",
+			StatusApproved: "Утвержден",
+			StatusRejected: "Отклонен",
+		},
+		"process": {
+			ProcessStatusInProgress: "В процессе",
+			ProcessStatusCompleted:  "Завершен",
+			ProcessStatusCanceled:   "Отменен",
+		},
+		"approver": {
+			ApproverStatusPending:  "Ожидает",
+			ApproverStatusApproved: "Утверждено",
+			ApproverStatusRejected: "Отклонено",
+			ApproverStatusSkipped:  "Пропущено",
+		},
+	},
+	LangEN: {
+		"document": {
+			StatusDraft:    "Draft",
+			StatusInReview: "In review",
+			StatusApproved: "Approved",
+			StatusRejected: "Rejected",
+		},
+		"process": {
+			ProcessStatusInProgress: "In progress",
+			ProcessStatusCompleted:  "Completed",
+			ProcessStatusCanceled:   "Canceled",
+		},
+		"approver": {
+			ApproverStatusPending:  "Pending",
+			ApproverStatusApproved: "Approved",
+			ApproverStatusRejected: "Rejected",
+			ApproverStatusSkipped:  "Skipped",
+		},
+	},
+}
+
+// legacyDocumentStatuses - прежние русские значения статуса документа,
+// которые еще могут прийти от старых клиентов
+var legacyDocumentStatuses = map[string]string{
+	"Черновик":        StatusDraft,
+	"Рассматривается": StatusInReview,
+	"На утверждении":  StatusInReview,
+	"Утвержден":       StatusApproved,
+	"Отклонен":        StatusRejected,
+}
+
+// StatusLabelsFor возвращает подписи для языка, по умолчанию русские
+func StatusLabelsFor(lang string) map[string]map[string]string {
+	if labels, ok := StatusLabels[strings.ToLower(lang)]; ok {
+		return labels
+	}
+	return StatusLabels[LangRU]
+}
+
+// NormalizeDocumentStatus приводит код или прежнюю русскую подпись к коду статуса.
+// Второе значение false, если статус неизвестен.
+func NormalizeDocumentStatus(status string) (string, bool) {
+	status = strings.TrimSpace(status)
+	if _, ok := StatusLabels[LangRU]["document"][status]; ok {
+		return status, true
+	}
+	code, ok := legacyDocumentStatuses[status]
+	return code, ok
+}
@@ -0,0 +1,259 @@
+// Package cms проверяет отсоединенные подписи PKCS#7/CMS (SignedData).
+// Поддерживаются ключи RSA и ECDSA с хешами SHA-256, SHA-384 и SHA-512.
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	digestAlgorithms = map[string]crypto.Hash{
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+
+	signatureAlgorithms = map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+)
+
+var (
+	ErrInvalidSignature = errors.New("подпись не соответствует данным")
+	ErrUntrusted        = errors.New("сертификат подписи не выдан доверенным центром")
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// Signer - результат проверки подписи
+type Signer struct {
+	Certificate *x509.Certificate
+	SigningTime *time.Time // из подписанных атрибутов, если подписант его указал
+}
+
+// VerifyDetached проверяет отсоединенную подпись der над content.
+// Цепочка сертификата подписанта строится до корней roots; промежуточные
+// сертификаты берутся из самой подписи. Подпись должна содержать ровно
+// одного подписанта.
+func VerifyDetached(der, content []byte, roots *x509.CertPool) (*Signer, error) {
+	sd, certs, err := parse(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		return nil, fmt.Errorf("ожидается отсоединенная подпись, а подпись содержит данные")
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("подпись должна содержать одного подписанта, а содержит %d", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+
+	cert, err := signerCertificate(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := digestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый алгоритм хеширования %s", si.DigestAlgorithm.Algorithm)
+	}
+	algorithm, ok := signatureAlgorithms[cert.PublicKeyAlgorithm][hash]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый тип ключа подписи %s", cert.PublicKeyAlgorithm)
+	}
+
+	signer := &Signer{Certificate: cert}
+
+	// Без подписанных атрибутов подписываются сами данные, с ними - атрибуты,
+	// в которых лежит хеш данных
+	signed := content
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		digest, signingTime, err := readSignedAttrs(si.SignedAttrs.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		h := hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return nil, ErrInvalidSignature
+		}
+		signer.SigningTime = signingTime
+
+		// Подпись считается над атрибутами в кодировке SET OF, а не [0] IMPLICIT
+		signed = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+	}
+
+	if err := cert.CheckSignature(algorithm, signed, si.Signature); err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != cert {
+			intermediates.AddCert(c)
+		}
+	}
+	// Время подписи указывает сам подписант, поэтому срок действия
+	// сертификата проверяется на текущий момент
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUntrusted, err)
+	}
+
+	return signer, nil
+}
+
+func parse(der []byte) (*signedData, []*x509.Certificate, error) {
+	var ci contentInfo
+	rest, err := asn1.Unmarshal(der, &ci)
+	if err != nil || len(rest) > 0 {
+		return nil, nil, fmt.Errorf("подпись не является структурой CMS")
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("подпись не является SignedData")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, nil, fmt.Errorf("ошибка разбора SignedData: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidData) {
+		return nil, nil, fmt.Errorf("неподдерживаемый тип подписанных данных %s", sd.EncapContentInfo.EContentType)
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка разбора сертификатов подписи: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("подпись не содержит сертификат подписанта")
+	}
+
+	return &sd, certs, nil
+}
+
+// signerCertificate находит сертификат подписанта по издателю и серийному
+// номеру или по идентификатору ключа
+func signerCertificate(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("ошибка разбора идентификатора подписанта: %w", err)
+		}
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+				return c, nil
+			}
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("в подписи нет сертификата подписанта")
+}
+
+// readSignedAttrs проверяет тип содержимого и возвращает хеш данных и время подписи
+func readSignedAttrs(raw []byte) ([]byte, *time.Time, error) {
+	var digest []byte
+	var signingTime *time.Time
+	contentTypeOK := false
+
+	for rest := raw; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return nil, nil, fmt.Errorf("ошибка разбора подписанных атрибутов: %w", err)
+		}
+
+		switch {
+		case attr.Type.Equal(oidContentType):
+			var oid asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &oid); err != nil {
+				return nil, nil, fmt.Errorf("ошибка разбора типа содержимого: %w", err)
+			}
+			contentTypeOK = oid.Equal(oidData)
+		case attr.Type.Equal(oidMessageDigest):
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+				return nil, nil, fmt.Errorf("ошибка разбора хеша данных: %w", err)
+			}
+		case attr.Type.Equal(oidSigningTime):
+			var t time.Time
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &t); err == nil {
+				signingTime = &t
+			}
+		}
+	}
+
+	if !contentTypeOK || digest == nil {
+		return nil, nil, fmt.Errorf("в подписанных атрибутах нет типа содержимого или хеша данных")
+	}
+	return digest, signingTime, nil
+}
@@ -0,0 +1,124 @@
+package cms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"document-approval/pkg/cms/cmstest"
+)
+
+func TestVerifyDetached(t *testing.T) {
+	root := cmstest.NewAuthority(t, "Тестовый УЦ")
+	intermediate := root.Intermediate(t, "Тестовый подчиненный УЦ")
+	other := cmstest.NewAuthority(t, "Чужой УЦ")
+
+	content := []byte("document-approval decision v1\nprocess: 1\n")
+	signer := root.Issue(t, "ivanov@pomau.ru")
+
+	tests := []struct {
+		name        string
+		signature   []byte
+		content     []byte
+		roots       *cmstest.Authority
+		wantErr     error
+		wantTime    bool
+		wantSubject string
+	}{
+		{
+			name:        "подписанные атрибуты",
+			signature:   signer.Sign(t, content, true),
+			content:     content,
+			roots:       root,
+			wantTime:    true,
+			wantSubject: "ivanov@pomau.ru",
+		},
+		{
+			name:        "подпись самих данных",
+			signature:   signer.Sign(t, content, false),
+			content:     content,
+			roots:       root,
+			wantSubject: "ivanov@pomau.ru",
+		},
+		{
+			name:        "цепочка через промежуточный центр",
+			signature:   intermediate.Issue(t, "petrov@pomau.ru").Sign(t, content, true),
+			content:     content,
+			roots:       root,
+			wantTime:    true,
+			wantSubject: "petrov@pomau.ru",
+		},
+		{
+			name:      "измененные данные с атрибутами",
+			signature: signer.Sign(t, content, true),
+			content:   []byte("document-approval decision v1\nprocess: 2\n"),
+			roots:     root,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "измененные данные без атрибутов",
+			signature: signer.Sign(t, content, false),
+			content:   []byte("document-approval decision v1\nprocess: 2\n"),
+			roots:     root,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "недоверенный корень",
+			signature: other.Issue(t, "ivanov@pomau.ru").Sign(t, content, true),
+			content:   content,
+			roots:     root,
+			wantErr:   ErrUntrusted,
+		},
+		{
+			name:      "промежуточный центр чужого корня",
+			signature: other.Intermediate(t, "Чужой подчиненный УЦ").Issue(t, "ivanov@pomau.ru").Sign(t, content, false),
+			content:   content,
+			roots:     root,
+			wantErr:   ErrUntrusted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyDetached(tt.signature, tt.content, tt.roots.Pool())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyDetached: %v", err)
+			}
+			if got.Certificate.Subject.CommonName != tt.wantSubject {
+				t.Errorf("подписант %q, ожидался %q", got.Certificate.Subject.CommonName, tt.wantSubject)
+			}
+			if tt.wantTime != (got.SigningTime != nil) {
+				t.Errorf("время подписи %v, ожидалось наличие: %v", got.SigningTime, tt.wantTime)
+			}
+			if got.SigningTime != nil && time.Since(*got.SigningTime) > time.Minute {
+				t.Errorf("неверное время подписи %v", got.SigningTime)
+			}
+		})
+	}
+}
+
+func TestVerifyDetachedRejectsMalformed(t *testing.T) {
+	root := cmstest.NewAuthority(t, "Тестовый УЦ")
+	signature := root.Issue(t, "ivanov@pomau.ru").Sign(t, []byte("данные"), true)
+
+	tests := map[string][]byte{
+		"пустая подпись":       nil,
+		"не ASN.1":             []byte("not a signature"),
+		"обрезанная подпись":   signature[:len(signature)/2],
+		"лишние байты в конце": append(append([]byte{}, signature...), 0),
+	}
+
+	for name, der := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := VerifyDetached(der, []byte("данные"), root.Pool()); err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+		})
+	}
+}
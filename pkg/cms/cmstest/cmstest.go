@@ -0,0 +1,230 @@
+// Package cmstest выпускает тестовые сертификаты и создает ими отсоединенные
+// подписи CMS для проверки кода, который принимает подписи.
+package cmstest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSAWithSHA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// Authority - удостоверяющий центр с ключом ECDSA P-256
+type Authority struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	serial      int64
+}
+
+// Signer - сертификат подписанта с ключом и цепочкой до корня без самого корня
+type Signer struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// NewAuthority выпускает самоподписанный корневой сертификат
+func NewAuthority(tb testing.TB, name string) *Authority {
+	tb.Helper()
+
+	key := newKey(tb)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return &Authority{Certificate: createCertificate(tb, template, template, key, key), key: key, serial: 1}
+}
+
+// Pool возвращает пул из одного корневого сертификата центра
+func (a *Authority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.Certificate)
+	return pool
+}
+
+// Intermediate выпускает промежуточный центр, подчиненный a
+func (a *Authority) Intermediate(tb testing.TB, name string) *Authority {
+	tb.Helper()
+
+	key := newKey(tb)
+	template := &x509.Certificate{
+		SerialNumber:          a.nextSerial(),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return &Authority{Certificate: createCertificate(tb, template, a.Certificate, key, a.key), key: key}
+}
+
+// Issue выпускает сертификат подписи на email. Если центр промежуточный,
+// в цепочку подписанта попадает его сертификат.
+func (a *Authority) Issue(tb testing.TB, email string) *Signer {
+	tb.Helper()
+
+	key := newKey(tb)
+	template := &x509.Certificate{
+		SerialNumber:   a.nextSerial(),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(24 * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	signer := &Signer{Certificate: createCertificate(tb, template, a.Certificate, key, a.key), key: key}
+	if !isSelfSigned(a.Certificate) {
+		signer.Chain = []*x509.Certificate{a.Certificate}
+	}
+	return signer
+}
+
+func (a *Authority) nextSerial() *big.Int {
+	a.serial++
+	return big.NewInt(a.serial)
+}
+
+// Sign создает отсоединенную подпись SignedData над content. С signedAttrs
+// подписываются атрибуты с типом содержимого, хешем данных и временем подписи,
+// без них - сами данные.
+func (s *Signer) Sign(tb testing.TB, content []byte, signedAttrs bool) []byte {
+	tb.Helper()
+
+	sid, err := asn1.Marshal(struct {
+		Issuer asn1.RawValue
+		Serial *big.Int
+	}{asn1.RawValue{FullBytes: s.Certificate.RawIssuer}, s.Certificate.SerialNumber})
+	if err != nil {
+		tb.Fatalf("cmstest: идентификатор подписанта: %v", err)
+	}
+
+	signed := content
+	var attrs asn1.RawValue
+	if signedAttrs {
+		digest := sha256.Sum256(content)
+		set := bytes.Join([][]byte{
+			attribute(tb, oidContentType, oidData),
+			attribute(tb, oidMessageDigest, digest[:]),
+			attribute(tb, oidSigningTime, time.Now().UTC()),
+		}, nil)
+		attrs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: set}
+		signed = mustMarshal(tb, asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: set})
+	}
+
+	hash := sha256.Sum256(signed)
+	signature, err := s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		tb.Fatalf("cmstest: подпись: %v", err)
+	}
+
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{s.Certificate}, s.Chain...) {
+		certs = append(certs, c.Raw...)
+	}
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	sd := struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+		EncapContentInfo struct{ EContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue
+		SignerInfos      []signerInfo `asn1:"set"`
+	}{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256Alg,
+			SignedAttrs:        attrs,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA},
+			Signature:          signature,
+		}},
+	}
+	sd.EncapContentInfo.EContentType = oidData
+
+	return mustMarshal(tb, struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(tb, sd)},
+	})
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+func attribute(tb testing.TB, oid asn1.ObjectIdentifier, value any) []byte {
+	return mustMarshal(tb, struct {
+		Type   asn1.ObjectIdentifier
+		Values asn1.RawValue
+	}{oid, asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(tb, value)}})
+}
+
+func mustMarshal(tb testing.TB, v any) []byte {
+	tb.Helper()
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		tb.Fatalf("cmstest: кодирование ASN.1: %v", err)
+	}
+	return der
+}
+
+func newKey(tb testing.TB) *ecdsa.PrivateKey {
+	tb.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatalf("cmstest: генерация ключа: %v", err)
+	}
+	return key
+}
+
+func createCertificate(tb testing.TB, template, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	tb.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		tb.Fatalf("cmstest: выпуск сертификата: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		tb.Fatalf("cmstest: разбор сертификата: %v", err)
+	}
+	return cert
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(cert) == nil
+}
@@ -0,0 +1,13 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// URLFromEnv формирует строку подключения из переменных окружения DB_*
+func URLFromEnv() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"))
+}
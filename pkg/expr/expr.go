@@ -0,0 +1,613 @@
+// Package expr - небольшой язык условий для маршрутизации согласований.
+//
+// Примеры выражений:
+//
+//	metadata.income > 1000000 || metadata.expenses > 1000000
+//	document_type == "financial_report" && kopuk in [1, 2, 3]
+//	!(founder contains "Министерство")
+//
+// Поддерживаются числа, строки в двойных кавычках, true/false/null, списки,
+// операторы == != > >= < <= in contains, логические && || ! и скобки.
+// Переменные с точкой (metadata.income) обращаются к вложенным полям.
+// Отсутствующая переменная равна null, сравнение null с числом ложно.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr - разобранное выражение
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse разбирает выражение и проверяет синтаксис
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("неожиданный токен %q в позиции %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// String возвращает исходный текст выражения
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval вычисляет выражение на наборе переменных. Результат должен быть логическим.
+func (e *Expr) Eval(env map[string]any) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("результат выражения не логический: %v", v)
+	}
+	return b, nil
+}
+
+// Identifiers возвращает имена переменных, используемых в выражении
+func (e *Expr) Identifiers() []string {
+	seen := make(map[string]bool)
+	var names []string
+	walk(e.root, func(n node) {
+		if id, ok := n.(identNode); ok && !seen[id.name] {
+			seen[id.name] = true
+			names = append(names, id.name)
+		}
+	})
+	return names
+}
+
+// Lookup возвращает значение переменной с точками (metadata.income) из набора
+func Lookup(env map[string]any, name string) any {
+	var current any = env
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// --- Лексер ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(src) {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+			continue
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+			continue
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+			continue
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+			continue
+		case c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("незакрытая строка в позиции %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, sb.String(), start})
+			continue
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1]) && !prevIsValue(tokens)):
+			start := i
+			i++
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			word := src[start:i]
+			switch word {
+			case "and":
+				tokens = append(tokens, token{tokOp, "&&", start})
+			case "or":
+				tokens = append(tokens, token{tokOp, "||", start})
+			case "not":
+				tokens = append(tokens, token{tokOp, "!", start})
+			case "in", "contains":
+				tokens = append(tokens, token{tokOp, word, start})
+			default:
+				tokens = append(tokens, token{tokIdent, word, start})
+			}
+			continue
+		}
+
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(src[i:], op) {
+				tokens = append(tokens, token{tokOp, op, i})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("неожиданный символ %q в позиции %d", c, i)
+		}
+	}
+
+	tokens = append(tokens, token{tokEOF, "", len(src)})
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// prevIsValue отличает бинарный минус от знака числа
+func prevIsValue(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokNumber, tokString, tokIdent, tokRParen, tokRBracket:
+		return true
+	}
+	return false
+}
+
+// --- Парсер ---
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind != tokOp {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", ">", ">=", "<", "<=", "in", "contains":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверное число %q в позиции %d", t.text, t.pos)
+		}
+		return literalNode{value: n}, nil
+	case tokString:
+		return literalNode{value: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if strings.HasSuffix(t.text, ".") || strings.Contains(t.text, "..") {
+			return nil, fmt.Errorf("неверное имя переменной %q в позиции %d", t.text, t.pos)
+		}
+		return identNode{name: t.text}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("ожидалась ) после позиции %d", t.pos)
+		}
+		return inner, nil
+	case tokLBracket:
+		var items []node
+		if p.peek().kind == tokRBracket {
+			p.next()
+			return listNode{items: items}, nil
+		}
+		for {
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+			sep := p.next()
+			if sep.kind == tokRBracket {
+				return listNode{items: items}, nil
+			}
+			if sep.kind != tokComma {
+				return nil, fmt.Errorf("ожидалась , или ] в позиции %d", sep.pos)
+			}
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("неожиданный конец выражения")
+	}
+
+	return nil, fmt.Errorf("неожиданный токен %q в позиции %d", t.text, t.pos)
+}
+
+// --- Вычисление ---
+
+type node interface {
+	eval(env map[string]any) (any, error)
+}
+
+type literalNode struct{ value any }
+
+type identNode struct{ name string }
+
+type listNode struct{ items []node }
+
+type notNode struct{ operand node }
+
+type logicNode struct {
+	op          string
+	left, right node
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n literalNode) eval(map[string]any) (any, error) {
+	return n.value, nil
+}
+
+func (n identNode) eval(env map[string]any) (any, error) {
+	return normalize(Lookup(env, n.name)), nil
+}
+
+func (n listNode) eval(env map[string]any) (any, error) {
+	values := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (n notNode) eval(env map[string]any) (any, error) {
+	v, err := evalBool(n.operand, env)
+	if err != nil {
+		return nil, err
+	}
+	return !v, nil
+}
+
+func (n logicNode) eval(env map[string]any) (any, error) {
+	left, err := evalBool(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Вычисляем правую часть, только если она влияет на результат
+	if n.op == "&&" && !left {
+		return false, nil
+	}
+	if n.op == "||" && left {
+		return true, nil
+	}
+
+	return evalBool(n.right, env)
+}
+
+func (n compareNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		list, ok := right.([]any)
+		if !ok {
+			return nil, fmt.Errorf("справа от in должен быть список")
+		}
+		for _, item := range list {
+			if equal(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "contains":
+		if list, ok := left.([]any); ok {
+			for _, item := range list {
+				if equal(item, right) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+		if !lok || !rok {
+			return false, nil
+		}
+		return strings.Contains(strings.ToLower(ls), strings.ToLower(rs)), nil
+	}
+
+	// Упорядочивающие сравнения: числа или строки, остальное ложно
+	if lf, rf, ok := bothNumbers(left, right); ok {
+		return compareOrdered(n.op, lf, rf), nil
+	}
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if lok && rok {
+		return compareOrdered(n.op, ls, rs), nil
+	}
+	return false, nil
+}
+
+func evalBool(n node, env map[string]any) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("ожидалось логическое значение, получено %v", v)
+}
+
+func compareOrdered[T float64 | string](op string, a, b T) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if af, bf, ok := bothNumbers(a, b); ok {
+		return af == bf
+	}
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case nil:
+		return b == nil
+	}
+	return false
+}
+
+// bothNumbers приводит оба значения к числам. Строки с числом тоже
+// считаются числами: поля форм часто приходят строками.
+func bothNumbers(a, b any) (float64, float64, bool) {
+	af, aok := toNumber(a)
+	bf, bok := toNumber(b)
+	if !aok || !bok {
+		return 0, 0, false
+	}
+	_, aIsNum := a.(float64)
+	_, bIsNum := b.(float64)
+	return af, bf, aIsNum || bIsNum
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// normalize приводит значения из Go-структур к типам языка
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case []string:
+		list := make([]any, len(n))
+		for i, s := range n {
+			list[i] = s
+		}
+		return list
+	}
+	return v
+}
+
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch v := n.(type) {
+	case listNode:
+		for _, item := range v.items {
+			walk(item, fn)
+		}
+	case notNode:
+		walk(v.operand, fn)
+	case logicNode:
+		walk(v.left, fn)
+		walk(v.right, fn)
+	case compareNode:
+		walk(v.left, fn)
+		walk(v.right, fn)
+	}
+}
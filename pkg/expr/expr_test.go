@@ -0,0 +1,159 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "неожиданный конец выражения"},
+		{"income >", "неожиданный конец выражения"},
+		{"(income > 1", "ожидалась )"},
+		{"income > 1)", "неожиданный токен"},
+		{`founder == "Министерство`, "незакрытая строка"},
+		{"income # 1", "неожиданный символ"},
+		{"kopuk in [1, 2", "ожидалась , или ]"},
+		{"kopuk in [1 2]", "ожидалась , или ]"},
+		{"metadata. == 1", "неверное имя переменной"},
+		{"metadata..income == 1", "неверное имя переменной"},
+		{"1.2.3 == 1", "неверное число"},
+		{"income > > 1", "неожиданный токен"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil {
+				t.Fatalf("ожидалась ошибка разбора")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ошибка %q не содержит %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := map[string]any{
+		"document_type": "financial_report",
+		"kopuk":         2,
+		"founder":       "Министерство культуры",
+		"tags":          []string{"срочно", "внешний"},
+		"metadata": map[string]any{
+			"income":   1500000.0,
+			"expenses": "250000",
+			"approved": true,
+		},
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		// Приоритет: && выше ||, ! ниже сравнения
+		{"and выше or", "true || false && false", true},
+		{"скобки меняют порядок", "(true || false) && false", false},
+		{"not связывает ближайший операнд", "!true || true", true},
+		{"not над сравнением", "!kopuk == 2", false},
+		{"двойное отрицание", "!!true", true},
+		{"слова and/or/not", "not (kopuk == 1) and founder contains \"культуры\" or false", true},
+		{"цепочка or", "kopuk == 5 || kopuk == 6 || kopuk == 2", true},
+
+		// Сравнения
+		{"число больше", "metadata.income > 1000000", true},
+		{"число меньше или равно", "metadata.income <= 1000000", false},
+		{"отрицательное число", "kopuk > -1", true},
+		{"строка с числом сравнивается как число", "metadata.expenses > 100000", true},
+		{"строки по алфавиту", `document_type < "museum_report"`, true},
+		{"равенство строк", `document_type == "financial_report"`, true},
+		{"неравенство", `document_type != "financial_report"`, false},
+		{"in по списку чисел", "kopuk in [1, 2, 3]", true},
+		{"in пустой список", "kopuk in []", false},
+		{"contains подстроки без регистра", `founder contains "МИНИСТЕРСТВО"`, true},
+		{"contains по списку", `tags contains "срочно"`, true},
+		{"логическая переменная", "metadata.approved", true},
+
+		// Несовпадение типов не ошибка, а ложь
+		{"строка больше числа", `founder > 1`, false},
+		{"логическое равно числу", "metadata.approved == 1", false},
+		{"contains у числа", `kopuk contains "2"`, false},
+
+		// Отсутствующие переменные равны null
+		{"null не больше числа", "metadata.visitors > 0", false},
+		{"null не меньше числа", "metadata.visitors < 0", false},
+		{"null равен null", "metadata.visitors == null", true},
+		{"путь через не объект", "founder.name == null", true},
+		{"null в логике ложен", "metadata.missing || kopuk == 2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.src, err)
+			}
+			got, err := e.Eval(env)
+			if err != nil {
+				t.Fatalf("Eval(%q): %v", tt.src, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Eval(%q) = %v, ожидалось %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalTypeErrors(t *testing.T) {
+	env := map[string]any{"kopuk": 2, "metadata": map[string]any{"income": 10.0}}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"metadata.income", "результат выражения не логический"},
+		{`"строка"`, "результат выражения не логический"},
+		{"kopuk in 2", "справа от in должен быть список"},
+		{"kopuk && true", "ожидалось логическое значение"},
+		{"!metadata.income", "ожидалось логическое значение"},
+		{"true || true && kopuk", ""},
+		{"false || kopuk", "ожидалось логическое значение"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.src, err)
+			}
+			_, err = e.Eval(env)
+			if tt.want == "" {
+				// Правая часть не вычисляется, если результат уже известен
+				if err != nil {
+					t.Fatalf("Eval(%q): %v", tt.src, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Eval(%q) = %v, ожидалась ошибка %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	e, err := Parse(`metadata.income > 1 || (kopuk in [1, 2] && metadata.income < 5) || !founder contains "x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"metadata.income", "kopuk", "founder"}
+	if got := e.Identifiers(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Identifiers() = %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+var (
+	ErrInvalidToken = errors.New("недействительный токен")
+	ErrExpiredToken = errors.New("срок действия токена истек")
+)
+
+// Claims содержит полезную нагрузку токена доступа
+type Claims struct {
+	UserID    int64    `json:"uid"`
+	Roles     []string `json:"roles,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// Codec подписывает и проверяет токены выбранным алгоритмом
+type Codec struct {
+	alg        string
+	issuer     string
+	secret     []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+func NewHS256(secret []byte) (*Codec, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("секрет для HS256 должен быть не короче 32 байт")
+	}
+	return &Codec{alg: AlgHS256, secret: secret}, nil
+}
+
+// NewRS256 создает кодек RS256. Приватный ключ может отсутствовать,
+// тогда кодек умеет только проверять токены.
+func NewRS256(privateKeyPEM, publicKeyPEM []byte) (*Codec, error) {
+	c := &Codec{alg: AlgRS256}
+
+	if len(privateKeyPEM) > 0 {
+		key, err := parsePrivateKey(privateKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		c.privateKey = key
+		c.publicKey = &key.PublicKey
+	}
+
+	if len(publicKeyPEM) > 0 {
+		key, err := parsePublicKey(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		c.publicKey = key
+	}
+
+	if c.publicKey == nil {
+		return nil, fmt.Errorf("не указан ключ для RS256")
+	}
+
+	return c, nil
+}
+
+// SetIssuer задает издателя токенов: он проставляется при подписи, если не
+// указан явно, и токены с другим iss при проверке отклоняются
+func (c *Codec) SetIssuer(issuer string) {
+	c.issuer = issuer
+}
+
+func (c *Codec) Sign(claims Claims) (string, error) {
+	if claims.Issuer == "" {
+		claims.Issuer = c.issuer
+	}
+	if claims.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = id
+	}
+
+	headerJSON, err := json.Marshal(header{Alg: c.alg, Typ: "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации заголовка: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации данных токена: %w", err)
+	}
+
+	signingInput := encode(headerJSON) + "." + encode(claimsJSON)
+
+	signature, err := c.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encode(signature), nil
+}
+
+// Parse проверяет подпись, издателя и срок действия токена
+func (c *Codec) Parse(token string) (*Claims, error) {
+	return c.parse(token, time.Now())
+}
+
+func (c *Codec) parse(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := decode(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	// Алгоритм берем из настроек, а не из токена, чтобы исключить подмену
+	if h.Alg != c.alg {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := decode(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !c.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := decode(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Токен другого издателя с тем же ключом не принимаем
+	if claims.Issuer != c.issuer {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+func (c *Codec) sign(input []byte) ([]byte, error) {
+	switch c.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case AlgRS256:
+		if c.privateKey == nil {
+			return nil, fmt.Errorf("приватный ключ RS256 не настроен")
+		}
+		digest := sha256.Sum256(input)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подписи токена: %w", err)
+		}
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый алгоритм: %s", c.alg)
+	}
+}
+
+func (c *Codec) verify(input, signature []byte) bool {
+	switch c.alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case AlgRS256:
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(c.publicKey, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("неверный формат приватного ключа")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора приватного ключа: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("приватный ключ не является RSA ключом")
+	}
+	return key, nil
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("неверный формат публичного ключа")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора публичного ключа: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("публичный ключ не является RSA ключом")
+	}
+	return key, nil
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации идентификатора токена: %w", err)
+	}
+	return encode(b), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,230 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func hsCodec(t *testing.T, issuer string) *Codec {
+	t.Helper()
+	c, err := NewHS256([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("NewHS256: %v", err)
+	}
+	c.SetIssuer(issuer)
+	return c
+}
+
+func rsCodec(t *testing.T, issuer string) (*Codec, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	c, err := NewRS256(keyPEM, nil)
+	if err != nil {
+		t.Fatalf("NewRS256: %v", err)
+	}
+	c.SetIssuer(issuer)
+	return c, key
+}
+
+// rawToken собирает токен с произвольным заголовком и данными без подписи
+// кодека, как это сделал бы атакующий
+func rawToken(t *testing.T, h header, claims any, signature []byte) string {
+	t.Helper()
+	hj, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cj, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encode(hj) + "." + encode(cj) + "." + encode(signature)
+}
+
+func TestRoundTrip(t *testing.T) {
+	rs, _ := rsCodec(t, "document-approval")
+	codecs := map[string]*Codec{
+		AlgHS256: hsCodec(t, "document-approval"),
+		AlgRS256: rs,
+	}
+
+	for alg, c := range codecs {
+		t.Run(alg, func(t *testing.T) {
+			now := time.Now()
+			token, err := c.Sign(Claims{
+				UserID:    7,
+				Roles:     []string{"admin", "approver"},
+				IssuedAt:  now.Unix(),
+				ExpiresAt: now.Add(time.Minute).Unix(),
+			})
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			claims, err := c.Parse(token)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims.UserID != 7 || strings.Join(claims.Roles, ",") != "admin,approver" {
+				t.Errorf("claims %+v", claims)
+			}
+			if claims.Issuer != "document-approval" {
+				t.Errorf("iss %q", claims.Issuer)
+			}
+			if claims.ID == "" {
+				t.Error("jti не проставлен")
+			}
+		})
+	}
+}
+
+func TestRS256VerifyOnly(t *testing.T) {
+	signer, key := rsCodec(t, "")
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: mustPKIX(t, &key.PublicKey)})
+	verifier, err := NewRS256(nil, pubPEM)
+	if err != nil {
+		t.Fatalf("NewRS256: %v", err)
+	}
+
+	token, err := signer.Sign(Claims{UserID: 1, ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := verifier.Parse(token); err != nil {
+		t.Fatalf("Parse публичным ключом: %v", err)
+	}
+	if _, err := verifier.Sign(Claims{UserID: 1}); err == nil {
+		t.Fatal("кодек без приватного ключа не должен подписывать")
+	}
+}
+
+func mustPKIX(t *testing.T, key *rsa.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParseRejects(t *testing.T) {
+	hs := hsCodec(t, "document-approval")
+	rs, _ := rsCodec(t, "document-approval")
+	now := time.Now()
+	valid := Claims{UserID: 7, Issuer: "document-approval", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	sign := func(c *Codec, claims Claims) string {
+		token, err := c.Sign(claims)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return token
+	}
+
+	hsToken := sign(hs, valid)
+	parts := strings.Split(hsToken, ".")
+
+	// Подпись другим секретом той же длины
+	other, err := NewHS256([]byte(strings.Repeat("x", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetIssuer("document-approval")
+
+	// Данные подменены, подпись прежняя
+	forged := valid
+	forged.UserID = 1
+	forgedClaims, _ := json.Marshal(forged)
+
+	// Открытый ключ RS256 как секрет HS256 - классическая подмена алгоритма
+	rsToken := sign(rs, valid)
+
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Second).Unix()
+	noExp := valid
+	noExp.ExpiresAt = 0
+	wrongIss := valid
+	wrongIss.Issuer = "other-service"
+	noIss := valid
+	noIss.Issuer = ""
+
+	tests := []struct {
+		name  string
+		codec *Codec
+		token string
+		want  error
+	}{
+		{"не три части", hs, parts[0] + "." + parts[1], ErrInvalidToken},
+		{"мусор в заголовке", hs, "!!." + parts[1] + "." + parts[2], ErrInvalidToken},
+		{"подменены данные", hs, parts[0] + "." + encode(forgedClaims) + "." + parts[2], ErrInvalidToken},
+		{"испорчена подпись", hs, parts[0] + "." + parts[1] + "." + encode([]byte("tampered")), ErrInvalidToken},
+		{"чужой секрет", hs, sign(other, valid), ErrInvalidToken},
+		{"alg none без подписи", hs, rawToken(t, header{Alg: "none", Typ: "JWT"}, valid, nil), ErrInvalidToken},
+		{"alg none для RS256", rs, rawToken(t, header{Alg: "none", Typ: "JWT"}, valid, nil), ErrInvalidToken},
+		{"RS256 токен для HS256 кодека", hs, rsToken, ErrInvalidToken},
+		{"HS256 токен для RS256 кодека", rs, hsToken, ErrInvalidToken},
+		{"чужой iss", hs, sign(hs, wrongIss), ErrInvalidToken},
+		{"токен с другим iss в RS256", rs, sign(rs, wrongIss), ErrInvalidToken},
+		{"истек", hs, sign(hs, expired), ErrExpiredToken},
+		{"истек RS256", rs, sign(rs, expired), ErrExpiredToken},
+		{"нет exp", hs, sign(hs, noExp), ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.codec.Parse(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ошибка %v, ожидалась %v (claims %+v)", err, tt.want, claims)
+			}
+		})
+	}
+
+	// Пустой iss в токене подставляется издателем кодека при подписи,
+	// поэтому проверяем токен без iss, собранный вручную
+	t.Run("нет iss", func(t *testing.T) {
+		raw := rawToken(t, header{Alg: AlgHS256, Typ: "JWT"}, noIss, nil)
+		p := strings.Split(raw, ".")
+		sig, err := hs.sign([]byte(p[0] + "." + p[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := hs.Parse(p[0] + "." + p[1] + "." + encode(sig)); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("ошибка %v, ожидалась %v", err, ErrInvalidToken)
+		}
+	})
+}
+
+func TestExpiryBoundary(t *testing.T) {
+	c := hsCodec(t, "")
+	exp := time.Unix(1_800_000_000, 0)
+	token, err := c.Sign(Claims{UserID: 1, ExpiresAt: exp.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.parse(token, exp.Add(-time.Second)); err != nil {
+		t.Errorf("за секунду до exp: %v", err)
+	}
+	if _, err := c.parse(token, exp); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("в момент exp: %v", err)
+	}
+}
+
+func TestNewHS256ShortSecret(t *testing.T) {
+	if _, err := NewHS256([]byte("short")); err == nil {
+		t.Fatal("короткий секрет должен отклоняться")
+	}
+}
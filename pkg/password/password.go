@@ -0,0 +1,76 @@
+package password
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Cost - стоимость bcrypt для новых хешей
+	Cost = 12
+
+	// MinLength - минимальная длина нового пароля
+	MinLength = 8
+
+	// MaxLength - bcrypt учитывает только первые 72 байта пароля
+	MaxLength = 72
+)
+
+// Hash возвращает bcrypt хеш пароля
+func Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), Cost)
+	if err != nil {
+		return "", fmt.Errorf("ошибка хеширования пароля: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Verify сравнивает пароль с хешем. needsRehash = true, если хеш
+// посчитан с устаревшими параметрами и его стоит пересчитать.
+func Verify(password, encoded string) (ok bool, needsRehash bool) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return true, err != nil || cost < Cost
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// VerifyDummy тратит на проверку столько же времени, сколько Verify. Вызывается,
+// когда проверять нечего, чтобы время ответа не выдавало состояние учетной записи.
+func VerifyDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), Cost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// Validate проверяет требования к новому паролю
+func Validate(password string) error {
+	if len([]rune(password)) < MinLength {
+		return fmt.Errorf("пароль должен содержать не менее %d символов", MinLength)
+	}
+	if len(password) > MaxLength {
+		return fmt.Errorf("пароль не должен быть длиннее %d байт", MaxLength)
+	}
+	return nil
+}
+
+// Generate создает случайный временный пароль
+func Generate() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации пароля: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
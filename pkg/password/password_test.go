@@ -0,0 +1,55 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$2a$") {
+		t.Fatalf("ожидался bcrypt хеш, получено %q", hash)
+	}
+
+	if ok, rehash := Verify("correct horse", hash); !ok || rehash {
+		t.Fatalf("Verify(верный) = %v, %v", ok, rehash)
+	}
+	if ok, _ := Verify("wrong horse", hash); ok {
+		t.Fatal("неверный пароль принят")
+	}
+	if ok, _ := Verify("correct horse", "plain text"); ok {
+		t.Fatal("принят поврежденный хеш")
+	}
+}
+
+func TestVerifyRequestsRehashForLowCost(t *testing.T) {
+	weak, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := Verify("correct horse", string(weak)); !ok || !rehash {
+		t.Fatalf("Verify(низкая стоимость) = %v, %v, ожидалось true, true", ok, rehash)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"short", false},
+		{"пароль12", true},
+		{strings.Repeat("a", MaxLength), true},
+		{strings.Repeat("a", MaxLength+1), false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.password); (err == nil) != tt.valid {
+			t.Errorf("Validate(%d символов) = %v, ожидалось valid=%v", len(tt.password), err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pdfgen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	refPattern       = `(\d+) (\d+) R`
+	rootPattern      = regexp.MustCompile(`/Root ` + refPattern)
+	pagesPattern     = regexp.MustCompile(`/Pages ` + refPattern)
+	kidsPattern      = regexp.MustCompile(`/Kids \[([^\]]*)\]`)
+	startxrefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	xrefStmPattern   = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\b`)
+)
+
+// inheritedKeys - атрибуты корня дерева страниц, которые наследуют
+// исходные страницы и которые нужно сохранить при его перезаписи
+var inheritedKeys = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// AppendTo дописывает страницы документа в конец существующего PDF.
+// Исходный файл не меняется: новые объекты и обновленный корень дерева
+// страниц добавляются инкрементальным обновлением, поэтому подписи и
+// содержимое оригинала остаются нетронутыми. Раздел дописывания оформляется
+// так же, как последняя таблица xref оригинала: таблицей или потоком.
+func (d *Document) AppendTo(original []byte) (out []byte, err error) {
+	if len(d.pages) == 0 {
+		return nil, fmt.Errorf("в документе нет страниц")
+	}
+
+	// Библиотека разбора PDF паникует на поврежденных файлах
+	defer func() {
+		if p := recover(); p != nil {
+			out, err = nil, fmt.Errorf("исходный файл не удалось прочитать как PDF: %v", p)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(original), int64(len(original)))
+	if err != nil {
+		return nil, fmt.Errorf("исходный файл не удалось прочитать как PDF: %w", err)
+	}
+
+	trailer := r.Trailer()
+	if !trailer.Key("Encrypt").IsNull() {
+		return nil, fmt.Errorf("дописывание в зашифрованный PDF не поддерживается")
+	}
+
+	root := rootPattern.FindStringSubmatch(trailer.String())
+	if root == nil {
+		return nil, fmt.Errorf("в PDF не найден каталог документа")
+	}
+	catalog := trailer.Key("Root")
+	pagesRef := pagesPattern.FindStringSubmatch(catalog.String())
+	if pagesRef == nil {
+		return nil, fmt.Errorf("в PDF не найдено дерево страниц")
+	}
+	pages := catalog.Key("Pages")
+	kids := kidsPattern.FindStringSubmatch(pages.String())
+	if kids == nil {
+		return nil, fmt.Errorf("в PDF не найден список страниц")
+	}
+
+	tail := original
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	startxref := startxrefPattern.FindSubmatch(tail)
+	if startxref == nil {
+		return nil, fmt.Errorf("в PDF не найдена таблица xref")
+	}
+	xrefStream, err := isXRefStream(original, string(startxref[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	// Атрибуты корня переносим в том виде, в каком их отдает разбор PDF.
+	// Строки в этом виде не совпадают с синтаксисом PDF, такие файлы не трогаем.
+	var inherited strings.Builder
+	for _, key := range inheritedKeys {
+		v := pages.Key(key)
+		if v.IsNull() {
+			continue
+		}
+		text := v.String()
+		if strings.ContainsAny(text, `"@{`) {
+			return nil, fmt.Errorf("не удалось перенести атрибут %s дерева страниц", key)
+		}
+		fmt.Fprintf(&inherited, " /%s %s", key, text)
+	}
+
+	pagesNum, _ := strconv.Atoi(pagesRef[1])
+	pagesGen, _ := strconv.Atoi(pagesRef[2])
+	parent := ref(pagesNum, pagesGen)
+
+	base := len(original)
+	separator := ""
+	if !bytes.HasSuffix(original, []byte("\n")) {
+		separator = "\n"
+		base++
+	}
+
+	w := newWriter(base)
+	w.next = int(trailer.Key("Size").Int64())
+	if w.next <= pagesNum {
+		return nil, fmt.Errorf("поврежденный trailer PDF")
+	}
+
+	pageRefs, err := d.writePages(w, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	count := pages.Key("Count").Int64() + int64(len(pageRefs))
+	w.objectGen(pagesNum, pagesGen, fmt.Sprintf("<< /Type /Pages /Kids [%s %s] /Count %d%s >>",
+		strings.TrimSpace(kids[1]), strings.Join(pageRefs, " "), count, inherited.String()))
+
+	update := fmt.Sprintf("/Root %s %s R /Prev %s", root[1], root[2], startxref[1])
+	if xrefStream {
+		w.finishStream(update)
+	} else {
+		w.finish(update, false)
+	}
+
+	out = make([]byte, 0, base+w.buf.Len())
+	out = append(out, original...)
+	out = append(out, separator...)
+	return append(out, w.buf.Bytes()...), nil
+}
+
+// isXRefStream сообщает, что startxref указывает на поток перекрестных
+// ссылок, а не на классическую таблицу xref
+func isXRefStream(original []byte, startxref string) (bool, error) {
+	offset, err := strconv.Atoi(startxref)
+	if err != nil || offset >= len(original) {
+		return false, fmt.Errorf("в PDF неверное смещение таблицы xref")
+	}
+
+	section := original[offset:]
+	switch {
+	case bytes.HasPrefix(bytes.TrimLeft(section, " \t\r\n"), []byte("xref")):
+		return false, nil
+	case xrefStmPattern.Match(section[:min(len(section), 64)]):
+		return true, nil
+	}
+	return false, fmt.Errorf("в PDF не найдена таблица xref")
+}
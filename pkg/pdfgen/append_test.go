@@ -0,0 +1,229 @@
+package pdfgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// testFont собирает минимальный шрифт TrueType: символы с пробела до
+// конца кириллицы отображаются на глифы подряд, у всех глифов одна ширина
+func testFont(t *testing.T) *Font {
+	t.Helper()
+
+	const first, last = 0x20, 0x44F
+	numGlyphs := last - first + 2
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[18:], 1000) // unitsPerEm
+	for i, v := range []int16{0, -200, 600, 800} {
+		binary.BigEndian.PutUint16(head[36+2*i:], uint16(v))
+	}
+
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint16(hhea[4:], 800)
+	binary.BigEndian.PutUint16(hhea[6:], uint16(0xFFFF-199)) // -200
+	binary.BigEndian.PutUint16(hhea[34:], 1)                 // numberOfHMetrics
+
+	hmtx := []byte{0x01, 0xF4, 0, 0} // ширина 500
+
+	maxp := make([]byte, 6)
+	binary.BigEndian.PutUint32(maxp, 0x00005000)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(numGlyphs))
+
+	// cmap: одна подтаблица формата 12 (Windows, UCS-4) с одной группой
+	cmap := make([]byte, 12+28)
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 10)
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	sub := cmap[12:]
+	binary.BigEndian.PutUint16(sub, 12)
+	binary.BigEndian.PutUint32(sub[4:], 28)
+	binary.BigEndian.PutUint32(sub[12:], 1)
+	binary.BigEndian.PutUint32(sub[16:], first)
+	binary.BigEndian.PutUint32(sub[20:], last)
+	binary.BigEndian.PutUint32(sub[24:], 1)
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"maxp", maxp}}
+
+	var font bytes.Buffer
+	binary.Write(&font, binary.BigEndian, uint32(0x00010000))
+	binary.Write(&font, binary.BigEndian, uint16(len(tables)))
+	font.Write(make([]byte, 6))
+
+	offset := 12 + 16*len(tables)
+	for _, tbl := range tables {
+		font.WriteString(tbl.tag)
+		binary.Write(&font, binary.BigEndian, uint32(0))
+		binary.Write(&font, binary.BigEndian, uint32(offset))
+		binary.Write(&font, binary.BigEndian, uint32(len(tbl.data)))
+		offset += (len(tbl.data) + 3) &^ 3
+	}
+	for _, tbl := range tables {
+		font.Write(tbl.data)
+		font.Write(make([]byte, (4-len(tbl.data)%4)%4))
+	}
+
+	f, err := ParseFont(font.Bytes())
+	if err != nil {
+		t.Fatalf("ParseFont: %v", err)
+	}
+	return f
+}
+
+func sheet(t *testing.T, lines ...string) *Document {
+	t.Helper()
+	doc := New(testFont(t))
+	for _, line := range lines {
+		page := doc.AddPage()
+		page.Text(50, 50, 12, line)
+		page.Line(50, 60, 300, 60, 1)
+	}
+	return doc
+}
+
+// xrefStreamPDF собирает PDF 1.5 с одной страницей, в котором вместо
+// таблицы xref - поток перекрестных ссылок, как у PDF из офисных пакетов
+func xrefStreamPDF() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n%\xE2\xE3\xCF\xD3\n")
+
+	offsets := map[int]int{}
+	object := func(num int, body string) {
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+	content := "BT /F1 12 Tf 50 780 Td (Original) Tj ET"
+
+	object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 595 842] >>")
+	object(3, "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>")
+	offsets[4] = b.Len()
+	fmt.Fprintf(&b, "4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)
+	object(5, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+
+	xref := b.Len()
+	offsets[6] = xref
+	var data bytes.Buffer
+	data.Write([]byte{0, 0, 0, 0, 0, 0xFF, 0xFF})
+	for n := 1; n <= 6; n++ {
+		entry := [7]byte{1}
+		binary.BigEndian.PutUint32(entry[1:], uint32(offsets[n]))
+		data.Write(entry[:])
+	}
+	fmt.Fprintf(&b, "6 0 obj\n<< /Type /XRef /Size 7 /W [1 4 2] /Root 1 0 R /Length %d >>\nstream\n", data.Len())
+	b.Write(data.Bytes())
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
+
+// pageTexts открывает PDF заново и возвращает текст каждой страницы
+func pageTexts(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("результат не открывается как PDF: %v", err)
+	}
+
+	texts := make([]string, r.NumPage())
+	for i := range texts {
+		page := r.Page(i + 1)
+		if page.V.IsNull() {
+			t.Fatalf("страница %d не найдена", i+1)
+		}
+		var text strings.Builder
+		for _, s := range page.Content().Text {
+			text.WriteString(s.S)
+		}
+		texts[i] = text.String()
+	}
+	return texts
+}
+
+func TestAppendToGeneratedPDF(t *testing.T) {
+	original, err := sheet(t, "Документ").Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := sheet(t, "Лист согласования", "Подписи").AppendTo(original)
+	if err != nil {
+		t.Fatalf("AppendTo: %v", err)
+	}
+
+	if !bytes.HasPrefix(out, original) {
+		t.Fatal("исходный файл должен остаться в начале результата без изменений")
+	}
+	if bytes.Contains(out[len(original):], []byte("/Type /XRef")) {
+		t.Fatal("к файлу с таблицей xref нужно дописывать таблицу xref")
+	}
+
+	texts := pageTexts(t, out)
+	if len(texts) != 3 {
+		t.Fatalf("страниц %d, ожидалось 3", len(texts))
+	}
+	for i, want := range []string{"Документ", "Лист согласования", "Подписи"} {
+		if texts[i] != want {
+			t.Errorf("страница %d: %q, ожидалось %q", i+1, texts[i], want)
+		}
+	}
+}
+
+func TestAppendToXRefStreamPDF(t *testing.T) {
+	original := xrefStreamPDF()
+	if texts := pageTexts(t, original); len(texts) != 1 {
+		t.Fatalf("тестовый PDF: страниц %d, ожидалась 1", len(texts))
+	}
+
+	out, err := sheet(t, "Лист согласования").AppendTo(original)
+	if err != nil {
+		t.Fatalf("AppendTo: %v", err)
+	}
+
+	update := out[len(original):]
+	if !bytes.HasPrefix(out, original) {
+		t.Fatal("исходный файл должен остаться в начале результата без изменений")
+	}
+	if bytes.Contains(update, []byte("\nxref\n")) || !bytes.Contains(update, []byte("/Type /XRef")) {
+		t.Fatal("к файлу с потоком xref нужно дописывать поток xref")
+	}
+	prev := startxrefPattern.FindSubmatch(original)[1]
+	if !bytes.Contains(update, append([]byte("/Prev "), prev...)) {
+		t.Fatal("в разделе дописывания нет ссылки на прежний xref")
+	}
+
+	texts := pageTexts(t, out)
+	if len(texts) != 2 {
+		t.Fatalf("страниц %d, ожидалось 2", len(texts))
+	}
+	if texts[0] != "Original" || texts[1] != "Лист согласования" {
+		t.Fatalf("текст страниц: %q", texts)
+	}
+
+	// Результат можно дописывать повторно
+	again, err := sheet(t, "Повторно").AppendTo(out)
+	if err != nil {
+		t.Fatalf("повторный AppendTo: %v", err)
+	}
+	if texts := pageTexts(t, again); len(texts) != 3 || texts[2] != "Повторно" {
+		t.Fatalf("после повторного дописывания: %q", texts)
+	}
+}
+
+func TestAppendToRejectsBrokenPDF(t *testing.T) {
+	original := xrefStreamPDF()
+	broken := bytes.Replace(original, []byte("startxref\n"), []byte("startxref\n1"), 1)
+
+	if _, err := sheet(t, "Лист").AppendTo(broken); err == nil {
+		t.Fatal("ожидалась ошибка для PDF с неверным startxref")
+	}
+}
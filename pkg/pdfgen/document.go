@@ -0,0 +1,337 @@
+// Package pdfgen формирует простые PDF документы с текстом и линиями
+// на чистом Go и умеет дописывать их страницы в конец существующего PDF.
+package pdfgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Размеры страницы A4 в пунктах
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document - набор страниц A4 с текстом одним встроенным шрифтом
+type Document struct {
+	font  *Font
+	pages []*Page
+	used  map[uint16]rune
+}
+
+// Page - страница документа. Координаты отсчитываются от левого верхнего угла.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+func New(font *Font) *Document {
+	return &Document{font: font, used: map[uint16]rune{}}
+}
+
+// Font возвращает шрифт документа, например для расчета переносов
+func (d *Document) Font() *Font {
+	return d.font
+}
+
+// AddPage добавляет пустую страницу A4
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text выводит строку так, что ее базовая линия проходит на y пунктов ниже верха страницы
+func (p *Page) Text(x, y, size float64, s string) {
+	var hex strings.Builder
+	for _, r := range s {
+		g := p.doc.font.glyph(r)
+		if _, ok := p.doc.used[g]; !ok {
+			p.doc.used[g] = r
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+	fmt.Fprintf(&p.content, "BT /F1 %s Tf %s %s Td <%s> Tj ET\n",
+		num(size), num(x), num(PageHeight-y), hex.String())
+}
+
+// Line рисует отрезок толщиной width
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Rect рисует рамку прямоугольника
+func (p *Page) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n",
+		num(width), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Bytes возвращает документ как самостоятельный PDF файл
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		return nil, fmt.Errorf("в документе нет страниц")
+	}
+
+	w := newWriter(0)
+	w.buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	catalog, pagesRoot := 1, 2
+	w.next = 3
+	pageRefs, err := d.writePages(w, ref(pagesRoot, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	w.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %s >>", ref(pagesRoot, 0)))
+	w.object(pagesRoot, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(pageRefs, " "), len(pageRefs)))
+
+	w.finish(fmt.Sprintf("/Root %s", ref(catalog, 0)), true)
+	return w.buf.Bytes(), nil
+}
+
+// writePages записывает шрифт и страницы, возвращает ссылки на страницы
+func (d *Document) writePages(w *writer, parent string) ([]string, error) {
+	fontRef, err := d.writeFont(w)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, p := range d.pages {
+		content := w.alloc()
+		if err := w.stream(content, "", p.content.Bytes(), true); err != nil {
+			return nil, err
+		}
+
+		page := w.alloc()
+		w.object(page, fmt.Sprintf(
+			"<< /Type /Page /Parent %s /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %s >> >> /Contents %s >>",
+			parent, num(PageWidth), num(PageHeight), fontRef, ref(content, 0),
+		))
+		refs = append(refs, ref(page, 0))
+	}
+	return refs, nil
+}
+
+// writeFont встраивает шрифт как составной (Type0) с кодировкой Identity-H
+func (d *Document) writeFont(w *writer) (string, error) {
+	f := d.font
+	type0, cidFont, descriptor, fontFile, toUnicode := w.alloc(), w.alloc(), w.alloc(), w.alloc(), w.alloc()
+
+	w.object(type0, fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%s] /ToUnicode %s >>",
+		f.name, ref(cidFont, 0), ref(toUnicode, 0),
+	))
+
+	glyphs := make([]int, 0, len(d.used))
+	for g := range d.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, f.scale(int(f.advance(uint16(g)))))
+	}
+
+	w.object(cidFont, fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor %s /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+		f.name, ref(descriptor, 0), widths.String(),
+	))
+
+	w.object(descriptor, fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] "+
+			"/ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %s >>",
+		f.name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), ref(fontFile, 0),
+	))
+
+	if err := w.stream(fontFile, fmt.Sprintf("/Length1 %d", len(f.data)), f.data, true); err != nil {
+		return "", err
+	}
+
+	if err := w.stream(toUnicode, "", d.toUnicodeCMap(glyphs), true); err != nil {
+		return "", err
+	}
+
+	return ref(type0, 0), nil
+}
+
+// toUnicodeCMap сопоставляет глифы символам, чтобы текст листа можно было
+// копировать и индексировать
+func (d *Document) toUnicodeCMap(glyphs []int) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	for start := 0; start < len(glyphs); start += 100 {
+		end := start + 100
+		if end > len(glyphs) {
+			end = len(glyphs)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, g := range glyphs[start:end] {
+			var hex strings.Builder
+			for _, u := range utf16.Encode([]rune{d.used[uint16(g)]}) {
+				fmt.Fprintf(&hex, "%04X", u)
+			}
+			fmt.Fprintf(&b, "<%04X> <%s>\n", g, hex.String())
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// writer собирает объекты PDF и таблицу смещений для xref
+type writer struct {
+	buf     bytes.Buffer
+	base    int // длина исходного файла при дописывании
+	next    int
+	offsets map[int]int
+	gens    map[int]int
+}
+
+func newWriter(base int) *writer {
+	return &writer{base: base, offsets: map[int]int{}, gens: map[int]int{}}
+}
+
+func (w *writer) alloc() int {
+	n := w.next
+	w.next++
+	return n
+}
+
+func (w *writer) objectGen(num, gen int, body string) {
+	w.offsets[num] = w.base + w.buf.Len()
+	w.gens[num] = gen
+	fmt.Fprintf(&w.buf, "%d %d obj\n%s\nendobj\n", num, gen, body)
+}
+
+func (w *writer) object(num int, body string) {
+	w.objectGen(num, 0, body)
+}
+
+func (w *writer) stream(num int, extra string, data []byte, compress bool) error {
+	filter := ""
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("ошибка сжатия потока PDF: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("ошибка сжатия потока PDF: %w", err)
+		}
+		data = z.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+
+	w.offsets[num] = w.base + w.buf.Len()
+	w.gens[num] = 0
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Length %d%s %s >>\nstream\n", num, len(data), filter, extra)
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+// finish записывает таблицу xref и trailer. full - таблица для нового файла,
+// иначе - раздел дописывания с измененными объектами.
+func (w *writer) finish(trailer string, full bool) {
+	xrefOffset := w.base + w.buf.Len()
+	w.buf.WriteString("xref\n")
+	if full {
+		fmt.Fprintf(&w.buf, "0 %d\n0000000000 65535 f \n", w.next)
+		for _, n := range w.sortedNums() {
+			fmt.Fprintf(&w.buf, "%010d %05d n \n", w.offsets[n], w.gens[n])
+		}
+	} else {
+		for _, sub := range subsections(w.sortedNums()) {
+			fmt.Fprintf(&w.buf, "%d %d\n", sub[0], len(sub))
+			for _, n := range sub {
+				fmt.Fprintf(&w.buf, "%010d %05d n \n", w.offsets[n], w.gens[n])
+			}
+		}
+	}
+
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", w.next, trailer, xrefOffset)
+}
+
+// finishStream записывает раздел дописывания потоком перекрестных ссылок
+// (PDF 1.5). Так дописываются файлы, которые сами используют такие потоки:
+// классическая таблица не может ссылаться на поток через /Prev.
+func (w *writer) finishStream(trailer string) {
+	xref := w.alloc()
+	xrefOffset := w.base + w.buf.Len()
+	w.offsets[xref] = xrefOffset
+	w.gens[xref] = 0
+
+	// Запись: тип 1 (объект в файле), смещение 4 байта, поколение 2 байта
+	var index strings.Builder
+	var data bytes.Buffer
+	for _, sub := range subsections(w.sortedNums()) {
+		fmt.Fprintf(&index, "%d %d ", sub[0], len(sub))
+		for _, n := range sub {
+			entry := [7]byte{1}
+			binary.BigEndian.PutUint32(entry[1:], uint32(w.offsets[n]))
+			binary.BigEndian.PutUint16(entry[5:], uint16(w.gens[n]))
+			data.Write(entry[:])
+		}
+	}
+
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Type /XRef /Size %d /Index [%s] /W [1 4 2] /Length %d %s >>\nstream\n",
+		xref, w.next, strings.TrimSpace(index.String()), data.Len(), trailer)
+	w.buf.Write(data.Bytes())
+	fmt.Fprintf(&w.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+}
+
+func (w *writer) sortedNums() []int {
+	nums := make([]int, 0, len(w.offsets))
+	for n := range w.offsets {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// subsections делит отсортированные номера объектов на подразделы из
+// подряд идущих номеров
+func subsections(nums []int) [][]int {
+	var subs [][]int
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		subs = append(subs, nums[i:j+1])
+		i = j + 1
+	}
+	return subs
+}
+
+func ref(num, gen int) string {
+	return fmt.Sprintf("%d %d R", num, gen)
+}
+
+// num форматирует число без лишних нулей, как принято в PDF
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
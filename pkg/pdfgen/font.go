@@ -0,0 +1,344 @@
+package pdfgen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font - шрифт TrueType, который целиком встраивается в PDF.
+// Кириллица и другие символы выводятся по кодам глифов (Identity-H).
+type Font struct {
+	data       []byte
+	name       string
+	unitsPerEm float64
+	ascent     int
+	descent    int
+	capHeight  int
+	bbox       [4]int
+	cmap       map[rune]uint16
+	advances   []uint16
+}
+
+// LoadFont читает шрифт TrueType из файла
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения шрифта: %w", err)
+	}
+	return ParseFont(data)
+}
+
+// ParseFont разбирает таблицы шрифта TrueType, нужные для вывода текста
+func ParseFont(data []byte) (*Font, error) {
+	tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("в шрифте нет таблицы %s", tag)
+		}
+	}
+
+	f := &Font{data: data}
+
+	head := tables["head"]
+	if len(head) < 54 {
+		return nil, fmt.Errorf("поврежденная таблица head")
+	}
+	f.unitsPerEm = float64(u16(head, 18))
+	if f.unitsPerEm == 0 {
+		return nil, fmt.Errorf("поврежденная таблица head")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(u16(head, 36+2*i)))
+	}
+
+	hhea := tables["hhea"]
+	if len(hhea) < 36 {
+		return nil, fmt.Errorf("поврежденная таблица hhea")
+	}
+	f.ascent = int(int16(u16(hhea, 4)))
+	f.descent = int(int16(u16(hhea, 6)))
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && u16(os2, 0) >= 2 {
+		f.capHeight = int(int16(u16(os2, 88)))
+	}
+
+	numGlyphs := int(u16(tables["maxp"], 4))
+	numMetrics := int(u16(hhea, 34))
+	hmtx := tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("поврежденная таблица hmtx")
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for g := range f.advances {
+		if g < numMetrics {
+			f.advances[g] = u16(hmtx, g*4)
+		} else {
+			f.advances[g] = f.advances[numMetrics-1]
+		}
+	}
+
+	if f.cmap, err = readCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+
+	f.name = postScriptName(tables["name"])
+	return f, nil
+}
+
+// TextWidth возвращает ширину строки в пунктах для кегля size
+func (f *Font) TextWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		units += float64(f.advance(f.glyph(r)))
+	}
+	return units * size / f.unitsPerEm
+}
+
+// Wrap разбивает текст на строки не шире width пунктов.
+// Слишком длинные слова переносятся по символам.
+func (f *Font) Wrap(text string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if f.TextWidth(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = word
+			for f.TextWidth(line, size) > width {
+				cut := f.fit(line, size, width)
+				lines = append(lines, line[:cut])
+				line = line[cut:]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fit возвращает длину в байтах самого длинного префикса не шире width
+func (f *Font) fit(s string, size, width float64) int {
+	cut := 0
+	for i, r := range s {
+		if i > 0 && f.TextWidth(s[:i+len(string(r))], size) > width {
+			return i
+		}
+		cut = i + len(string(r))
+	}
+	return cut
+}
+
+func (f *Font) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+func (f *Font) advance(g uint16) uint16 {
+	if int(g) < len(f.advances) {
+		return f.advances[g]
+	}
+	return 0
+}
+
+// scale переводит единицы шрифта в тысячные доли кегля, принятые в PDF
+func (f *Font) scale(v int) int {
+	return int(float64(v) * 1000 / f.unitsPerEm)
+}
+
+func readTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("файл не является шрифтом TrueType")
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // TrueType
+	default:
+		return nil, fmt.Errorf("поддерживаются только шрифты TrueType")
+	}
+
+	numTables := int(u16(data, 4))
+	if len(data) < 12+numTables*16 {
+		return nil, fmt.Errorf("поврежденный шрифт")
+	}
+
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[12+i*16:]
+		tag := string(rec[:4])
+		offset := int(binary.BigEndian.Uint32(rec[8:]))
+		length := int(binary.BigEndian.Uint32(rec[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, fmt.Errorf("поврежденная таблица %s", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// readCmap строит соответствие символов глифам по юникодной подтаблице
+// формата 12 или 4
+func readCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("поврежденная таблица cmap")
+	}
+
+	var format4, format12 []byte
+	numTables := int(u16(cmap, 2))
+	for i := 0; i < numTables; i++ {
+		if 4+i*8+8 > len(cmap) {
+			break
+		}
+		platform := u16(cmap, 4+i*8)
+		encoding := u16(cmap, 4+i*8+2)
+		offset := int(binary.BigEndian.Uint32(cmap[4+i*8+4:]))
+		if offset+4 > len(cmap) {
+			continue
+		}
+		unicode := platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)
+		if !unicode {
+			continue
+		}
+		switch u16(cmap, offset) {
+		case 4:
+			format4 = cmap[offset:]
+		case 12:
+			format12 = cmap[offset:]
+		}
+	}
+
+	switch {
+	case format12 != nil:
+		return readCmap12(format12)
+	case format4 != nil:
+		return readCmap4(format4)
+	}
+	return nil, fmt.Errorf("в шрифте нет юникодной таблицы символов")
+}
+
+func readCmap4(t []byte) (map[rune]uint16, error) {
+	if len(t) < 14 {
+		return nil, fmt.Errorf("поврежденная таблица cmap")
+	}
+	segCount := int(u16(t, 6)) / 2
+	ends := 14
+	starts := ends + segCount*2 + 2
+	deltas := starts + segCount*2
+	rangeOffsets := deltas + segCount*2
+	if len(t) < rangeOffsets+segCount*2 {
+		return nil, fmt.Errorf("поврежденная таблица cmap")
+	}
+
+	m := make(map[rune]uint16)
+	for i := 0; i < segCount; i++ {
+		end := int(u16(t, ends+i*2))
+		start := int(u16(t, starts+i*2))
+		delta := int(u16(t, deltas+i*2))
+		rangeOffset := int(u16(t, rangeOffsets+i*2))
+
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			var g int
+			if rangeOffset == 0 {
+				g = (c + delta) & 0xFFFF
+			} else {
+				addr := rangeOffsets + i*2 + rangeOffset + 2*(c-start)
+				if addr+2 > len(t) {
+					continue
+				}
+				if g = int(u16(t, addr)); g != 0 {
+					g = (g + delta) & 0xFFFF
+				}
+			}
+			if g != 0 {
+				m[rune(c)] = uint16(g)
+			}
+		}
+	}
+	return m, nil
+}
+
+func readCmap12(t []byte) (map[rune]uint16, error) {
+	if len(t) < 16 {
+		return nil, fmt.Errorf("поврежденная таблица cmap")
+	}
+	groups := int(binary.BigEndian.Uint32(t[12:]))
+	if len(t) < 16+groups*12 {
+		return nil, fmt.Errorf("поврежденная таблица cmap")
+	}
+
+	m := make(map[rune]uint16)
+	for i := 0; i < groups; i++ {
+		g := t[16+i*12:]
+		start := binary.BigEndian.Uint32(g)
+		end := binary.BigEndian.Uint32(g[4:])
+		glyph := binary.BigEndian.Uint32(g[8:])
+		if end < start || end-start > 0x10FFFF {
+			continue
+		}
+		for c := start; c <= end; c++ {
+			m[rune(c)] = uint16(glyph + c - start)
+		}
+	}
+	return m, nil
+}
+
+// postScriptName достает имя шрифта (nameID 6) для /BaseFont
+func postScriptName(t []byte) string {
+	const fallback = "EmbeddedFont"
+	if len(t) < 6 {
+		return fallback
+	}
+
+	count := int(u16(t, 2))
+	storage := int(u16(t, 4))
+	for i := 0; i < count; i++ {
+		rec := 6 + i*12
+		if rec+12 > len(t) || u16(t, rec+6) != 6 {
+			continue
+		}
+		platform := u16(t, rec)
+		length := int(u16(t, rec+8))
+		offset := storage + int(u16(t, rec+10))
+		if offset+length > len(t) {
+			continue
+		}
+		raw := t[offset : offset+length]
+
+		var name string
+		if platform == 3 || platform == 0 {
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = u16(raw, j*2)
+			}
+			name = string(utf16.Decode(units))
+		} else {
+			name = string(raw)
+		}
+
+		name = strings.Map(func(r rune) rune {
+			if r > ' ' && r < 127 && !strings.ContainsRune("()<>[]{}/%#", r) {
+				return r
+			}
+			return -1
+		}, name)
+		if name != "" {
+			return name
+		}
+	}
+	return fallback
+}
+
+func u16(b []byte, off int) uint16 {
+	return binary.BigEndian.Uint16(b[off:])
+}
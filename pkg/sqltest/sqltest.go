@@ -0,0 +1,296 @@
+// Package sqltest - драйвер database/sql для тестов сервисов без PostgreSQL.
+// Тест перечисляет ожидаемые запросы по порядку: фрагмент SQL, аргументы и
+// результат. Запрос, которого тест не ждал, завершается ошибкой и отмечает
+// тест проваленным.
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Any совпадает с любым значением аргумента
+var Any = anyArg{}
+
+type anyArg struct{}
+
+const (
+	kindBegin    = "BEGIN"
+	kindCommit   = "COMMIT"
+	kindRollback = "ROLLBACK"
+	kindQuery    = "QUERY"
+	kindExec     = "EXEC"
+)
+
+// Mock хранит ожидаемые запросы. Соединений может быть несколько (сервис
+// читает вне открытой транзакции), запросы сверяются в общем порядке.
+type Mock struct {
+	t        testing.TB
+	mu       sync.Mutex
+	expected []*Expectation
+	pos      int
+}
+
+// Expectation - ожидаемый запрос и его результат
+type Expectation struct {
+	kind     string
+	sql      string
+	args     []any
+	checkArg bool
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// Open возвращает базу, которая отвечает по ожиданиям mock. После теста
+// проверяется, что все ожидаемые запросы выполнены.
+func Open(t testing.TB) (*sql.DB, *Mock) {
+	t.Helper()
+
+	m := &Mock{t: t}
+	db := sql.OpenDB(connector{m})
+	t.Cleanup(func() {
+		db.Close()
+		if !t.Failed() {
+			m.ExpectationsWereMet()
+		}
+	})
+	return db, m
+}
+
+func (m *Mock) add(e *Expectation) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expected = append(m.expected, e)
+	return e
+}
+
+func (m *Mock) ExpectBegin() *Expectation    { return m.add(&Expectation{kind: kindBegin}) }
+func (m *Mock) ExpectCommit() *Expectation   { return m.add(&Expectation{kind: kindCommit}) }
+func (m *Mock) ExpectRollback() *Expectation { return m.add(&Expectation{kind: kindRollback}) }
+
+// ExpectQuery ждет запрос, содержащий фрагмент sql. Пробелы и переводы строк
+// при сравнении схлопываются.
+func (m *Mock) ExpectQuery(sql string) *Expectation {
+	return m.add(&Expectation{kind: kindQuery, sql: normalize(sql)})
+}
+
+// ExpectExec ждет команду, содержащую фрагмент sql
+func (m *Mock) ExpectExec(sql string) *Expectation {
+	return m.add(&Expectation{kind: kindExec, sql: normalize(sql), affected: 1})
+}
+
+// WithArgs задает ожидаемые аргументы запроса; Any совпадает с любым
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	e.checkArg = true
+	return e
+}
+
+// WillReturnRows задает результат запроса
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]any) *Expectation {
+	e.columns = columns
+	for _, row := range rows {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			cv, err := driver.DefaultParameterConverter.ConvertValue(v)
+			if err != nil {
+				panic(fmt.Sprintf("sqltest: значение %v: %v", v, err))
+			}
+			values[i] = cv
+		}
+		e.rows = append(e.rows, values)
+	}
+	return e
+}
+
+// WillReturnResult задает число затронутых строк команды
+func (e *Expectation) WillReturnResult(affected int64) *Expectation {
+	e.affected = affected
+	return e
+}
+
+// WillReturnError задает ошибку, которую вернет запрос
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// ExpectationsWereMet отмечает тест проваленным, если остались невыполненные
+// ожидания
+func (m *Mock) ExpectationsWereMet() {
+	m.t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expected[m.pos:] {
+		m.t.Errorf("sqltest: ожидаемый запрос не выполнен: %s %s", e.kind, e.sql)
+	}
+}
+
+// next сверяет очередной запрос с ожиданием
+func (m *Mock) next(kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query = normalize(query)
+	if m.pos >= len(m.expected) {
+		return nil, m.fail("неожиданный запрос %s %s", kind, query)
+	}
+	e := m.expected[m.pos]
+	if e.kind != kind || !strings.Contains(query, e.sql) {
+		return nil, m.fail("запрос %s %s, ожидался %s %s", kind, query, e.kind, e.sql)
+	}
+	if e.checkArg {
+		if len(args) != len(e.args) {
+			return nil, m.fail("запрос %s: %d аргументов, ожидалось %d", e.sql, len(args), len(e.args))
+		}
+		for i, want := range e.args {
+			if !argEqual(want, args[i].Value) {
+				return nil, m.fail("запрос %s: аргумент $%d = %#v, ожидалось %#v", e.sql, i+1, args[i].Value, want)
+			}
+		}
+	}
+	m.pos++
+	return e, e.err
+}
+
+func (m *Mock) fail(format string, args ...any) error {
+	err := fmt.Errorf("sqltest: "+format, args...)
+	m.t.Error(err)
+	return err
+}
+
+func argEqual(want any, got driver.Value) bool {
+	if _, ok := want.(anyArg); ok {
+		return true
+	}
+	w, err := driver.DefaultParameterConverter.ConvertValue(want)
+	if err != nil {
+		return false
+	}
+	if wt, ok := w.(time.Time); ok {
+		gt, ok := got.(time.Time)
+		return ok && wt.Equal(gt)
+	}
+	return reflect.DeepEqual(w, got)
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+type connector struct{ m *Mock }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{m: c.m}, nil }
+func (c connector) Driver() driver.Driver                        { return drv{} }
+
+type drv struct{}
+
+func (drv) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqltest: используйте sqltest.Open")
+}
+
+type conn struct{ m *Mock }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return &stmt{c: c, query: query}, nil }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error)                 { return c.BeginTx(context.Background(), driver.TxOptions{}) }
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.m.next(kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return tx{c.m}, nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.m.next(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: e.columns, values: e.rows}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.m.next(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(e.affected), nil
+}
+
+// CheckNamedValue пропускает значения драйверов, например pq.Array, через
+// driver.Valuer
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+type tx struct{ m *Mock }
+
+func (t tx) Commit() error {
+	_, err := t.m.next(kindCommit, "", nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.m.next(kindRollback, "", nil)
+	return err
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
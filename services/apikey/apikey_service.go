@@ -0,0 +1,167 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"document-approval/models"
+
+	"github.com/lib/pq"
+)
+
+// KeyPrefix отличает API ключи от JWT в заголовке Authorization
+const KeyPrefix = "dak_"
+
+var (
+	ErrInvalidKey  = errors.New("недействительный API ключ")
+	ErrKeyNotFound = errors.New("API ключ не найден")
+)
+
+type APIKeyService struct {
+	db *sql.DB
+}
+
+func NewAPIKeyService(db *sql.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// IsAPIKey сообщает, похоже ли значение на API ключ, а не на JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix)
+}
+
+// CreateKey выпускает новый ключ и возвращает его открытое значение (единственный раз)
+func (s *APIKeyService) CreateKey(userID int64, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("название ключа обязательно")
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("не указаны области действия ключа")
+	}
+	for _, scope := range scopes {
+		if !models.ValidAPIKeyScope(scope) {
+			return nil, "", fmt.Errorf("неизвестная область действия: %s", scope)
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, "", fmt.Errorf("срок действия ключа уже истек")
+	}
+
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", fmt.Errorf("ошибка генерации ключа: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", fmt.Errorf("ошибка генерации ключа: %w", err)
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	plain := KeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+
+	err := s.db.QueryRow(`
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at
+    `, userID, name, prefix, hashKey(plain), pq.Array(scopes), expiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка сохранения ключа: %w", err)
+	}
+
+	return key, plain, nil
+}
+
+// ListKeys возвращает ключи пользователя, включая отозванные
+func (s *APIKeyService) ListKeys(userID int64) ([]models.APIKey, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+        FROM api_keys
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ключей: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		err := rows.Scan(
+			&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+			&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования ключа: %w", err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// RevokeKey отзывает ключ, принадлежащий пользователю
+func (s *APIKeyService) RevokeKey(userID, keyID int64) error {
+	result, err := s.db.Exec(`
+        UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+    `, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва ключа: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate проверяет ключ и отмечает время его последнего использования
+func (s *APIKeyService) Authenticate(plain string) (*models.APIKey, error) {
+	if !IsAPIKey(plain) {
+		return nil, ErrInvalidKey
+	}
+
+	var k models.APIKey
+	err := s.db.QueryRow(`
+        UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+        WHERE key_hash = $1
+          AND revoked_at IS NULL
+          AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+        RETURNING id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+    `, hashKey(plain)).Scan(
+		&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки ключа: %w", err)
+	}
+
+	return &k, nil
+}
+
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
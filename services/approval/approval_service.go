@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"document-approval/models"
+	"document-approval/services/webhook"
+
+	"github.com/lib/pq"
 )
 
 type ApprovalService struct {
@@ -16,22 +19,50 @@ func NewApprovalService(db *sql.DB) *ApprovalService {
 	return &ApprovalService{db: db}
 }
 
+// delegationQuery проверяет, что $1 сейчас замещает утверждающего a.user_id
+const delegationQuery = `
+    SELECT 1 FROM approval_delegations dl
+    WHERE dl.delegator_id = a.user_id AND dl.substitute_id = $1
+      AND dl.revoked_at IS NULL
+      AND CURRENT_TIMESTAMP BETWEEN dl.starts_at AND dl.ends_at
+`
+
+// activeDelegationQuery проверяет, что $1 может голосовать за a.user_id.
+// Заместитель, у которого в процессе есть свое место или который уже
+// голосовал за другого, за замещаемого не голосует: иначе один человек
+// подал бы несколько голосов.
+const activeDelegationQuery = delegationQuery + `
+      AND NOT EXISTS (
+          SELECT 1 FROM approvers o
+          WHERE o.process_id = a.process_id AND o.id <> a.id
+            AND (o.user_id = $1 OR o.acted_by = $1)
+      )
+`
+
 func (s *ApprovalService) GetUserApprovals(userId int64, status string) ([]models.ApprovalProcess, error) {
 	query := `
-        SELECT ap.id, ap.document_id, ap.status, ap.created_at,
-               d.title, d.museum_name, d.file_path
+        SELECT DISTINCT ap.id, ap.document_id, ap.status, ap.current_stage, ap.decision_rule, ap.created_at,
+               ap.document_version, d.title, d.museum_name, COALESCE(v.file_path, d.file_path, '')
         FROM approval_processes ap
         JOIN documents d ON d.id = ap.document_id
+        LEFT JOIN document_versions v ON v.document_id = ap.document_id AND v.version = ap.document_version
         JOIN approvers a ON a.process_id = ap.id
-        WHERE a.user_id = $1
     `
 
 	if status == "pending" {
-		query += " AND a.status = 'Ожидает'"
+		// Свои ожидающие решения и решения замещаемых пользователей
+		query += `
+        WHERE a.status = 'pending'
+          AND ap.status = 'in_progress' AND a.stage = ap.current_stage
+          AND (a.user_id = $1 OR EXISTS (` + activeDelegationQuery + `))`
 	} else {
-		query += " AND a.status IN ('Утверждено', 'Отклонено')"
+		query += `
+        WHERE (a.user_id = $1 OR a.acted_by = $1)
+          AND a.status IN ('approved', 'rejected')`
 	}
 
+	query += " ORDER BY ap.created_at DESC"
+
 	rows, err := s.db.Query(query, userId)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения согласований: %w", err)
@@ -44,8 +75,8 @@ func (s *ApprovalService) GetUserApprovals(userId int64, status string) ([]model
 		var d models.Document
 
 		err := rows.Scan(
-			&p.ID, &p.DocumentID, &p.Status, &p.CreatedAt,
-			&d.Title, &d.MuseumName, &d.FilePath,
+			&p.ID, &p.DocumentID, &p.Status, &p.CurrentStage, &p.DecisionRule, &p.CreatedAt,
+			&p.DocumentVersion, &d.Title, &d.MuseumName, &d.FilePath,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования результатов: %w", err)
@@ -67,12 +98,15 @@ func (s *ApprovalService) GetUserApprovals(userId int64, status string) ([]model
 
 func (s *ApprovalService) getProcessApprovers(processId int64) ([]models.Approver, error) {
 	query := `
-        SELECT a.id, a.user_id, a.status, a.comment, a.approved_at,
-               u.first_name, u.last_name
+        SELECT a.id, a.user_id, a.stage, a.status, COALESCE(a.comment, ''), a.approved_at, a.due_at,
+               u.first_name, u.last_name,
+               a.acted_by, su.first_name, su.last_name, a.reassigned_from,
+               COALESCE(a.signer_subject, ''), a.signed_at
         FROM approvers a
         JOIN users u ON u.id = a.user_id
+        LEFT JOIN users su ON su.id = a.acted_by
         WHERE a.process_id = $1
-        ORDER BY a.id
+        ORDER BY a.stage, a.id
     `
 
 	rows, err := s.db.Query(query, processId)
@@ -85,16 +119,28 @@ func (s *ApprovalService) getProcessApprovers(processId int64) ([]models.Approve
 	for rows.Next() {
 		var a models.Approver
 		var u models.User
+		var actedFirstName, actedLastName sql.NullString
 
 		err := rows.Scan(
-			&a.ID, &a.UserID, &a.Status, &a.Comment, &a.ApprovedAt,
+			&a.ID, &a.UserID, &a.Stage, &a.Status, &a.Comment, &a.ApprovedAt, &a.DueAt,
 			&u.FirstName, &u.LastName,
+			&a.ActedBy, &actedFirstName, &actedLastName, &a.ReassignedFrom,
+			&a.SignerSubject, &a.SignedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования утверждающего: %w", err)
 		}
 
+		u.ID = a.UserID
+		a.ProcessID = processId
 		a.User = &u
+		if a.ActedBy != nil {
+			a.ActedByUser = &models.User{
+				ID:        *a.ActedBy,
+				FirstName: actedFirstName.String,
+				LastName:  actedLastName.String,
+			}
+		}
 		approvers = append(approvers, a)
 	}
 
@@ -102,23 +148,21 @@ func (s *ApprovalService) getProcessApprovers(processId int64) ([]models.Approve
 }
 
 func (s *ApprovalService) GetApprovalDetails(processId int64) (*models.ApprovalProcess, error) {
+	// Файл документа - закрепленная за процессом версия, а не текущая
 	query := `
-        SELECT ap.id, ap.document_id, ap.status, ap.created_at,
-               d.title, d.museum_name, d.file_path
+        SELECT ` + processColumns + `,
+               d.title, d.museum_name, COALESCE(v.file_path, d.file_path, '')
         FROM approval_processes ap
         JOIN documents d ON d.id = ap.document_id
+        LEFT JOIN document_versions v ON v.document_id = ap.document_id AND v.version = ap.document_version
         WHERE ap.id = $1
     `
 
-	var p models.ApprovalProcess
 	var d models.Document
 
-	err := s.db.QueryRow(query, processId).Scan(
-		&p.ID, &p.DocumentID, &p.Status, &p.CreatedAt,
-		&d.Title, &d.MuseumName, &d.FilePath,
-	)
+	p, err := scanProcess(s.db.QueryRow(query, processId), &d.Title, &d.MuseumName, &d.FilePath)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка получения процесса: %w", err)
+		return nil, err
 	}
 
 	approvers, err := s.getProcessApprovers(processId)
@@ -129,7 +173,7 @@ func (s *ApprovalService) GetApprovalDetails(processId int64) (*models.ApprovalP
 	p.Document = &d
 	p.Approvers = approvers
 
-	return &p, nil
+	return p, nil
 }
 
 func (s *ApprovalService) GetApprovalProcesses() ([]models.ApprovalProcess, error) {
@@ -166,51 +210,154 @@ func (s *ApprovalService) GetApprovalProcesses() ([]models.ApprovalProcess, erro
 	return processes, nil
 }
 
-func (s *ApprovalService) StartApprovalProcess(documentID int64, approverIDs []int64) error {
+// StartApprovalProcess запускает согласование по этапам. Этапы проходят
+// последовательно, утверждающие внутри этапа работают параллельно.
+// Если у документа уже были процессы, новый становится следующим кругом.
+func (s *ApprovalService) StartApprovalProcess(documentID, initiatorID int64, route models.ApprovalRoute) (int64, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("ошибка начала транзакции: %w", err)
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Создаем процесс утверждения
+	processID, err := s.startProcess(tx, documentID, initiatorID, route)
+	if err != nil {
+		return 0, err
+	}
+
+	return processID, tx.Commit()
+}
+
+func (s *ApprovalService) startProcess(tx *sql.Tx, documentID, initiatorID int64, route models.ApprovalRoute) (int64, error) {
+	if route.DecisionRule == "" {
+		route.DecisionRule = models.RuleUnanimous
+	}
+
+	var approverIDs []int64
+	for i := range route.Stages {
+		stage := &route.Stages[i]
+		if len(stage.ApproverIDs) == 0 {
+			return 0, fmt.Errorf("этап %d не содержит утверждающих", i+1)
+		}
+		if stage.DecisionRule == "" {
+			stage.DecisionRule = route.DecisionRule
+			stage.Quorum = route.Quorum
+		}
+		if err := ValidateRule(stage.DecisionRule, stage.Quorum, len(stage.ApproverIDs)); err != nil {
+			return 0, fmt.Errorf("этап %d: %w", i+1, err)
+		}
+		approverIDs = append(approverIDs, stage.ApproverIDs...)
+	}
+	if len(approverIDs) == 0 {
+		return 0, fmt.Errorf("не указаны утверждающие")
+	}
+	if len(uniqueIDs(approverIDs)) != len(approverIDs) {
+		return 0, fmt.Errorf("утверждающий не может участвовать в согласовании дважды")
+	}
+
+	// Отключенные пользователи не могут быть утверждающими
+	var activeCount int
+	err := tx.QueryRow(`
+        SELECT COUNT(DISTINCT id) FROM users WHERE id = ANY($1) AND is_active
+    `, pq.Array(approverIDs)).Scan(&activeCount)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка проверки утверждающих: %w", err)
+	}
+	if activeCount != len(approverIDs) {
+		return 0, fmt.Errorf("среди утверждающих есть неизвестные или отключенные пользователи")
+	}
+
+	// Смена статуса блокирует документ и не дает запустить два процесса сразу
+	if err := setDocumentStatus(tx, documentID, models.StatusInReview, &initiatorID); err != nil {
+		return 0, err
+	}
+
+	// Предыдущий процесс документа, если есть, - прошлый круг согласования
+	var previousID sql.NullInt64
+	var previousRound int
+	err = tx.QueryRow(`
+        SELECT id, round FROM approval_processes
+        WHERE document_id = $1
+        ORDER BY round DESC, id DESC
+        LIMIT 1
+    `, documentID).Scan(&previousID, &previousRound)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("ошибка получения предыдущего процесса: %w", err)
+	}
+
+	// Создаем процесс утверждения. Процесс закрепляет текущую версию файла:
+	// согласуется именно она, даже если позже загрузят новую.
 	var processID int64
+	var documentVersion *int
 	err = tx.QueryRow(`
-        INSERT INTO approval_processes (document_id, status, created_at)
-        VALUES ($1, 'В процессе', $2)
-        RETURNING id
-    `, documentID, time.Now()).Scan(&processID)
+        INSERT INTO approval_processes (document_id, status, created_at, current_stage,
+                                        decision_rule, quorum, template_id, template_version,
+                                        initiated_by, round, previous_process_id, document_version)
+        VALUES ($1, 'in_progress', $2, 1, $3, NULLIF($4, 0), $5, NULLIF($6, 0), $7, $8, $9,
+                (SELECT current_version FROM documents WHERE id = $1))
+        RETURNING id, document_version
+    `, documentID, time.Now(), route.DecisionRule, route.Quorum,
+		route.TemplateID, route.TemplateVersion,
+		initiatorID, previousRound+1, previousID).Scan(&processID, &documentVersion)
 
 	if err != nil {
-		return fmt.Errorf("ошибка создания процесса: %w", err)
+		return 0, fmt.Errorf("ошибка создания процесса: %w", err)
 	}
 
-	// Добавляем утверждающих
-	for _, approverID := range approverIDs {
+	// Добавляем этапы и утверждающих
+	for i, stage := range route.Stages {
 		_, err = tx.Exec(`
-            INSERT INTO approvers (process_id, user_id, status)
-            VALUES ($1, $2, 'Ожидает')
-        `, processID, approverID)
+            INSERT INTO approval_stages (process_id, stage, decision_rule, quorum, due_hours)
+            VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0))
+        `, processID, i+1, stage.DecisionRule, stage.Quorum, stage.DueHours)
 
 		if err != nil {
-			return fmt.Errorf("ошибка добавления утверждающего: %w", err)
+			return 0, fmt.Errorf("ошибка добавления этапа: %w", err)
+		}
+
+		for _, approverID := range stage.ApproverIDs {
+			_, err = tx.Exec(`
+                INSERT INTO approvers (process_id, user_id, status, stage)
+                VALUES ($1, $2, 'pending', $3)
+            `, processID, approverID, i+1)
+
+			if err != nil {
+				return 0, fmt.Errorf("ошибка добавления утверждающего: %w", err)
+			}
 		}
 	}
 
-	// Обновляем статус документа
-	_, err = tx.Exec(`
-        UPDATE documents SET status = 'На утверждении'
-        WHERE id = $1
-    `, documentID)
+	if err := s.openStage(tx, processID, 1); err != nil {
+		return 0, err
+	}
+
+	details := map[string]any{"round": previousRound + 1}
+	if previousID.Valid {
+		details["previous_process_id"] = previousID.Int64
+	}
+	if documentVersion != nil {
+		details["document_version"] = *documentVersion
+	}
+	if err := recordHistory(tx, processID, nil, models.HistoryStarted, &initiatorID, details); err != nil {
+		return 0, err
+	}
 
+	err = webhook.EmitForProcess(tx, processID, models.WebhookApprovalStarted, map[string]any{
+		"initiated_by":     initiatorID,
+		"stages":           len(route.Stages),
+		"document_version": documentVersion,
+	})
 	if err != nil {
-		return fmt.Errorf("ошибка обновления статуса документа: %w", err)
+		return 0, err
 	}
 
-	return tx.Commit()
+	return processID, nil
 }
 
-func (s *ApprovalService) ApproveDocument(processID int64, userID int64, approved bool, comment string) error {
+// ApproveDocument записывает решение пользователя (или его заместителя)
+// и продвигает процесс по этапам. sig - проверенная подпись решения, если
+// утверждающий его подписал.
+func (s *ApprovalService) ApproveDocument(processID int64, userID int64, approved bool, comment string, sig *models.DecisionSignature) error {
 	// Начинаем транзакцию
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -218,93 +365,272 @@ func (s *ApprovalService) ApproveDocument(processID int64, userID int64, approve
 	}
 	defer tx.Rollback()
 
-	// Проверяем, что пользователь является утверждающим для данного процесса
-	var exists bool
-	err = tx.QueryRow(`
-        SELECT EXISTS (
-            SELECT 1 FROM approvers 
-            WHERE process_id = $1 AND user_id = $2 AND status = 'Ожидает'
-        )
-    `, processID, userID).Scan(&exists)
-
+	// Решения по одному процессу принимаются последовательно
+	processStatus, _, err := lockProcess(tx, processID)
 	if err != nil {
-		return fmt.Errorf("ошибка проверки прав пользователя: %w", err)
+		return err
+	}
+	if processStatus != models.ProcessStatusInProgress {
+		return ErrProcessNotActive
 	}
 
-	if !exists {
-		return fmt.Errorf("пользователь не имеет прав на утверждение")
+	// Ищем ожидающее решение пользователя на открытом этапе, а если его нет -
+	// решение замещаемого им утверждающего. Собственное решение имеет приоритет.
+	var approverID, approverUserID int64
+	var stage int
+	err = tx.QueryRow(`
+        SELECT a.id, a.user_id, a.stage FROM approvers a
+        JOIN approval_processes ap ON ap.id = a.process_id
+        WHERE a.process_id = $2 AND a.status = 'pending'
+          AND ap.status = 'in_progress' AND a.stage = ap.current_stage
+          AND (a.user_id = $1 OR EXISTS (`+activeDelegationQuery+`))
+        ORDER BY (a.user_id = $1) DESC, a.id
+        LIMIT 1
+        FOR UPDATE OF a
+    `, userID, processID).Scan(&approverID, &approverUserID, &stage)
+
+	if err == sql.ErrNoRows {
+		return notAssignedError(tx, processID, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки прав пользователя: %w", err)
 	}
 
 	// Обновляем статус утверждающего
-	status := "Утверждено"
+	status := models.ApproverStatusApproved
 	if !approved {
-		status = "Отклонено"
+		status = models.ApproverStatusRejected
+	}
+	if err := CheckTransition(EntityApprover, models.ApproverStatusPending, status); err != nil {
+		return err
 	}
 
 	_, err = tx.Exec(`
         UPDATE approvers
-        SET status = $1, comment = $2, approved_at = CURRENT_TIMESTAMP
-        WHERE process_id = $3 AND user_id = $4
-    `, status, comment, processID, userID)
+        SET status = $1, comment = $2, approved_at = CURRENT_TIMESTAMP, acted_by = $3
+        WHERE id = $4
+    `, status, comment, userID, approverID)
 
 	if err != nil {
 		return fmt.Errorf("ошибка обновления статуса утверждающего: %w", err)
 	}
 
-	// Проверяем, все ли утвердили документ
-	var allApproved bool
+	details := map[string]any{
+		"user_id":  approverUserID,
+		"stage":    stage,
+		"decision": status,
+		"comment":  comment,
+	}
+
+	if sig != nil {
+		_, err = tx.Exec(`
+            UPDATE approvers
+            SET signature = $1, signature_payload = $2, signature_file_hash = $3,
+                signer_subject = $4, signer_serial = $5, signed_at = $6
+            WHERE id = $7
+        `, sig.Signature, sig.Payload, sig.FileHash, sig.SignerSubject, sig.SignerSerial, sig.SignedAt, approverID)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения подписи решения: %w", err)
+		}
+		details["signer_subject"] = sig.SignerSubject
+		details["file_sha256"] = sig.FileHash
+	}
+
+	err = recordHistory(tx, processID, &approverID, models.HistoryDecided, &userID, details)
+	if err != nil {
+		return err
+	}
+
+	err = webhook.EmitForProcess(tx, processID, models.WebhookApprovalDecided, map[string]any{
+		"approver_id": approverID,
+		"user_id":     approverUserID,
+		"acted_by":    userID,
+		"stage":       stage,
+		"decision":    status,
+		"comment":     comment,
+		"signed":      sig != nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.advanceProcess(tx, processID, &userID); err != nil {
+		return err
+	}
+
+	// Публикуем после продвижения процесса, чтобы в событии был итоговый статус документа
+	if err := notifyDecided(tx, processID, userID, status, comment); err != nil {
+		return err
+	}
+	if err := notifyMentioned(tx, processID, userID, comment); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// advanceProcess подводит итог текущего этапа по его правилу: если итог
+// определен, закрывает оставшиеся решения этапа и открывает следующий этап
+// или завершает процесс. actorID - пользователь, чье решение продвинуло процесс.
+func (s *ApprovalService) advanceProcess(tx *sql.Tx, processID int64, actorID *int64) error {
+	var currentStage int
+	err := tx.QueryRow(`
+        SELECT current_stage FROM approval_processes WHERE id = $1 FOR UPDATE
+    `, processID).Scan(&currentStage)
+	if err != nil {
+		return fmt.Errorf("ошибка получения процесса: %w", err)
+	}
+
+	var rule string
+	var quorum sql.NullInt64
 	err = tx.QueryRow(`
-        SELECT NOT EXISTS (
-            SELECT 1 FROM approvers 
-            WHERE process_id = $1 AND status = 'Ожидает'
-        )
-    `, processID).Scan(&allApproved)
+        SELECT decision_rule, quorum FROM approval_stages
+        WHERE process_id = $1 AND stage = $2
+    `, processID, currentStage).Scan(&rule, &quorum)
+	if err == sql.ErrNoRows {
+		rule = models.RuleUnanimous
+	} else if err != nil {
+		return fmt.Errorf("ошибка получения правила этапа: %w", err)
+	}
 
+	var tally StageTally
+	err = tx.QueryRow(`
+        SELECT COUNT(*),
+               COUNT(*) FILTER (WHERE status = 'approved'),
+               COUNT(*) FILTER (WHERE status = 'rejected'),
+               COUNT(*) FILTER (WHERE status = 'pending')
+        FROM approvers
+        WHERE process_id = $1 AND stage = $2
+    `, processID, currentStage).Scan(&tally.Total, &tally.Approved, &tally.Rejected, &tally.Pending)
 	if err != nil {
 		return fmt.Errorf("ошибка проверки статусов: %w", err)
 	}
 
-	if allApproved {
-		// Проверяем, есть ли отклонения
-		var hasRejections bool
-		err = tx.QueryRow(`
-            SELECT EXISTS (
-                SELECT 1 FROM approvers 
-                WHERE process_id = $1 AND status = 'Отклонено'
-            )
-        `, processID).Scan(&hasRejections)
+	outcome := EvaluateStage(rule, int(quorum.Int64), tally)
+	if outcome == OutcomePending {
+		return nil
+	}
 
-		if err != nil {
-			return fmt.Errorf("ошибка проверки отклонений: %w", err)
-		}
+	// Итог этапа определен, оставшиеся решения этапа больше не нужны
+	if err := s.skipPending(tx, processID, currentStage, currentStage); err != nil {
+		return err
+	}
 
-		// Обновляем статус процесса и документа
-		finalStatus := "Утвержден"
-		if hasRejections {
-			finalStatus = "Отклонен"
+	// Отклоненный этап отклоняет весь процесс, следующие этапы не открываются
+	if outcome == OutcomeRejected {
+		if err := s.skipPending(tx, processID, currentStage+1, 0); err != nil {
+			return err
 		}
+		return s.finishProcess(tx, processID, models.StatusRejected, actorID)
+	}
 
-		_, err = tx.Exec(`
-            UPDATE approval_processes
-            SET status = 'Завершен'
-            WHERE id = $1
-        `, processID)
+	var nextStage sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT MIN(stage) FROM approvers WHERE process_id = $1 AND stage > $2
+    `, processID, currentStage).Scan(&nextStage)
+	if err != nil {
+		return fmt.Errorf("ошибка поиска следующего этапа: %w", err)
+	}
 
-		if err != nil {
-			return fmt.Errorf("ошибка обновления статуса процесса: %w", err)
-		}
+	if !nextStage.Valid {
+		return s.finishProcess(tx, processID, models.StatusApproved, actorID)
+	}
 
-		_, err = tx.Exec(`
-            UPDATE documents d
-            SET status = $1
-            FROM approval_processes ap
-            WHERE ap.id = $2 AND ap.document_id = d.id
-        `, finalStatus, processID)
+	_, err = tx.Exec(`
+        UPDATE approval_processes SET current_stage = $1 WHERE id = $2
+    `, nextStage.Int64, processID)
+	if err != nil {
+		return fmt.Errorf("ошибка перехода к следующему этапу: %w", err)
+	}
 
-		if err != nil {
-			return fmt.Errorf("ошибка обновления статуса документа: %w", err)
-		}
+	return s.openStage(tx, processID, int(nextStage.Int64))
+}
+
+// openStage выставляет сроки утверждающим открывшегося этапа: срок этапа
+// из маршрута, а если он не задан - срок исполнения документа, и ставит
+// в очередь письма о назначении
+func (s *ApprovalService) openStage(tx *sql.Tx, processID int64, stage int) error {
+	_, err := tx.Exec(`
+        UPDATE approvers a
+        SET due_at = CASE
+            WHEN st.due_hours IS NOT NULL THEN CURRENT_TIMESTAMP + st.due_hours * INTERVAL '1 hour'
+            ELSE d.deadline_date
+        END
+        FROM approval_processes ap
+        JOIN documents d ON d.id = ap.document_id
+        LEFT JOIN approval_stages st ON st.process_id = ap.id AND st.stage = $2
+        WHERE ap.id = $1 AND a.process_id = ap.id AND a.stage = $2
+    `, processID, stage)
+	if err != nil {
+		return fmt.Errorf("ошибка установки сроков этапа: %w", err)
 	}
+	return notifyAssigned(tx, processID, nil)
+}
 
-	return tx.Commit()
+// skipPending закрывает ожидающие решения на этапах с fromStage по toStage
+// (toStage = 0 - до последнего этапа) статусом "Пропущено"
+func (s *ApprovalService) skipPending(tx *sql.Tx, processID int64, fromStage, toStage int) error {
+	_, err := tx.Exec(`
+        UPDATE approvers SET status = 'skipped'
+        WHERE process_id = $1 AND status = 'pending'
+          AND stage >= $2 AND ($3 = 0 OR stage <= $3)
+    `, processID, fromStage, toStage)
+	if err != nil {
+		return fmt.Errorf("ошибка закрытия ожидающих решений: %w", err)
+	}
+	return nil
+}
+
+// finishProcess завершает процесс и выставляет итоговый статус документа
+func (s *ApprovalService) finishProcess(tx *sql.Tx, processID int64, documentStatus string, actorID *int64) error {
+	documentID, err := setProcessStatus(tx, processID, models.ProcessStatusCompleted)
+	if err != nil {
+		return err
+	}
+
+	if err := setDocumentStatus(tx, documentID, documentStatus, actorID); err != nil {
+		return err
+	}
+
+	err = webhook.EmitForProcess(tx, processID, models.WebhookApprovalCompleted, map[string]any{
+		"result": documentStatus,
+	})
+	if err != nil {
+		return err
+	}
+
+	return notifyFinished(tx, processID, documentStatus)
+}
+
+// notAssignedError объясняет, почему у пользователя нет решения в процессе:
+// замещение есть, но голосовать по нему нельзя, или пользователь не назначен
+func notAssignedError(tx *sql.Tx, processID, userID int64) error {
+	var substitute bool
+	err := tx.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM approvers a
+            JOIN approval_processes ap ON ap.id = a.process_id
+            WHERE a.process_id = $2 AND a.status = 'pending' AND a.stage = ap.current_stage
+              AND EXISTS (`+delegationQuery+`)
+        )
+    `, userID, processID).Scan(&substitute)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки прав пользователя: %w", err)
+	}
+	if substitute {
+		return ErrSubstituteVoted
+	}
+	return ErrNotAssigned
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var result []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
 }
@@ -0,0 +1,357 @@
+package approval
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"document-approval/models"
+	"document-approval/services/events"
+	"document-approval/services/mail"
+	"document-approval/services/notification"
+)
+
+// deadlineLockKey - ключ advisory lock. Сроки в каждый момент проверяет
+// только одна реплика, остальные пропускают свой запуск.
+const deadlineLockKey = 730013
+
+// DeadlineConfig - настройки планировщика сроков согласования
+type DeadlineConfig struct {
+	Interval      time.Duration // как часто проверять сроки
+	RemindBefore  time.Duration // за сколько до срока напоминать утверждающему
+	EscalationDue time.Duration // срок решения после эскалации, если у этапа нет своего
+}
+
+// RunDeadlineScheduler периодически рассылает напоминания и эскалирует
+// просроченные решения, пока не отменен контекст
+func (s *ApprovalService) RunDeadlineScheduler(ctx context.Context, cfg DeadlineConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.CheckDeadlines(cfg); err != nil {
+			log.Printf("Ошибка проверки сроков согласования: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckDeadlines выполняет один проход планировщика. Проход идет в одной
+// транзакции под advisory lock, но каждый утверждающий обрабатывается в своей
+// точке сохранения: ошибка на одной строке не откатывает остальные.
+func (s *ApprovalService) CheckDeadlines(cfg DeadlineConfig) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, deadlineLockKey).Scan(&locked); err != nil {
+		return fmt.Errorf("ошибка получения блокировки: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	if err := s.sendReminders(tx, cfg.RemindBefore); err != nil {
+		return err
+	}
+	if err := s.escalateOverdue(tx, cfg.EscalationDue); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// eachApprover вызывает fn для каждого утверждающего в отдельной точке
+// сохранения. Если fn вернула ошибку, изменения этого утверждающего
+// откатываются, ошибка пишется в лог, и проход продолжается - строка будет
+// обработана повторно на следующем проходе.
+func eachApprover(tx *sql.Tx, list []dueApprover, action string, fn func(dueApprover) error) error {
+	for _, d := range list {
+		if _, err := tx.Exec(`SAVEPOINT deadline_approver`); err != nil {
+			return fmt.Errorf("ошибка создания точки сохранения: %w", err)
+		}
+
+		if err := fn(d); err != nil {
+			log.Printf("Ошибка (%s): процесс %d, утверждающий %d: %v", action, d.processID, d.userID, err)
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT deadline_approver`); err != nil {
+				return fmt.Errorf("ошибка отката к точке сохранения: %w", err)
+			}
+			continue
+		}
+
+		if _, err := tx.Exec(`RELEASE SAVEPOINT deadline_approver`); err != nil {
+			return fmt.Errorf("ошибка освобождения точки сохранения: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetProcessHistory возвращает историю процесса в хронологическом порядке
+func (s *ApprovalService) GetProcessHistory(processID int64) ([]models.ApprovalHistoryEntry, error) {
+	rows, err := s.db.Query(`
+        SELECT id, process_id, approver_id, action, actor_id, details, created_at
+        FROM approval_history
+        WHERE process_id = $1
+        ORDER BY created_at, id
+    `, processID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории процесса: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.ApprovalHistoryEntry
+	for rows.Next() {
+		var h models.ApprovalHistoryEntry
+		var details []byte
+
+		err := rows.Scan(&h.ID, &h.ProcessID, &h.ApproverID, &h.Action, &h.ActorID, &details, &h.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования истории: %w", err)
+		}
+		if err := json.Unmarshal(details, &h.Details); err != nil {
+			return nil, fmt.Errorf("ошибка разбора истории: %w", err)
+		}
+		history = append(history, h)
+	}
+
+	return history, nil
+}
+
+type dueApprover struct {
+	id        int64
+	processID int64
+	userID    int64
+	dueAt     time.Time
+	managerID sql.NullInt64
+}
+
+// sendReminders находит утверждающих, у которых скоро срок, и каждому
+// отправляет напоминание
+func (s *ApprovalService) sendReminders(tx *sql.Tx, remindBefore time.Duration) error {
+	rows, err := tx.Query(`
+        SELECT a.id, a.process_id, a.user_id, a.due_at
+        FROM approvers a
+        JOIN approval_processes ap ON ap.id = a.process_id
+        WHERE ap.status = 'in_progress' AND a.stage = ap.current_stage
+          AND a.status = 'pending' AND a.reminded_at IS NULL
+          AND a.due_at > CURRENT_TIMESTAMP
+          AND a.due_at <= CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+        ORDER BY a.due_at
+        FOR UPDATE OF a SKIP LOCKED
+    `, remindBefore.Seconds())
+	if err != nil {
+		return fmt.Errorf("ошибка поиска сроков для напоминаний: %w", err)
+	}
+
+	var due []dueApprover
+	for rows.Next() {
+		var d dueApprover
+		if err := rows.Scan(&d.id, &d.processID, &d.userID, &d.dueAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования утверждающего: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+
+	return eachApprover(tx, due, "напоминание", func(d dueApprover) error {
+		return remind(tx, d)
+	})
+}
+
+// remind отмечает напоминание утверждающему, пишет его в историю, создает
+// уведомление в приложении и ставит письмо в очередь
+func remind(tx *sql.Tx, d dueApprover) error {
+	_, err := tx.Exec(`UPDATE approvers SET reminded_at = CURRENT_TIMESTAMP WHERE id = $1`, d.id)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки напоминания: %w", err)
+	}
+
+	err = recordHistory(tx, d.processID, &d.id, models.HistoryReminderSent, nil, map[string]any{
+		"user_id": d.userID,
+		"due_at":  d.dueAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	documentID, _, err := processDocument(tx, d.processID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{"due_at": d.dueAt.Format(dueLayout)}
+	err = notification.Create(tx, models.Notification{
+		UserID:     d.userID,
+		Type:       models.NotificationReminder,
+		DocumentID: &documentID,
+		ProcessID:  &d.processID,
+		Data:       data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return mail.Enqueue(tx, d.userID, d.processID, models.NotifyReminder, data)
+}
+
+// escalateOverdue передает просроченные решения руководителю утверждающего,
+// а если его нет или он уже участвует в процессе - администратору.
+// Решение, которое некому передать, отмечается escalated_at и больше не
+// выбирается.
+// dueAfter - срок нового утверждающего, если у этапа нет своего.
+func (s *ApprovalService) escalateOverdue(tx *sql.Tx, dueAfter time.Duration) error {
+	rows, err := tx.Query(`
+        SELECT a.id, a.process_id, a.user_id, a.due_at, u.manager_id
+        FROM approvers a
+        JOIN approval_processes ap ON ap.id = a.process_id
+        JOIN users u ON u.id = a.user_id
+        WHERE ap.status = 'in_progress' AND a.stage = ap.current_stage
+          AND a.status = 'pending' AND a.escalated_at IS NULL
+          AND a.due_at < CURRENT_TIMESTAMP
+        ORDER BY a.due_at
+        FOR UPDATE OF a SKIP LOCKED
+    `)
+	if err != nil {
+		return fmt.Errorf("ошибка поиска просроченных решений: %w", err)
+	}
+
+	var overdue []dueApprover
+	for rows.Next() {
+		var d dueApprover
+		if err := rows.Scan(&d.id, &d.processID, &d.userID, &d.dueAt, &d.managerID); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования утверждающего: %w", err)
+		}
+		overdue = append(overdue, d)
+	}
+	rows.Close()
+
+	return eachApprover(tx, overdue, "эскалация", func(d dueApprover) error {
+		return s.escalate(tx, d, dueAfter)
+	})
+}
+
+func (s *ApprovalService) escalate(tx *sql.Tx, d dueApprover, dueAfter time.Duration) error {
+	target, reason, err := escalationTarget(tx, d)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]any{
+		"from_user_id": d.userID,
+		"due_at":       d.dueAt,
+	}
+
+	if target == 0 {
+		// Передать некому: отмечаем эскалацию, чтобы не повторять ее на каждом проходе
+		_, err = tx.Exec(`UPDATE approvers SET escalated_at = CURRENT_TIMESTAMP WHERE id = $1`, d.id)
+		details["reason"] = "no_target"
+		log.Printf("Эскалация: процесс %d, утверждающий %d - некому передать решение", d.processID, d.userID)
+	} else {
+		// Новый утверждающий получает полный срок этапа и свое напоминание.
+		// escalated_at сбрасывается: отметка относится к назначению, и если
+		// новый утверждающий тоже пропустит срок, решение эскалируется дальше.
+		_, err = tx.Exec(`
+            UPDATE approvers a
+            SET reassigned_from = user_id, user_id = $1, escalated_at = NULL,
+                reminded_at = NULL,
+                due_at = CURRENT_TIMESTAMP + COALESCE((
+                    SELECT st.due_hours * INTERVAL '1 hour' FROM approval_stages st
+                    WHERE st.process_id = a.process_id AND st.stage = a.stage
+                ), $3 * INTERVAL '1 second')
+            WHERE id = $2
+        `, target, d.id, dueAfter.Seconds())
+		details["to_user_id"] = target
+		details["reason"] = reason
+		log.Printf("Эскалация: процесс %d, решение передано от %d к %d", d.processID, d.userID, target)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка эскалации решения: %w", err)
+	}
+
+	if err := recordHistory(tx, d.processID, &d.id, models.HistoryEscalated, nil, details); err != nil {
+		return err
+	}
+	if target == 0 {
+		return nil
+	}
+	return notifyAssigned(tx, d.processID, &d.id)
+}
+
+// escalationTarget выбирает, кому передать просроченное решение.
+// Возвращает 0, если подходящего пользователя нет.
+func escalationTarget(tx *sql.Tx, d dueApprover) (int64, string, error) {
+	notInProcess := `
+        u.is_active AND NOT EXISTS (
+            SELECT 1 FROM approvers x WHERE x.process_id = $1 AND x.user_id = u.id
+        )
+    `
+
+	if d.managerID.Valid {
+		var ok bool
+		err := tx.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM users u WHERE u.id = $2 AND `+notInProcess+`)
+        `, d.processID, d.managerID.Int64).Scan(&ok)
+		if err != nil {
+			return 0, "", fmt.Errorf("ошибка проверки руководителя: %w", err)
+		}
+		if ok {
+			return d.managerID.Int64, "manager", nil
+		}
+	}
+
+	var adminID int64
+	err := tx.QueryRow(`
+        SELECT u.id FROM users u
+        JOIN user_roles ur ON ur.user_id = u.id AND ur.role = $2
+        WHERE `+notInProcess+`
+        ORDER BY u.id
+        LIMIT 1
+    `, d.processID, models.RoleAdmin).Scan(&adminID)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("ошибка поиска администратора: %w", err)
+	}
+
+	return adminID, "admin", nil
+}
+
+// recordHistory добавляет событие в историю процесса и в историю его документа
+// в рамках транзакции
+func recordHistory(tx *sql.Tx, processID int64, approverID *int64, action string, actorID *int64, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO approval_history (process_id, approver_id, action, actor_id, details)
+        VALUES ($1, $2, $3, $4, $5)
+    `, processID, approverID, action, actorID, data)
+	if err != nil {
+		return fmt.Errorf("ошибка записи истории процесса: %w", err)
+	}
+
+	if approverID != nil {
+		details["approver_id"] = *approverID
+	}
+	return events.RecordForProcess(tx, processID, action, actorID, details)
+}
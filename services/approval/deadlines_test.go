@@ -0,0 +1,184 @@
+package approval
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"document-approval/models"
+	"document-approval/pkg/sqltest"
+)
+
+var (
+	dueColumns     = []string{"id", "process_id", "user_id", "due_at"}
+	overdueColumns = []string{"id", "process_id", "user_id", "due_at", "manager_id"}
+)
+
+var testDeadlines = DeadlineConfig{
+	Interval:      time.Minute,
+	RemindBefore:  24 * time.Hour,
+	EscalationDue: 48 * time.Hour,
+}
+
+func expectLock(mock *sqltest.Mock, locked bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock($1)").WithArgs(deadlineLockKey).
+		WillReturnRows([]string{"locked"}, []any{locked})
+}
+
+// expectHistory ждет записи в историю процесса и в историю документа
+func expectHistory(mock *sqltest.Mock, processID int64) {
+	mock.ExpectExec("INSERT INTO approval_history").WithArgs(processID, sqltest.Any, sqltest.Any, sqltest.Any, sqltest.Any)
+	mock.ExpectExec("INSERT INTO document_events").WithArgs(processID, sqltest.Any, sqltest.Any, sqltest.Any)
+}
+
+func TestCheckDeadlinesSkipsWhenLocked(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewApprovalService(db)
+
+	// Сроки уже проверяет другая реплика: ни напоминаний, ни эскалаций
+	expectLock(mock, false)
+	mock.ExpectRollback()
+
+	if err := s.CheckDeadlines(testDeadlines); err != nil {
+		t.Fatalf("CheckDeadlines: %v", err)
+	}
+}
+
+func TestCheckDeadlinesContinuesAfterFailure(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewApprovalService(db)
+	due := time.Now().Add(time.Hour)
+
+	expectLock(mock, true)
+	mock.ExpectQuery("a.reminded_at IS NULL").WithArgs(testDeadlines.RemindBefore.Seconds()).
+		WillReturnRows(dueColumns,
+			[]any{int64(1), int64(10), int64(5), due},
+			[]any{int64(2), int64(11), int64(6), due},
+		)
+
+	// Первое напоминание падает и откатывается к точке сохранения
+	mock.ExpectExec("SAVEPOINT deadline_approver")
+	mock.ExpectExec("UPDATE approvers SET reminded_at = CURRENT_TIMESTAMP WHERE id = $1").WithArgs(int64(1))
+	mock.ExpectExec("INSERT INTO approval_history").WillReturnError(errors.New("нарушено ограничение"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT deadline_approver")
+
+	// Второе проходит целиком
+	mock.ExpectExec("SAVEPOINT deadline_approver")
+	mock.ExpectExec("UPDATE approvers SET reminded_at = CURRENT_TIMESTAMP WHERE id = $1").WithArgs(int64(2))
+	expectHistory(mock, 11)
+	mock.ExpectQuery("SELECT d.id, d.title FROM approval_processes ap").WithArgs(int64(11)).
+		WillReturnRows([]string{"id", "title"}, []any{int64(100), "Отчет за 2 квартал"})
+	mock.ExpectQuery("SELECT u.is_active, COALESCE(p.language, 'ru')").WithArgs(int64(6)).
+		WillReturnRows([]string{"is_active", "language"})
+	mock.ExpectQuery("SELECT COALESCE(u.email, '')").WithArgs(int64(6)).
+		WillReturnRows([]string{"email", "language", "enabled"})
+	mock.ExpectExec("RELEASE SAVEPOINT deadline_approver")
+
+	mock.ExpectQuery("a.escalated_at IS NULL").WillReturnRows(overdueColumns)
+	mock.ExpectCommit()
+
+	if err := s.CheckDeadlines(testDeadlines); err != nil {
+		t.Fatalf("CheckDeadlines: %v", err)
+	}
+}
+
+func TestCheckDeadlinesEscalationResetsMark(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewApprovalService(db)
+
+	expectLock(mock, true)
+	mock.ExpectQuery("a.reminded_at IS NULL").WillReturnRows(dueColumns)
+	mock.ExpectQuery("a.escalated_at IS NULL").
+		WillReturnRows(overdueColumns, []any{int64(3), int64(12), int64(7), time.Now().Add(-time.Hour), int64(8)})
+
+	mock.ExpectExec("SAVEPOINT deadline_approver")
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users u WHERE u.id = $2").WithArgs(int64(12), int64(8)).
+		WillReturnRows([]string{"exists"}, []any{true})
+	// Отметка эскалации сбрасывается для нового назначения, чтобы руководитель,
+	// пропустивший свой срок, тоже был эскалирован
+	mock.ExpectExec("SET reassigned_from = user_id, user_id = $1, escalated_at = NULL").
+		WithArgs(int64(8), int64(3), testDeadlines.EscalationDue.Seconds())
+	expectHistory(mock, 12)
+	mock.ExpectQuery("SELECT a.user_id, a.due_at FROM approvers a").WithArgs(int64(12), int64(3)).
+		WillReturnRows([]string{"user_id", "due_at"})
+	mock.ExpectExec("RELEASE SAVEPOINT deadline_approver")
+	mock.ExpectCommit()
+
+	if err := s.CheckDeadlines(testDeadlines); err != nil {
+		t.Fatalf("CheckDeadlines: %v", err)
+	}
+}
+
+func TestCheckDeadlinesMarksUnassignable(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewApprovalService(db)
+
+	expectLock(mock, true)
+	mock.ExpectQuery("a.reminded_at IS NULL").WillReturnRows(dueColumns)
+	mock.ExpectQuery("a.escalated_at IS NULL").
+		WillReturnRows(overdueColumns, []any{int64(3), int64(12), int64(7), time.Now().Add(-time.Hour), nil})
+
+	mock.ExpectExec("SAVEPOINT deadline_approver")
+	mock.ExpectQuery("JOIN user_roles ur").WithArgs(int64(12), models.RoleAdmin).WillReturnRows([]string{"id"})
+	// Передать некому: отметка остается, решение больше не выбирается
+	mock.ExpectExec("UPDATE approvers SET escalated_at = CURRENT_TIMESTAMP WHERE id = $1").WithArgs(int64(3))
+	expectHistory(mock, 12)
+	mock.ExpectExec("RELEASE SAVEPOINT deadline_approver")
+	mock.ExpectCommit()
+
+	if err := s.CheckDeadlines(testDeadlines); err != nil {
+		t.Fatalf("CheckDeadlines: %v", err)
+	}
+}
+
+func TestEscalationTarget(t *testing.T) {
+	manager := sql.NullInt64{Int64: 8, Valid: true}
+
+	tests := []struct {
+		name       string
+		managerID  sql.NullInt64
+		managerOK  bool
+		admins     [][]any
+		wantTarget int64
+		wantReason string
+	}{
+		{name: "руководитель", managerID: manager, managerOK: true, wantTarget: 8, wantReason: "manager"},
+		{name: "руководитель уже в процессе", managerID: manager, admins: [][]any{{int64(1)}}, wantTarget: 1, wantReason: "admin"},
+		{name: "нет руководителя", admins: [][]any{{int64(1)}}, wantTarget: 1, wantReason: "admin"},
+		{name: "некому передать", managerID: manager},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqltest.Open(t)
+			d := dueApprover{id: 3, processID: 12, userID: 7, managerID: tt.managerID}
+
+			mock.ExpectBegin()
+			if tt.managerID.Valid {
+				mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users u WHERE u.id = $2").WithArgs(int64(12), int64(8)).
+					WillReturnRows([]string{"exists"}, []any{tt.managerOK})
+			}
+			if !tt.managerOK {
+				mock.ExpectQuery("JOIN user_roles ur").WithArgs(int64(12), models.RoleAdmin).
+					WillReturnRows([]string{"id"}, tt.admins...)
+			}
+			mock.ExpectRollback()
+
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			target, reason, err := escalationTarget(tx, d)
+			if err != nil {
+				t.Fatalf("escalationTarget: %v", err)
+			}
+			if target != tt.wantTarget || reason != tt.wantReason {
+				t.Errorf("получено (%d, %q), ожидалось (%d, %q)", target, reason, tt.wantTarget, tt.wantReason)
+			}
+		})
+	}
+}
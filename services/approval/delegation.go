@@ -0,0 +1,196 @@
+package approval
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"document-approval/models"
+)
+
+var ErrDelegationNotFound = errors.New("замещение не найдено")
+
+// CreateDelegation назначает заместителя утверждающего на период
+func (s *ApprovalService) CreateDelegation(d *models.Delegation) error {
+	if d.SubstituteID == d.DelegatorID {
+		return fmt.Errorf("нельзя назначить заместителем самого себя")
+	}
+	if !d.EndsAt.After(d.StartsAt) {
+		return fmt.Errorf("дата окончания замещения должна быть позже даты начала")
+	}
+	if d.EndsAt.Before(time.Now()) {
+		return fmt.Errorf("период замещения уже закончился")
+	}
+
+	// Заместитель должен сам иметь право принимать решения
+	var active, canApprove bool
+	err := s.db.QueryRow(`
+        SELECT u.is_active, EXISTS (
+            SELECT 1 FROM user_roles ur
+            WHERE ur.user_id = u.id AND ur.role IN ($2, $3)
+        )
+        FROM users u WHERE u.id = $1
+    `, d.SubstituteID, models.RoleApprover, models.RoleAdmin).Scan(&active, &canApprove)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return fmt.Errorf("заместитель не найден или отключен")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки заместителя: %w", err)
+	}
+	if !canApprove {
+		return fmt.Errorf("заместитель должен иметь роль утверждающего")
+	}
+
+	// Пересекающиеся периоды сделали бы выбор заместителя неоднозначным
+	var overlaps bool
+	err = s.db.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM approval_delegations
+            WHERE delegator_id = $1 AND revoked_at IS NULL
+              AND starts_at < $3 AND ends_at > $2
+        )
+    `, d.DelegatorID, d.StartsAt, d.EndsAt).Scan(&overlaps)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки замещений: %w", err)
+	}
+	if overlaps {
+		return fmt.Errorf("на этот период уже назначен заместитель")
+	}
+
+	err = s.db.QueryRow(`
+        INSERT INTO approval_delegations (delegator_id, substitute_id, starts_at, ends_at, reason)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, d.DelegatorID, d.SubstituteID, d.StartsAt, d.EndsAt, d.Reason).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания замещения: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserDelegations возвращает замещения, где пользователь - замещаемый или заместитель
+func (s *ApprovalService) GetUserDelegations(userID int64) ([]models.Delegation, error) {
+	rows, err := s.db.Query(`
+        SELECT d.id, d.delegator_id, d.substitute_id, d.starts_at, d.ends_at,
+               d.reason, d.revoked_at, d.created_at,
+               du.first_name, du.last_name, su.first_name, su.last_name
+        FROM approval_delegations d
+        JOIN users du ON du.id = d.delegator_id
+        JOIN users su ON su.id = d.substitute_id
+        WHERE d.delegator_id = $1 OR d.substitute_id = $1
+        ORDER BY d.starts_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения замещений: %w", err)
+	}
+	defer rows.Close()
+
+	var delegations []models.Delegation
+	for rows.Next() {
+		var d models.Delegation
+		var delegator, substitute models.User
+
+		err := rows.Scan(
+			&d.ID, &d.DelegatorID, &d.SubstituteID, &d.StartsAt, &d.EndsAt,
+			&d.Reason, &d.RevokedAt, &d.CreatedAt,
+			&delegator.FirstName, &delegator.LastName,
+			&substitute.FirstName, &substitute.LastName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования замещения: %w", err)
+		}
+
+		delegator.ID = d.DelegatorID
+		substitute.ID = d.SubstituteID
+		d.Delegator = &delegator
+		d.Substitute = &substitute
+		delegations = append(delegations, d)
+	}
+
+	return delegations, nil
+}
+
+// RevokeDelegation досрочно отменяет замещение
+func (s *ApprovalService) RevokeDelegation(delegationID, delegatorID int64) error {
+	result, err := s.db.Exec(`
+        UPDATE approval_delegations SET revoked_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND delegator_id = $2 AND revoked_at IS NULL
+    `, delegationID, delegatorID)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены замещения: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDelegationNotFound
+	}
+
+	return nil
+}
+
+// ReassignApprover передает ожидающее решение другому пользователю в идущем процессе
+func (s *ApprovalService) ReassignApprover(processID, approverID, newUserID, actorID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var active bool
+	err = tx.QueryRow(`
+        SELECT is_active FROM users WHERE id = $1
+    `, newUserID).Scan(&active)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return fmt.Errorf("новый утверждающий не найден или отключен")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки утверждающего: %w", err)
+	}
+
+	// Участник процесса, в том числе голосовавший как заместитель, второе
+	// место не получает
+	var alreadyApprover bool
+	err = tx.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM approvers
+            WHERE process_id = $1 AND (user_id = $2 OR acted_by = $2) AND id <> $3
+        )
+    `, processID, newUserID, approverID).Scan(&alreadyApprover)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки утверждающих: %w", err)
+	}
+	if alreadyApprover {
+		return fmt.Errorf("пользователь уже участвует в этом согласовании")
+	}
+
+	var previousUserID int64
+	err = tx.QueryRow(`
+        UPDATE approvers a
+        SET reassigned_from = a.user_id, user_id = $1
+        FROM approval_processes ap
+        WHERE a.id = $2 AND a.process_id = $3 AND ap.id = a.process_id
+          AND a.status = 'pending' AND ap.status = 'in_progress'
+        RETURNING a.reassigned_from
+    `, newUserID, approverID, processID).Scan(&previousUserID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("ожидающий утверждающий не найден в активном процессе")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка переназначения утверждающего: %w", err)
+	}
+
+	err = recordHistory(tx, processID, &approverID, models.HistoryReassigned, &actorID, map[string]any{
+		"from_user_id": previousUserID,
+		"to_user_id":   newUserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := notifyAssigned(tx, processID, &approverID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,324 @@
+package approval
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"document-approval/models"
+	"document-approval/services/mail"
+	"document-approval/services/notification"
+	"document-approval/services/stream"
+)
+
+const dueLayout = "02.01.2006 15:04"
+
+// notifyAssigned ставит в очередь письма о назначении ожидающим утверждающим
+// открытого этапа процесса, создает им уведомления и публикует событие в поток
+// обновлений.
+// approverID ограничивает рассылку одним решением, например после переназначения.
+func notifyAssigned(tx *sql.Tx, processID int64, approverID *int64) error {
+	rows, err := tx.Query(`
+        SELECT a.user_id, a.due_at FROM approvers a
+        JOIN approval_processes ap ON ap.id = a.process_id
+        WHERE a.process_id = $1 AND a.status = 'pending'
+          AND ap.status = 'in_progress' AND a.stage = ap.current_stage
+          AND ($2::int IS NULL OR a.id = $2)
+        ORDER BY a.id
+    `, processID, approverID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения назначенных утверждающих: %w", err)
+	}
+
+	type assigned struct {
+		userID int64
+		dueAt  *time.Time
+	}
+	var list []assigned
+	for rows.Next() {
+		var a assigned
+		if err := rows.Scan(&a.userID, &a.dueAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования утверждающего: %w", err)
+		}
+		list = append(list, a)
+	}
+	rows.Close()
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	documentID, title, err := processDocument(tx, processID)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range list {
+		data := map[string]any{}
+		if a.dueAt != nil {
+			data["due_at"] = a.dueAt.Format(dueLayout)
+		}
+		if err := mail.Enqueue(tx, a.userID, processID, models.NotifyAssigned, data); err != nil {
+			return err
+		}
+
+		err := notification.Create(tx, models.Notification{
+			UserID:     a.userID,
+			Type:       models.NotificationAssigned,
+			DocumentID: &documentID,
+			ProcessID:  &processID,
+			Data:       data,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = stream.Publish(tx, &a.userID, models.StreamApprovalAssigned, map[string]any{
+			"process_id":     processID,
+			"document_id":    documentID,
+			"document_title": title,
+			"due_at":         a.dueAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyDecided сообщает о решении утверждающего тем, чей это документ:
+// инициатору процесса и автору документа. Принявший решение уведомление
+// не получает.
+func notifyDecided(tx *sql.Tx, processID, actorID int64, decision, comment string) error {
+	owners, err := documentOwners(tx, processID)
+	if err != nil {
+		return err
+	}
+
+	var recipients []int64
+	for _, id := range owners {
+		if id != actorID {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	var documentID int64
+	var title, documentStatus string
+	err = tx.QueryRow(`
+        SELECT d.id, d.title, d.status FROM approval_processes ap
+        JOIN documents d ON d.id = ap.document_id
+        WHERE ap.id = $1
+    `, processID).Scan(&documentID, &title, &documentStatus)
+	if err != nil {
+		return fmt.Errorf("ошибка получения документа процесса: %w", err)
+	}
+
+	err = notification.CreateEach(tx, recipients, models.Notification{
+		Type:       models.NotificationDecided,
+		DocumentID: &documentID,
+		ProcessID:  &processID,
+		ActorID:    &actorID,
+		Data: map[string]any{
+			"decision": decision,
+			"comment":  comment,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return stream.PublishEach(tx, recipients, models.StreamApprovalDecided, map[string]any{
+		"process_id":      processID,
+		"document_id":     documentID,
+		"document_title":  title,
+		"document_status": documentStatus,
+		"decision":        decision,
+		"acted_by":        actorID,
+	})
+}
+
+// notifyMentioned уведомляет пользователей, упомянутых в комментарии к решению
+func notifyMentioned(tx *sql.Tx, processID, actorID int64, comment string) error {
+	mentioned, err := notification.Mentioned(tx, comment)
+	if err != nil || len(mentioned) == 0 {
+		return err
+	}
+
+	documentID, _, err := processDocument(tx, processID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range mentioned {
+		if userID == actorID {
+			continue
+		}
+		err := notification.Create(tx, models.Notification{
+			UserID:     userID,
+			Type:       models.NotificationMentioned,
+			DocumentID: &documentID,
+			ProcessID:  &processID,
+			ActorID:    &actorID,
+			Data:       map[string]any{"comment": comment},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyCanceled уведомляет об отмене согласования тех, чьего решения ждал
+// открытый этап. Вызывается до закрытия ожидающих решений.
+func notifyCanceled(tx *sql.Tx, processID, actorID int64, reason string) error {
+	rows, err := tx.Query(`
+        SELECT DISTINCT a.user_id FROM approvers a
+        JOIN approval_processes ap ON ap.id = a.process_id
+        WHERE a.process_id = $1 AND a.status = 'pending' AND a.stage = ap.current_stage
+          AND a.user_id <> $2
+        ORDER BY a.user_id
+    `, processID, actorID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения утверждающих процесса: %w", err)
+	}
+
+	var recipients []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования утверждающего: %w", err)
+		}
+		recipients = append(recipients, id)
+	}
+	rows.Close()
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	documentID, _, err := processDocument(tx, processID)
+	if err != nil {
+		return err
+	}
+
+	return notification.CreateEach(tx, recipients, models.Notification{
+		Type:       models.NotificationCanceled,
+		DocumentID: &documentID,
+		ProcessID:  &processID,
+		ActorID:    &actorID,
+		Data:       map[string]any{"reason": reason},
+	})
+}
+
+// documentOwners возвращает инициатора процесса и автора его документа
+// (того, кто создал документ по его истории)
+func documentOwners(tx *sql.Tx, processID int64) ([]int64, error) {
+	rows, err := tx.Query(`
+        SELECT initiated_by FROM approval_processes
+        WHERE id = $1 AND initiated_by IS NOT NULL
+        UNION
+        SELECT e.actor_id FROM document_events e
+        JOIN approval_processes ap ON ap.document_id = e.document_id
+        WHERE ap.id = $1 AND e.event_type = $2 AND e.actor_id IS NOT NULL
+    `, processID, models.EventCreated)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения владельцев документа: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования владельца документа: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func processDocument(tx *sql.Tx, processID int64) (int64, string, error) {
+	var documentID int64
+	var title string
+	err := tx.QueryRow(`
+        SELECT d.id, d.title FROM approval_processes ap
+        JOIN documents d ON d.id = ap.document_id
+        WHERE ap.id = $1
+    `, processID).Scan(&documentID, &title)
+	if err != nil {
+		return 0, "", fmt.Errorf("ошибка получения документа процесса: %w", err)
+	}
+	return documentID, title, nil
+}
+
+// notifyFinished ставит в очередь письмо инициатору об итоге согласования
+// и создает ему уведомление.
+// При отклонении в письмо попадает комментарий последнего отклонившего.
+func notifyFinished(tx *sql.Tx, processID int64, documentStatus string) error {
+	var initiatedBy sql.NullInt64
+	err := tx.QueryRow(`
+        SELECT initiated_by FROM approval_processes WHERE id = $1
+    `, processID).Scan(&initiatedBy)
+	if err != nil {
+		return fmt.Errorf("ошибка получения инициатора процесса: %w", err)
+	}
+	if !initiatedBy.Valid {
+		return nil
+	}
+
+	documentID, _, err := processDocument(tx, processID)
+	if err != nil {
+		return err
+	}
+
+	if documentStatus != models.StatusRejected {
+		err := notification.Create(tx, models.Notification{
+			UserID:     initiatedBy.Int64,
+			Type:       models.NotificationCompleted,
+			DocumentID: &documentID,
+			ProcessID:  &processID,
+			Data:       map[string]any{"result": documentStatus},
+		})
+		if err != nil {
+			return err
+		}
+		return mail.Enqueue(tx, initiatedBy.Int64, processID, models.NotifyCompleted, nil)
+	}
+
+	var comment string
+	err = tx.QueryRow(`
+        SELECT COALESCE(comment, '') FROM approvers
+        WHERE process_id = $1 AND status = 'rejected'
+        ORDER BY approved_at DESC NULLS LAST, id DESC
+        LIMIT 1
+    `, processID).Scan(&comment)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("ошибка получения комментария отклонения: %w", err)
+	}
+
+	err = notification.Create(tx, models.Notification{
+		UserID:     initiatedBy.Int64,
+		Type:       models.NotificationCompleted,
+		DocumentID: &documentID,
+		ProcessID:  &processID,
+		Data: map[string]any{
+			"result":  documentStatus,
+			"comment": comment,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return mail.Enqueue(tx, initiatedBy.Int64, processID, models.NotifyRejected, map[string]any{
+		"comment": comment,
+	})
+}
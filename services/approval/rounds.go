@@ -0,0 +1,244 @@
+package approval
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"document-approval/models"
+)
+
+var (
+	ErrProcessNotFound   = errors.New("процесс согласования не найден")
+	ErrCancelForbidden   = errors.New("отменить согласование может только инициатор или администратор")
+	ErrResubmitForbidden = errors.New("повторно отправить на согласование может только инициатор или администратор")
+)
+
+// CancelProcess отменяет идущее согласование. Ожидающие решения закрываются,
+// документ возвращается в черновик.
+func (s *ApprovalService) CancelProcess(processID, userID int64, isAdmin bool, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("укажите причину отмены")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var initiatedBy sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT initiated_by FROM approval_processes WHERE id = $1
+    `, processID).Scan(&initiatedBy)
+	if err == sql.ErrNoRows {
+		return ErrProcessNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения процесса: %w", err)
+	}
+
+	if !isAdmin && (!initiatedBy.Valid || initiatedBy.Int64 != userID) {
+		return ErrCancelForbidden
+	}
+
+	documentID, err := setProcessStatus(tx, processID, models.ProcessStatusCanceled)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE approval_processes
+        SET canceled_at = CURRENT_TIMESTAMP, canceled_by = $1, cancel_reason = $2
+        WHERE id = $3
+    `, userID, reason, processID)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены процесса: %w", err)
+	}
+
+	if err := notifyCanceled(tx, processID, userID, reason); err != nil {
+		return err
+	}
+
+	if err := s.skipPending(tx, processID, 1, 0); err != nil {
+		return err
+	}
+
+	if err := setDocumentStatus(tx, documentID, models.StatusDraft, &userID); err != nil {
+		return err
+	}
+
+	err = recordHistory(tx, processID, nil, models.HistoryCanceled, &userID, map[string]any{
+		"reason": reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResubmitProcess запускает новый круг согласования по маршруту завершенного
+// процесса. Решения прошлого круга остаются в его процессе.
+func (s *ApprovalService) ResubmitProcess(processID, initiatorID int64, isAdmin bool) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var documentID int64
+	var status string
+	var isLatest bool
+	route := models.ApprovalRoute{}
+	var initiatedBy, quorum, templateVersion sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT ap.document_id, ap.status, ap.initiated_by, ap.decision_rule, ap.quorum, ap.template_id, ap.template_version,
+               NOT EXISTS (
+                   SELECT 1 FROM approval_processes n
+                   WHERE n.document_id = ap.document_id AND n.round > ap.round
+               )
+        FROM approval_processes ap
+        WHERE ap.id = $1
+    `, processID).Scan(
+		&documentID, &status, &initiatedBy, &route.DecisionRule, &quorum, &route.TemplateID, &templateVersion, &isLatest,
+	)
+	if err == sql.ErrNoRows {
+		return 0, ErrProcessNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения процесса: %w", err)
+	}
+
+	if !isAdmin && (!initiatedBy.Valid || initiatedBy.Int64 != initiatorID) {
+		return 0, ErrResubmitForbidden
+	}
+
+	if status == models.ProcessStatusInProgress {
+		return 0, ErrProcessActive
+	}
+	if !isLatest {
+		return 0, fmt.Errorf("повторно отправить можно только последний круг согласования")
+	}
+
+	route.Quorum = int(quorum.Int64)
+	route.TemplateVersion = int(templateVersion.Int64)
+
+	route.Stages, err = previousStages(tx, processID)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := s.startProcess(tx, documentID, initiatorID, route)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// previousStages восстанавливает этапы маршрута процесса с текущими утверждающими
+func previousStages(tx *sql.Tx, processID int64) ([]models.ApprovalStage, error) {
+	rows, err := tx.Query(`
+        SELECT a.stage, a.user_id,
+               COALESCE(st.decision_rule, ''), COALESCE(st.quorum, 0), COALESCE(st.due_hours, 0)
+        FROM approvers a
+        LEFT JOIN approval_stages st ON st.process_id = a.process_id AND st.stage = a.stage
+        WHERE a.process_id = $1
+        ORDER BY a.stage, a.id
+    `, processID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения маршрута процесса: %w", err)
+	}
+	defer rows.Close()
+
+	var stages []models.ApprovalStage
+	lastStage := 0
+	for rows.Next() {
+		var stageNum int
+		var userID int64
+		var stage models.ApprovalStage
+
+		err := rows.Scan(&stageNum, &userID, &stage.DecisionRule, &stage.Quorum, &stage.DueHours)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования маршрута: %w", err)
+		}
+
+		if stageNum != lastStage {
+			stages = append(stages, stage)
+			lastStage = stageNum
+		}
+		current := &stages[len(stages)-1]
+		current.ApproverIDs = append(current.ApproverIDs, userID)
+	}
+
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("в процессе нет утверждающих")
+	}
+
+	return stages, nil
+}
+
+// GetDocumentProcesses возвращает все круги согласования документа с решениями
+func (s *ApprovalService) GetDocumentProcesses(documentID int64) ([]models.ApprovalProcess, error) {
+	rows, err := s.db.Query(`
+        SELECT `+processColumns+`
+        FROM approval_processes ap
+        WHERE ap.document_id = $1
+        ORDER BY ap.round, ap.id
+    `, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения процессов документа: %w", err)
+	}
+
+	var processes []models.ApprovalProcess
+	for rows.Next() {
+		p, err := scanProcess(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		processes = append(processes, *p)
+	}
+	rows.Close()
+
+	for i := range processes {
+		processes[i].Approvers, err = s.getProcessApprovers(processes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return processes, nil
+}
+
+// processColumns - поля процесса для scanProcess
+const processColumns = `
+    ap.id, ap.document_id, ap.status, ap.current_stage, ap.decision_rule, ap.created_at,
+    ap.template_id, ap.template_version,
+    ap.initiated_by, ap.round, ap.previous_process_id,
+    ap.canceled_at, ap.canceled_by, COALESCE(ap.cancel_reason, ''),
+    ap.document_version
+`
+
+func scanProcess(row interface{ Scan(...any) error }, extra ...any) (*models.ApprovalProcess, error) {
+	var p models.ApprovalProcess
+	dest := []any{
+		&p.ID, &p.DocumentID, &p.Status, &p.CurrentStage, &p.DecisionRule, &p.CreatedAt,
+		&p.TemplateID, &p.TemplateVersion,
+		&p.InitiatedBy, &p.Round, &p.PreviousProcessID,
+		&p.CanceledAt, &p.CanceledBy, &p.CancelReason,
+		&p.DocumentVersion,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProcessNotFound
+		}
+		return nil, fmt.Errorf("ошибка сканирования процесса: %w", err)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,213 @@
+package approval
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"document-approval/config"
+	"document-approval/models"
+	"document-approval/pkg/expr"
+)
+
+// routingFields - поля документа, доступные в условиях правил
+var routingFields = []string{
+	"title", "document_type", "kopuk", "museum_name", "founder",
+	"founder_inn", "incoming_number", "contact_person",
+}
+
+// RoutingEnv собирает переменные для условий правил из документа
+func RoutingEnv(doc *models.Document) map[string]any {
+	metadata := doc.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	return map[string]any{
+		"title":           doc.Title,
+		"document_type":   doc.DocumentType,
+		"kopuk":           doc.Kopuk,
+		"museum_name":     doc.MuseumName,
+		"founder":         doc.Founder,
+		"founder_inn":     doc.FounderINN,
+		"incoming_number": doc.IncomingNumber,
+		"contact_person":  doc.ContactPerson,
+		"metadata":        metadata,
+	}
+}
+
+// ValidateRoutingRule проверяет правило шаблона: синтаксис условия, известные
+// переменные и корректность действия. stagesCount - число этапов шаблона.
+func ValidateRoutingRule(rule models.RoutingRule, documentType string, stagesCount int) error {
+	if strings.TrimSpace(rule.Condition) == "" {
+		return fmt.Errorf("не указано условие")
+	}
+
+	e, err := expr.Parse(rule.Condition)
+	if err != nil {
+		return fmt.Errorf("ошибка в условии: %w", err)
+	}
+
+	for _, name := range e.Identifiers() {
+		if !knownRoutingVariable(name, documentType) {
+			return fmt.Errorf("неизвестная переменная в условии: %s", name)
+		}
+	}
+
+	switch rule.Action {
+	case models.RoutingActionSkipStage:
+		if rule.Stage < 1 || rule.Stage > stagesCount {
+			return fmt.Errorf("этап для пропуска должен быть от 1 до %d", stagesCount)
+		}
+	case models.RoutingActionAddStage:
+		if rule.AddStage == nil {
+			return fmt.Errorf("не указан добавляемый этап")
+		}
+		if rule.After != nil && (*rule.After < 0 || *rule.After > stagesCount) {
+			return fmt.Errorf("позиция добавляемого этапа должна быть от 0 до %d", stagesCount)
+		}
+	default:
+		return fmt.Errorf("неизвестное действие правила: %s", rule.Action)
+	}
+
+	return nil
+}
+
+// ApplyRoutingRules применяет правила шаблона к документу и возвращает
+// итоговые этапы. origins[i] - номер этапа шаблона для i-го этапа или 0
+// для этапа, добавленного правилом.
+func ApplyRoutingRules(t *models.RouteTemplate, env map[string]any) ([]models.RouteTemplateStage, []int, error) {
+	skipped := make(map[int]bool)
+	added := make(map[int][]models.RouteTemplateStage)
+	var appended []models.RouteTemplateStage
+
+	for i, rule := range t.Rules {
+		e, err := expr.Parse(rule.Condition)
+		if err != nil {
+			return nil, nil, fmt.Errorf("правило %d: ошибка в условии: %w", i+1, err)
+		}
+
+		matched, err := e.Eval(env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("правило %d: %w", i+1, err)
+		}
+		if !matched {
+			continue
+		}
+
+		switch rule.Action {
+		case models.RoutingActionSkipStage:
+			skipped[rule.Stage] = true
+		case models.RoutingActionAddStage:
+			if rule.After == nil {
+				appended = append(appended, *rule.AddStage)
+			} else {
+				added[*rule.After] = append(added[*rule.After], *rule.AddStage)
+			}
+		}
+	}
+
+	var stages []models.RouteTemplateStage
+	var origins []int
+	for n := 0; n <= len(t.Stages); n++ {
+		if n > 0 && !skipped[n] {
+			stages = append(stages, t.Stages[n-1])
+			origins = append(origins, n)
+		}
+		for _, stage := range added[n] {
+			stages = append(stages, stage)
+			origins = append(origins, 0)
+		}
+	}
+	for _, stage := range appended {
+		stages = append(stages, stage)
+		origins = append(origins, 0)
+	}
+
+	if len(stages) == 0 {
+		return nil, nil, fmt.Errorf("после применения правил в маршруте не осталось этапов")
+	}
+
+	return stages, origins, nil
+}
+
+// checkOverrides проверяет, что изменения запуска относятся к этапам,
+// оставшимся в маршруте после правил. Изменение пропущенного этапа
+// отклоняется: иначе назначенные в нем утверждающие молча не попадут в
+// маршрут.
+func checkOverrides(stagesCount int, origins []int, overrides models.RouteOverrides) error {
+	kept := make(map[int]bool, len(origins))
+	for _, n := range origins {
+		kept[n] = true
+	}
+
+	for n := range overrides.Stages {
+		if n < 1 || n > stagesCount {
+			return fmt.Errorf("в шаблоне нет этапа %d", n)
+		}
+		if !kept[n] {
+			return fmt.Errorf("этап %d шаблона пропущен правилами маршрутизации и не может быть изменен", n)
+		}
+	}
+
+	return nil
+}
+
+func knownRoutingVariable(name, documentType string) bool {
+	for _, field := range routingFields {
+		if name == field {
+			return true
+		}
+	}
+
+	key, ok := strings.CutPrefix(name, "metadata.")
+	if !ok {
+		return false
+	}
+	for _, docType := range config.DocumentTypes {
+		if docType.ID != documentType {
+			continue
+		}
+		for _, field := range docType.Fields {
+			if field.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadRoutingDocument загружает поля документа, нужные для правил маршрутизации
+func (s *ApprovalService) loadRoutingDocument(documentID int64) (*models.Document, error) {
+	var doc models.Document
+	var documentType, contactPerson sql.NullString
+	var metadata []byte
+
+	err := s.db.QueryRow(`
+        SELECT title, document_type, kopuk, museum_name, founder, founder_inn,
+               incoming_number, contact_person, metadata
+        FROM documents WHERE id = $1
+    `, documentID).Scan(
+		&doc.Title, &documentType, &doc.Kopuk, &doc.MuseumName, &doc.Founder, &doc.FounderINN,
+		&doc.IncomingNumber, &contactPerson, &metadata,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("документ не найден")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+
+	doc.ID = documentID
+	doc.DocumentType = documentType.String
+	doc.ContactPerson = contactPerson.String
+
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &doc.Metadata); err != nil {
+			return nil, fmt.Errorf("ошибка разбора метаданных: %w", err)
+		}
+	}
+
+	return &doc, nil
+}
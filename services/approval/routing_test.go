@@ -0,0 +1,252 @@
+package approval
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"document-approval/models"
+)
+
+func sampleDocument() *models.Document {
+	return &models.Document{
+		Title:        "Отчет за 2 квартал",
+		DocumentType: "financial_report",
+		Kopuk:        2,
+		Founder:      "Министерство культуры",
+		Metadata: map[string]any{
+			"period":   "2 квартал",
+			"income":   1500000.0,
+			"expenses": 200000.0,
+		},
+	}
+}
+
+func sampleTemplate(rules ...models.RoutingRule) *models.RouteTemplate {
+	return &models.RouteTemplate{
+		DocumentType: "financial_report",
+		Stages: []models.RouteTemplateStage{
+			{Name: "Бухгалтерия"},
+			{Name: "Юрист"},
+			{Name: "Директор"},
+		},
+		Rules: rules,
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+func stageNames(stages []models.RouteTemplateStage) []string {
+	names := make([]string, len(stages))
+	for i, s := range stages {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func TestApplyRoutingRules(t *testing.T) {
+	treasury := &models.RouteTemplateStage{Name: "Казначейство"}
+
+	tests := []struct {
+		name        string
+		rules       []models.RoutingRule
+		wantStages  []string
+		wantOrigins []int
+	}{
+		{
+			name:        "без правил",
+			wantStages:  []string{"Бухгалтерия", "Юрист", "Директор"},
+			wantOrigins: []int{1, 2, 3},
+		},
+		{
+			name: "пропуск этапа при совпадении",
+			rules: []models.RoutingRule{
+				{Condition: "metadata.income > 1000000", Action: models.RoutingActionSkipStage, Stage: 2},
+			},
+			wantStages:  []string{"Бухгалтерия", "Директор"},
+			wantOrigins: []int{1, 3},
+		},
+		{
+			name: "условие не выполнено - этап остается",
+			rules: []models.RoutingRule{
+				{Condition: "metadata.income > 5000000", Action: models.RoutingActionSkipStage, Stage: 2},
+			},
+			wantStages:  []string{"Бухгалтерия", "Юрист", "Директор"},
+			wantOrigins: []int{1, 2, 3},
+		},
+		{
+			name: "добавление после этапа",
+			rules: []models.RoutingRule{
+				{Condition: "kopuk in [1, 2]", Action: models.RoutingActionAddStage, AddStage: treasury, After: intPtr(1)},
+			},
+			wantStages:  []string{"Бухгалтерия", "Казначейство", "Юрист", "Директор"},
+			wantOrigins: []int{1, 0, 2, 3},
+		},
+		{
+			name: "добавление в начало",
+			rules: []models.RoutingRule{
+				{Condition: `founder contains "культуры"`, Action: models.RoutingActionAddStage, AddStage: treasury, After: intPtr(0)},
+			},
+			wantStages:  []string{"Казначейство", "Бухгалтерия", "Юрист", "Директор"},
+			wantOrigins: []int{0, 1, 2, 3},
+		},
+		{
+			name: "добавление в конец",
+			rules: []models.RoutingRule{
+				{Condition: `document_type == "financial_report"`, Action: models.RoutingActionAddStage, AddStage: treasury},
+			},
+			wantStages:  []string{"Бухгалтерия", "Юрист", "Директор", "Казначейство"},
+			wantOrigins: []int{1, 2, 3, 0},
+		},
+		{
+			name: "добавление после пропущенного этапа",
+			rules: []models.RoutingRule{
+				{Condition: "metadata.expenses < 500000", Action: models.RoutingActionSkipStage, Stage: 2},
+				{Condition: "true", Action: models.RoutingActionAddStage, AddStage: treasury, After: intPtr(2)},
+			},
+			wantStages:  []string{"Бухгалтерия", "Казначейство", "Директор"},
+			wantOrigins: []int{1, 0, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, origins, err := ApplyRoutingRules(sampleTemplate(tt.rules...), RoutingEnv(sampleDocument()))
+			if err != nil {
+				t.Fatalf("ApplyRoutingRules: %v", err)
+			}
+			if got := stageNames(stages); !reflect.DeepEqual(got, tt.wantStages) {
+				t.Errorf("этапы = %v, ожидалось %v", got, tt.wantStages)
+			}
+			if !reflect.DeepEqual(origins, tt.wantOrigins) {
+				t.Errorf("origins = %v, ожидалось %v", origins, tt.wantOrigins)
+			}
+		})
+	}
+}
+
+func TestApplyRoutingRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []models.RoutingRule
+		want  string
+	}{
+		{
+			name: "пропущены все этапы",
+			rules: []models.RoutingRule{
+				{Condition: "true", Action: models.RoutingActionSkipStage, Stage: 1},
+				{Condition: "true", Action: models.RoutingActionSkipStage, Stage: 2},
+				{Condition: "true", Action: models.RoutingActionSkipStage, Stage: 3},
+			},
+			want: "не осталось этапов",
+		},
+		{
+			name:  "условие не логическое",
+			rules: []models.RoutingRule{{Condition: "metadata.income", Action: models.RoutingActionSkipStage, Stage: 1}},
+			want:  "правило 1",
+		},
+		{
+			name:  "ошибка разбора",
+			rules: []models.RoutingRule{{Condition: "kopuk ==", Action: models.RoutingActionSkipStage, Stage: 1}},
+			want:  "ошибка в условии",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ApplyRoutingRules(sampleTemplate(tt.rules...), RoutingEnv(sampleDocument()))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ошибка %v, ожидалась %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOverrides(t *testing.T) {
+	// Юрист не нужен при доходе выше миллиона
+	skipLawyer := models.RoutingRule{Condition: "metadata.income > 1000000", Action: models.RoutingActionSkipStage, Stage: 2}
+	treasury := models.RoutingRule{
+		Condition: "true",
+		Action:    models.RoutingActionAddStage,
+		AddStage:  &models.RouteTemplateStage{Name: "Казначейство"},
+		After:     intPtr(1),
+	}
+	tmpl := sampleTemplate(skipLawyer, treasury)
+
+	_, origins, err := ApplyRoutingRules(tmpl, RoutingEnv(sampleDocument()))
+	if err != nil {
+		t.Fatalf("ApplyRoutingRules: %v", err)
+	}
+
+	override := func(stages ...int) models.RouteOverrides {
+		o := models.RouteOverrides{Stages: map[int]models.ApprovalStage{}}
+		for _, n := range stages {
+			o.Stages[n] = models.ApprovalStage{ApproverIDs: []int64{42}}
+		}
+		return o
+	}
+
+	tests := []struct {
+		name      string
+		overrides models.RouteOverrides
+		want      string
+	}{
+		{name: "без изменений", overrides: models.RouteOverrides{}},
+		{name: "оставшиеся этапы", overrides: override(1, 3)},
+		{name: "пропущенный этап", overrides: override(1, 2), want: "этап 2 шаблона пропущен"},
+		{name: "нет такого этапа", overrides: override(4), want: "нет этапа 4"},
+		{name: "номер с нуля", overrides: override(0), want: "нет этапа 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOverrides(len(tmpl.Stages), origins, tt.overrides)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("checkOverrides: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ошибка %v, ожидалась %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoutingRule(t *testing.T) {
+	stage := &models.RouteTemplateStage{Name: "Казначейство"}
+
+	tests := []struct {
+		name string
+		rule models.RoutingRule
+		want string
+	}{
+		{"поле документа", models.RoutingRule{Condition: "kopuk == 1", Action: models.RoutingActionSkipStage, Stage: 1}, ""},
+		{"поле типа документа", models.RoutingRule{Condition: "metadata.income > 1", Action: models.RoutingActionSkipStage, Stage: 3}, ""},
+		{"добавление в конец", models.RoutingRule{Condition: "true", Action: models.RoutingActionAddStage, AddStage: stage}, ""},
+		{"пустое условие", models.RoutingRule{Condition: " ", Action: models.RoutingActionSkipStage, Stage: 1}, "не указано условие"},
+		{"синтаксическая ошибка", models.RoutingRule{Condition: "kopuk ==", Action: models.RoutingActionSkipStage, Stage: 1}, "ошибка в условии"},
+		{"неизвестное поле", models.RoutingRule{Condition: "amount > 1", Action: models.RoutingActionSkipStage, Stage: 1}, "неизвестная переменная"},
+		{"поле другого типа", models.RoutingRule{Condition: "metadata.visitor_count > 1", Action: models.RoutingActionSkipStage, Stage: 1}, "неизвестная переменная"},
+		{"этап вне шаблона", models.RoutingRule{Condition: "true", Action: models.RoutingActionSkipStage, Stage: 4}, "от 1 до 3"},
+		{"нет этапа для добавления", models.RoutingRule{Condition: "true", Action: models.RoutingActionAddStage}, "не указан добавляемый этап"},
+		{"позиция вне шаблона", models.RoutingRule{Condition: "true", Action: models.RoutingActionAddStage, AddStage: stage, After: intPtr(4)}, "от 0 до 3"},
+		{"неизвестное действие", models.RoutingRule{Condition: "true", Action: "notify"}, "неизвестное действие"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoutingRule(tt.rule, "financial_report", 3)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("неожиданная ошибка: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ошибка %v, ожидалась %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package approval
+
+import (
+	"fmt"
+
+	"document-approval/models"
+)
+
+// StageOutcome - итог этапа согласования
+type StageOutcome int
+
+const (
+	OutcomePending StageOutcome = iota
+	OutcomeApproved
+	OutcomeRejected
+)
+
+// StageTally - подсчет решений на этапе
+type StageTally struct {
+	Total    int
+	Approved int
+	Rejected int
+	Pending  int
+}
+
+// RequiredApprovals возвращает число одобрений, необходимое для прохождения этапа
+func RequiredApprovals(rule string, quorum, total int) int {
+	switch rule {
+	case models.RuleAnyOne:
+		return 1
+	case models.RuleMajority:
+		return total/2 + 1
+	case models.RuleNOfM:
+		return quorum
+	default:
+		return total
+	}
+}
+
+// EvaluateStage определяет итог этапа. Этап отклоняется сразу, как только
+// набрать нужное число одобрений становится невозможно.
+func EvaluateStage(rule string, quorum int, t StageTally) StageOutcome {
+	need := RequiredApprovals(rule, quorum, t.Total)
+
+	if t.Approved >= need {
+		return OutcomeApproved
+	}
+	if t.Approved+t.Pending < need {
+		return OutcomeRejected
+	}
+	return OutcomePending
+}
+
+// ValidateRule проверяет правило принятия решения для этапа с заданным числом утверждающих
+func ValidateRule(rule string, quorum, total int) error {
+	switch rule {
+	case models.RuleUnanimous, models.RuleMajority, models.RuleAnyOne:
+		return nil
+	case models.RuleNOfM:
+		if quorum < 1 || quorum > total {
+			return fmt.Errorf("кворум должен быть от 1 до %d", total)
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестное правило принятия решения: %s", rule)
+	}
+}
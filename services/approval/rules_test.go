@@ -0,0 +1,103 @@
+package approval
+
+import (
+	"strings"
+	"testing"
+
+	"document-approval/models"
+)
+
+func TestEvaluateStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		quorum int
+		tally  StageTally
+		want   StageOutcome
+	}{
+		{"все: все одобрили", models.RuleUnanimous, 0, StageTally{Total: 3, Approved: 3}, OutcomeApproved},
+		{"все: ждем последнего", models.RuleUnanimous, 0, StageTally{Total: 3, Approved: 2, Pending: 1}, OutcomePending},
+		{"все: никто не голосовал", models.RuleUnanimous, 0, StageTally{Total: 3, Pending: 3}, OutcomePending},
+		{"все: одно отклонение", models.RuleUnanimous, 0, StageTally{Total: 3, Approved: 1, Rejected: 1, Pending: 1}, OutcomeRejected},
+		{"пустое правило как все", "", 0, StageTally{Total: 2, Approved: 1, Rejected: 1}, OutcomeRejected},
+
+		{"любой: первое одобрение", models.RuleAnyOne, 0, StageTally{Total: 3, Approved: 1, Pending: 2}, OutcomeApproved},
+		{"любой: отклонения при ожидающих", models.RuleAnyOne, 0, StageTally{Total: 3, Rejected: 2, Pending: 1}, OutcomePending},
+		{"любой: никто не голосовал", models.RuleAnyOne, 0, StageTally{Total: 3, Pending: 3}, OutcomePending},
+		{"любой: все отклонили", models.RuleAnyOne, 0, StageTally{Total: 3, Rejected: 3}, OutcomeRejected},
+
+		{"большинство: 3 из 4", models.RuleMajority, 0, StageTally{Total: 4, Approved: 3, Rejected: 1}, OutcomeApproved},
+		{"большинство: половины мало", models.RuleMajority, 0, StageTally{Total: 4, Approved: 2, Rejected: 1, Pending: 1}, OutcomePending},
+		{"большинство: половина против", models.RuleMajority, 0, StageTally{Total: 4, Approved: 2, Rejected: 2}, OutcomeRejected},
+		{"большинство: 2 из 3", models.RuleMajority, 0, StageTally{Total: 3, Approved: 2, Pending: 1}, OutcomeApproved},
+		{"большинство: 2 против из 3", models.RuleMajority, 0, StageTally{Total: 3, Rejected: 2, Pending: 1}, OutcomeRejected},
+		{"большинство: никто не голосовал", models.RuleMajority, 0, StageTally{Total: 3, Pending: 3}, OutcomePending},
+
+		{"кворум 2 из 4: набран", models.RuleNOfM, 2, StageTally{Total: 4, Approved: 2, Pending: 2}, OutcomeApproved},
+		{"кворум 2 из 4: еще возможен", models.RuleNOfM, 2, StageTally{Total: 4, Approved: 1, Rejected: 2, Pending: 1}, OutcomePending},
+		{"кворум 2 из 4: уже невозможен", models.RuleNOfM, 2, StageTally{Total: 4, Approved: 1, Rejected: 3}, OutcomeRejected},
+		{"кворум 2 из 4: никто не голосовал", models.RuleNOfM, 2, StageTally{Total: 4, Pending: 4}, OutcomePending},
+		{"кворум 4 из 4: одно отклонение", models.RuleNOfM, 4, StageTally{Total: 4, Approved: 3, Rejected: 1}, OutcomeRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EvaluateStage(tt.rule, tt.quorum, tt.tally); got != tt.want {
+				t.Fatalf("EvaluateStage(%q, %d, %+v) = %v, ожидалось %v", tt.rule, tt.quorum, tt.tally, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredApprovals(t *testing.T) {
+	tests := []struct {
+		rule   string
+		quorum int
+		total  int
+		want   int
+	}{
+		{models.RuleUnanimous, 0, 5, 5},
+		{models.RuleAnyOne, 0, 5, 1},
+		{models.RuleMajority, 0, 5, 3},
+		{models.RuleMajority, 0, 4, 3},
+		{models.RuleMajority, 0, 1, 1},
+		{models.RuleNOfM, 2, 5, 2},
+	}
+
+	for _, tt := range tests {
+		if got := RequiredApprovals(tt.rule, tt.quorum, tt.total); got != tt.want {
+			t.Errorf("RequiredApprovals(%q, %d, %d) = %d, ожидалось %d", tt.rule, tt.quorum, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		rule   string
+		quorum int
+		total  int
+		want   string
+	}{
+		{models.RuleUnanimous, 0, 3, ""},
+		{models.RuleAnyOne, 0, 3, ""},
+		{models.RuleMajority, 0, 3, ""},
+		{models.RuleNOfM, 1, 3, ""},
+		{models.RuleNOfM, 3, 3, ""},
+		{models.RuleNOfM, 0, 3, "кворум должен быть от 1 до 3"},
+		{models.RuleNOfM, 4, 3, "кворум должен быть от 1 до 3"},
+		{"two_thirds", 0, 3, "неизвестное правило"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateRule(tt.rule, tt.quorum, tt.total)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("ValidateRule(%q, %d, %d): %v", tt.rule, tt.quorum, tt.total, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateRule(%q, %d, %d) = %v, ожидалось %q", tt.rule, tt.quorum, tt.total, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package approval
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"document-approval/models"
+	"document-approval/services/events"
+)
+
+// Entity - сущность, статусы которой контролирует автомат согласования
+type Entity string
+
+const (
+	EntityDocument Entity = "document"
+	EntityProcess  Entity = "process"
+	EntityApprover Entity = "approver"
+)
+
+var (
+	// ErrIllegalTransition - общая причина для всех TransitionError
+	ErrIllegalTransition = errors.New("недопустимая смена статуса")
+	ErrProcessNotActive  = errors.New("согласование уже завершено или отменено")
+	ErrProcessActive     = errors.New("согласование еще идет")
+	ErrNotAssigned       = errors.New("пользователь не имеет прав на утверждение")
+	ErrSubstituteVoted   = errors.New("заместитель сам участвует в согласовании или уже голосовал за другого и не может голосовать за замещаемого")
+)
+
+// TransitionError - попытка перевести сущность в статус, недопустимый из текущего
+type TransitionError struct {
+	Entity Entity
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("недопустимая смена статуса (%s): %s -> %s", e.Entity, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// transitions - автомат состояний согласования. Статусы, которых нет среди
+// ключей, конечные.
+var transitions = map[Entity]map[string][]string{
+	EntityDocument: {
+		models.StatusDraft:    {models.StatusInReview},
+		models.StatusInReview: {models.StatusApproved, models.StatusRejected, models.StatusDraft},
+		models.StatusRejected: {models.StatusInReview, models.StatusDraft},
+	},
+	EntityProcess: {
+		models.ProcessStatusInProgress: {models.ProcessStatusCompleted, models.ProcessStatusCanceled},
+	},
+	EntityApprover: {
+		models.ApproverStatusPending: {
+			models.ApproverStatusApproved,
+			models.ApproverStatusRejected,
+			models.ApproverStatusSkipped,
+		},
+	},
+}
+
+// CheckTransition возвращает *TransitionError, если переход недопустим
+func CheckTransition(entity Entity, from, to string) error {
+	for _, allowed := range transitions[entity][from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{Entity: entity, From: from, To: to}
+}
+
+// setDocumentStatus переводит документ в новый статус, если переход допустим,
+// и записывает смену статуса в историю документа. Строка документа
+// блокируется до конца транзакции.
+func setDocumentStatus(tx *sql.Tx, documentID int64, to string, actorID *int64) error {
+	var from string
+	err := tx.QueryRow(`
+        SELECT status FROM documents WHERE id = $1 FOR UPDATE
+    `, documentID).Scan(&from)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("документ не найден")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения статуса документа: %w", err)
+	}
+
+	if err := CheckTransition(EntityDocument, from, to); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE documents SET status = $1 WHERE id = $2`, to, documentID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления статуса документа: %w", err)
+	}
+
+	return events.Record(tx, documentID, models.EventStatusChanged, actorID, nil, map[string]any{
+		"from": from,
+		"to":   to,
+	})
+}
+
+// lockProcess блокирует процесс до конца транзакции и возвращает его статус и документ
+func lockProcess(tx *sql.Tx, processID int64) (status string, documentID int64, err error) {
+	err = tx.QueryRow(`
+        SELECT status, document_id FROM approval_processes WHERE id = $1 FOR UPDATE
+    `, processID).Scan(&status, &documentID)
+	if err == sql.ErrNoRows {
+		return "", 0, ErrProcessNotFound
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("ошибка получения процесса: %w", err)
+	}
+	return status, documentID, nil
+}
+
+// setProcessStatus переводит процесс в новый статус, если переход допустим,
+// и возвращает ID документа процесса
+func setProcessStatus(tx *sql.Tx, processID int64, to string) (int64, error) {
+	from, documentID, err := lockProcess(tx, processID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := CheckTransition(EntityProcess, from, to); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE approval_processes SET status = $1 WHERE id = $2`, to, processID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка обновления статуса процесса: %w", err)
+	}
+
+	return documentID, nil
+}
@@ -0,0 +1,143 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+
+	"document-approval/models"
+)
+
+// transitionCases - все пары статусов каждой сущности. Допустимые переходы
+// перечислены явно, чтобы изменение автомата требовало изменить и тест.
+var transitionCases = []struct {
+	entity Entity
+	from   string
+	to     string
+	legal  bool
+}{
+	{EntityDocument, models.StatusDraft, models.StatusDraft, false},
+	{EntityDocument, models.StatusDraft, models.StatusInReview, true},
+	{EntityDocument, models.StatusDraft, models.StatusApproved, false},
+	{EntityDocument, models.StatusDraft, models.StatusRejected, false},
+	{EntityDocument, models.StatusInReview, models.StatusDraft, true},
+	{EntityDocument, models.StatusInReview, models.StatusInReview, false},
+	{EntityDocument, models.StatusInReview, models.StatusApproved, true},
+	{EntityDocument, models.StatusInReview, models.StatusRejected, true},
+	{EntityDocument, models.StatusApproved, models.StatusDraft, false},
+	{EntityDocument, models.StatusApproved, models.StatusInReview, false},
+	{EntityDocument, models.StatusApproved, models.StatusApproved, false},
+	{EntityDocument, models.StatusApproved, models.StatusRejected, false},
+	{EntityDocument, models.StatusRejected, models.StatusDraft, true},
+	{EntityDocument, models.StatusRejected, models.StatusInReview, true},
+	{EntityDocument, models.StatusRejected, models.StatusApproved, false},
+	{EntityDocument, models.StatusRejected, models.StatusRejected, false},
+
+	{EntityProcess, models.ProcessStatusInProgress, models.ProcessStatusInProgress, false},
+	{EntityProcess, models.ProcessStatusInProgress, models.ProcessStatusCompleted, true},
+	{EntityProcess, models.ProcessStatusInProgress, models.ProcessStatusCanceled, true},
+	{EntityProcess, models.ProcessStatusCompleted, models.ProcessStatusInProgress, false},
+	{EntityProcess, models.ProcessStatusCompleted, models.ProcessStatusCompleted, false},
+	{EntityProcess, models.ProcessStatusCompleted, models.ProcessStatusCanceled, false},
+	{EntityProcess, models.ProcessStatusCanceled, models.ProcessStatusInProgress, false},
+	{EntityProcess, models.ProcessStatusCanceled, models.ProcessStatusCompleted, false},
+	{EntityProcess, models.ProcessStatusCanceled, models.ProcessStatusCanceled, false},
+
+	{EntityApprover, models.ApproverStatusPending, models.ApproverStatusPending, false},
+	{EntityApprover, models.ApproverStatusPending, models.ApproverStatusApproved, true},
+	{EntityApprover, models.ApproverStatusPending, models.ApproverStatusRejected, true},
+	{EntityApprover, models.ApproverStatusPending, models.ApproverStatusSkipped, true},
+	{EntityApprover, models.ApproverStatusApproved, models.ApproverStatusPending, false},
+	{EntityApprover, models.ApproverStatusApproved, models.ApproverStatusApproved, false},
+	{EntityApprover, models.ApproverStatusApproved, models.ApproverStatusRejected, false},
+	{EntityApprover, models.ApproverStatusApproved, models.ApproverStatusSkipped, false},
+	{EntityApprover, models.ApproverStatusRejected, models.ApproverStatusPending, false},
+	{EntityApprover, models.ApproverStatusRejected, models.ApproverStatusApproved, false},
+	{EntityApprover, models.ApproverStatusRejected, models.ApproverStatusRejected, false},
+	{EntityApprover, models.ApproverStatusRejected, models.ApproverStatusSkipped, false},
+	{EntityApprover, models.ApproverStatusSkipped, models.ApproverStatusPending, false},
+	{EntityApprover, models.ApproverStatusSkipped, models.ApproverStatusApproved, false},
+	{EntityApprover, models.ApproverStatusSkipped, models.ApproverStatusRejected, false},
+	{EntityApprover, models.ApproverStatusSkipped, models.ApproverStatusSkipped, false},
+}
+
+// entityStatuses - все статусы каждой сущности
+var entityStatuses = map[Entity][]string{
+	EntityDocument: {models.StatusDraft, models.StatusInReview, models.StatusApproved, models.StatusRejected},
+	EntityProcess:  {models.ProcessStatusInProgress, models.ProcessStatusCompleted, models.ProcessStatusCanceled},
+	EntityApprover: {
+		models.ApproverStatusPending, models.ApproverStatusApproved,
+		models.ApproverStatusRejected, models.ApproverStatusSkipped,
+	},
+}
+
+func TestCheckTransition(t *testing.T) {
+	for _, tt := range transitionCases {
+		t.Run(string(tt.entity)+"/"+tt.from+"->"+tt.to, func(t *testing.T) {
+			err := CheckTransition(tt.entity, tt.from, tt.to)
+			if tt.legal {
+				if err != nil {
+					t.Fatalf("переход должен быть допустим: %v", err)
+				}
+				return
+			}
+
+			var transition *TransitionError
+			if !errors.As(err, &transition) {
+				t.Fatalf("ожидалась TransitionError, получено %v", err)
+			}
+			if !errors.Is(err, ErrIllegalTransition) {
+				t.Fatalf("ошибка должна совпадать с ErrIllegalTransition")
+			}
+			if transition.Entity != tt.entity || transition.From != tt.from || transition.To != tt.to {
+				t.Fatalf("неверные поля ошибки: %+v", transition)
+			}
+		})
+	}
+}
+
+func TestTransitionCasesCoverAllPairs(t *testing.T) {
+	covered := map[[3]string]bool{}
+	for _, tt := range transitionCases {
+		covered[[3]string{string(tt.entity), tt.from, tt.to}] = true
+	}
+
+	for entity, statuses := range entityStatuses {
+		for _, from := range statuses {
+			for _, to := range statuses {
+				if !covered[[3]string{string(entity), from, to}] {
+					t.Errorf("нет строки для перехода %s: %s -> %s", entity, from, to)
+				}
+			}
+		}
+	}
+
+	// Автомат не должен знать статусов, которых нет в тесте
+	for entity, byFrom := range transitions {
+		for from, targets := range byFrom {
+			for _, to := range targets {
+				if !covered[[3]string{string(entity), from, to}] {
+					t.Errorf("переход %s: %s -> %s не покрыт тестом", entity, from, to)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckTransitionUnknownStatus(t *testing.T) {
+	tests := []struct {
+		entity Entity
+		from   string
+		to     string
+	}{
+		{EntityDocument, "Черновик", models.StatusInReview},
+		{EntityDocument, models.StatusDraft, ""},
+		{EntityProcess, models.ProcessStatusInProgress, models.StatusApproved},
+		{"folder", models.StatusDraft, models.StatusInReview},
+	}
+
+	for _, tt := range tests {
+		if err := CheckTransition(tt.entity, tt.from, tt.to); !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("CheckTransition(%s, %q, %q) = %v, ожидалась недопустимая смена статуса", tt.entity, tt.from, tt.to, err)
+		}
+	}
+}
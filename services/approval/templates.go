@@ -0,0 +1,481 @@
+package approval
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"document-approval/config"
+	"document-approval/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrTemplateNotFound = errors.New("шаблон маршрута не найден")
+
+// templateDefinition - содержимое версии шаблона, хранится в JSONB
+type templateDefinition struct {
+	DecisionRule string                      `json:"decision_rule,omitempty"`
+	Quorum       int                         `json:"quorum,omitempty"`
+	Stages       []models.RouteTemplateStage `json:"stages"`
+	Rules        []models.RoutingRule        `json:"rules,omitempty"`
+}
+
+const templateColumns = `
+    t.id, t.document_type, t.name, t.is_active, t.created_at, t.updated_at,
+    v.version, v.definition, v.created_by
+`
+
+// ListTemplates возвращает действующие шаблоны, при необходимости только для типа документа
+func (s *ApprovalService) ListTemplates(documentType string) ([]models.RouteTemplate, error) {
+	rows, err := s.db.Query(`
+        SELECT `+templateColumns+`
+        FROM route_templates t
+        JOIN route_template_versions v ON v.template_id = t.id AND v.version = t.current_version
+        WHERE t.is_active AND ($1 = '' OR t.document_type = $1)
+        ORDER BY t.document_type, t.id
+    `, documentType)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения шаблонов: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.RouteTemplate
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+
+	return templates, nil
+}
+
+// GetTemplate возвращает шаблон в его текущей версии
+func (s *ApprovalService) GetTemplate(id int64) (*models.RouteTemplate, error) {
+	row := s.db.QueryRow(`
+        SELECT `+templateColumns+`
+        FROM route_templates t
+        JOIN route_template_versions v ON v.template_id = t.id AND v.version = t.current_version
+        WHERE t.id = $1
+    `, id)
+
+	t, err := scanTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// GetTemplateVersions возвращает историю версий шаблона, начиная с последней
+func (s *ApprovalService) GetTemplateVersions(id int64) ([]models.RouteTemplate, error) {
+	rows, err := s.db.Query(`
+        SELECT `+templateColumns+`
+        FROM route_templates t
+        JOIN route_template_versions v ON v.template_id = t.id
+        WHERE t.id = $1
+        ORDER BY v.version DESC
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения версий шаблона: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []models.RouteTemplate
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *t)
+	}
+
+	if len(versions) == 0 {
+		return nil, ErrTemplateNotFound
+	}
+
+	return versions, nil
+}
+
+// CreateTemplate создает шаблон для типа документа с первой версией маршрута
+func (s *ApprovalService) CreateTemplate(t *models.RouteTemplate) error {
+	if err := s.validateTemplate(t); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM route_templates WHERE document_type = $1 AND is_active)
+    `, t.DocumentType).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки шаблонов: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("для типа документа %s уже есть шаблон маршрута", t.DocumentType)
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO route_templates (document_type, name, current_version)
+        VALUES ($1, $2, 1)
+        RETURNING id, is_active, created_at, updated_at
+    `, t.DocumentType, t.Name).Scan(&t.ID, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания шаблона: %w", err)
+	}
+
+	t.Version = 1
+	if err := insertTemplateVersion(tx, t); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateTemplate сохраняет новую версию шаблона. Тип документа не меняется.
+func (s *ApprovalService) UpdateTemplate(t *models.RouteTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+        SELECT document_type, current_version + 1, created_at FROM route_templates
+        WHERE id = $1 AND is_active
+        FOR UPDATE
+    `, t.ID).Scan(&t.DocumentType, &t.Version, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrTemplateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения шаблона: %w", err)
+	}
+
+	if err := s.validateTemplate(t); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+        UPDATE route_templates
+        SET name = $1, current_version = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3
+        RETURNING is_active, updated_at
+    `, t.Name, t.Version, t.ID).Scan(&t.IsActive, &t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления шаблона: %w", err)
+	}
+
+	if err := insertTemplateVersion(tx, t); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTemplate отключает шаблон. Версии сохраняются, так как на них
+// ссылаются уже запущенные процессы.
+func (s *ApprovalService) DeleteTemplate(id int64) error {
+	result, err := s.db.Exec(`
+        UPDATE route_templates SET is_active = FALSE, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND is_active
+    `, id)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления шаблона: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTemplateNotFound
+	}
+
+	return nil
+}
+
+// BuildRouteFromTemplate строит маршрут для документа по шаблону его типа
+// (или по явно указанному шаблону) с учетом правил маршрутизации и изменений
+// для этого запуска. Номера этапов в изменениях - номера этапов шаблона;
+// изменение этапа, пропущенного правилами, возвращает ошибку.
+func (s *ApprovalService) BuildRouteFromTemplate(documentID int64, templateID *int64, overrides models.RouteOverrides) (models.ApprovalRoute, error) {
+	var route models.ApprovalRoute
+
+	doc, err := s.loadRoutingDocument(documentID)
+	if err != nil {
+		return route, err
+	}
+
+	var t *models.RouteTemplate
+	if templateID != nil {
+		t, err = s.GetTemplate(*templateID)
+		if err == nil && !t.IsActive {
+			err = ErrTemplateNotFound
+		}
+	} else {
+		t, err = s.activeTemplateForType(doc.DocumentType)
+	}
+	if err != nil {
+		return route, err
+	}
+
+	stages, origins, err := ApplyRoutingRules(t, RoutingEnv(doc))
+	if err != nil {
+		return route, err
+	}
+	if err := checkOverrides(len(t.Stages), origins, overrides); err != nil {
+		return route, err
+	}
+
+	route.DecisionRule = t.DecisionRule
+	route.Quorum = t.Quorum
+	if overrides.DecisionRule != "" {
+		route.DecisionRule = overrides.DecisionRule
+		route.Quorum = overrides.Quorum
+	}
+	route.TemplateID = &t.ID
+	route.TemplateVersion = t.Version
+
+	// Пользователь, попавший в несколько этапов по ролям, участвует только в первом
+	assigned := make(map[int64]bool)
+	for i, ts := range stages {
+		stage := models.ApprovalStage{
+			DecisionRule: ts.DecisionRule,
+			Quorum:       ts.Quorum,
+			DueHours:     ts.DueHours,
+		}
+
+		override, ok := overrides.Stages[origins[i]]
+		if ok && len(override.ApproverIDs) > 0 {
+			stage.ApproverIDs = override.ApproverIDs
+		} else {
+			ids, err := s.resolveStageApprovers(ts)
+			if err != nil {
+				return route, err
+			}
+			for _, id := range ids {
+				if !assigned[id] {
+					stage.ApproverIDs = append(stage.ApproverIDs, id)
+				}
+			}
+		}
+		if ok && override.DecisionRule != "" {
+			stage.DecisionRule = override.DecisionRule
+			stage.Quorum = override.Quorum
+		}
+		if ok && override.DueHours > 0 {
+			stage.DueHours = override.DueHours
+		}
+
+		if len(stage.ApproverIDs) == 0 {
+			return route, fmt.Errorf("для этапа %d маршрута не найдено активных утверждающих", i+1)
+		}
+		for _, id := range stage.ApproverIDs {
+			assigned[id] = true
+		}
+
+		route.Stages = append(route.Stages, stage)
+	}
+
+	return route, nil
+}
+
+func (s *ApprovalService) activeTemplateForType(documentType string) (*models.RouteTemplate, error) {
+	row := s.db.QueryRow(`
+        SELECT `+templateColumns+`
+        FROM route_templates t
+        JOIN route_template_versions v ON v.template_id = t.id AND v.version = t.current_version
+        WHERE t.document_type = $1 AND t.is_active
+    `, documentType)
+
+	t, err := scanTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// resolveStageApprovers возвращает явно указанных пользователей этапа
+// и активных пользователей с ролями этапа
+func (s *ApprovalService) resolveStageApprovers(stage models.RouteTemplateStage) ([]int64, error) {
+	rows, err := s.db.Query(`
+        SELECT u.id FROM users u
+        WHERE u.is_active AND (
+            u.id = ANY($1) OR EXISTS (
+                SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role = ANY($2)
+            )
+        )
+        ORDER BY u.id
+    `, pq.Array(stage.UserIDs), pq.Array(stage.Roles))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения утверждающих этапа: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования утверждающего: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// validateTemplate проверяет шаблон при сохранении
+func (s *ApprovalService) validateTemplate(t *models.RouteTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("название шаблона обязательно")
+	}
+
+	var knownType bool
+	for _, docType := range config.DocumentTypes {
+		if docType.ID == t.DocumentType {
+			knownType = true
+			break
+		}
+	}
+	if !knownType {
+		return fmt.Errorf("неизвестный тип документа: %s", t.DocumentType)
+	}
+
+	if len(t.Stages) == 0 {
+		return fmt.Errorf("шаблон должен содержать хотя бы один этап")
+	}
+	if t.DecisionRule != "" {
+		if err := validateTemplateRule(t.DecisionRule, t.Quorum, 0); err != nil {
+			return err
+		}
+	}
+
+	var userIDs []int64
+	for i, stage := range t.Stages {
+		if err := validateTemplateStage(stage); err != nil {
+			return fmt.Errorf("этап %d: %w", i+1, err)
+		}
+		userIDs = append(userIDs, stage.UserIDs...)
+	}
+
+	for i, rule := range t.Rules {
+		if err := ValidateRoutingRule(rule, t.DocumentType, len(t.Stages)); err != nil {
+			return fmt.Errorf("правило %d: %w", i+1, err)
+		}
+		if rule.AddStage != nil {
+			if err := validateTemplateStage(*rule.AddStage); err != nil {
+				return fmt.Errorf("правило %d: %w", i+1, err)
+			}
+			userIDs = append(userIDs, rule.AddStage.UserIDs...)
+		}
+	}
+
+	userIDs = uniqueIDs(userIDs)
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var activeCount int
+	err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM users WHERE id = ANY($1) AND is_active
+    `, pq.Array(userIDs)).Scan(&activeCount)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки пользователей шаблона: %w", err)
+	}
+	if activeCount != len(userIDs) {
+		return fmt.Errorf("в шаблоне есть неизвестные или отключенные пользователи")
+	}
+
+	return nil
+}
+
+func validateTemplateStage(stage models.RouteTemplateStage) error {
+	if len(stage.Roles) == 0 && len(stage.UserIDs) == 0 {
+		return fmt.Errorf("не указаны ни роли, ни пользователи")
+	}
+	if stage.DueHours < 0 {
+		return fmt.Errorf("срок этапа не может быть отрицательным")
+	}
+	for _, role := range stage.Roles {
+		if !models.Role(role).Valid() {
+			return fmt.Errorf("неизвестная роль %s", role)
+		}
+	}
+	if stage.DecisionRule != "" {
+		// Число утверждающих известно заранее, только если ролей нет
+		known := 0
+		if len(stage.Roles) == 0 {
+			known = len(stage.UserIDs)
+		}
+		return validateTemplateRule(stage.DecisionRule, stage.Quorum, known)
+	}
+	return nil
+}
+
+// validateTemplateRule проверяет правило, когда число утверждающих может быть
+// еще неизвестно (total = 0). Окончательная проверка - при запуске процесса.
+func validateTemplateRule(rule string, quorum, total int) error {
+	if total == 0 {
+		total = quorum
+		if rule == models.RuleNOfM && quorum < 1 {
+			return fmt.Errorf("кворум должен быть не меньше 1")
+		}
+	}
+	return ValidateRule(rule, quorum, total)
+}
+
+func insertTemplateVersion(tx *sql.Tx, t *models.RouteTemplate) error {
+	definition, err := json.Marshal(templateDefinition{
+		DecisionRule: t.DecisionRule,
+		Quorum:       t.Quorum,
+		Stages:       t.Stages,
+		Rules:        t.Rules,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации шаблона: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO route_template_versions (template_id, version, definition, created_by)
+        VALUES ($1, $2, $3, $4)
+    `, t.ID, t.Version, definition, t.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения версии шаблона: %w", err)
+	}
+
+	return nil
+}
+
+func scanTemplate(row interface{ Scan(...any) error }) (*models.RouteTemplate, error) {
+	var t models.RouteTemplate
+	var definition []byte
+
+	err := row.Scan(
+		&t.ID, &t.DocumentType, &t.Name, &t.IsActive, &t.CreatedAt, &t.UpdatedAt,
+		&t.Version, &definition, &t.CreatedBy,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сканирования шаблона: %w", err)
+	}
+
+	var def templateDefinition
+	if err := json.Unmarshal(definition, &def); err != nil {
+		return nil, fmt.Errorf("ошибка разбора шаблона: %w", err)
+	}
+
+	t.DecisionRule = def.DecisionRule
+	t.Quorum = def.Quorum
+	t.Stages = def.Stages
+	t.Rules = def.Rules
+
+	return &t, nil
+}
@@ -0,0 +1,359 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"document-approval/models"
+)
+
+// auditLockKey - ключ advisory lock, под которым записи добавляются в цепочку
+// строго по одной
+const auditLockKey = 730018
+
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// entitySnapshots - запросы снимка состояния сущности по первому сегменту
+// пути API. Секреты и извлеченный текст файла в журнал не попадают.
+var entitySnapshots = map[string]string{
+	"documents": `SELECT to_jsonb(t) - 'file_content' FROM documents t WHERE t.id = $1`,
+	"folders":   `SELECT to_jsonb(t) FROM folders t WHERE t.id = $1`,
+	"users": `
+        SELECT (to_jsonb(t) - 'password_hash') || jsonb_build_object('roles', COALESCE(
+            (SELECT jsonb_agg(ur.role ORDER BY ur.role) FROM user_roles ur WHERE ur.user_id = t.id),
+            '[]'::jsonb))
+        FROM users t WHERE t.id = $1`,
+	"approvals": `
+        SELECT to_jsonb(t) || jsonb_build_object('approvers', COALESCE(
+            (SELECT jsonb_agg(to_jsonb(a) ORDER BY a.id) FROM approvers a WHERE a.process_id = t.id),
+            '[]'::jsonb))
+        FROM approval_processes t WHERE t.id = $1`,
+	"route-templates": `SELECT to_jsonb(t) FROM route_templates t WHERE t.id = $1`,
+	"api-keys":        `SELECT to_jsonb(t) - 'key_hash' FROM api_keys t WHERE t.id = $1`,
+	"delegations":     `SELECT to_jsonb(t) FROM approval_delegations t WHERE t.id = $1`,
+}
+
+// HasSnapshot сообщает, умеет ли журнал сохранять состояние сущности
+func HasSnapshot(entityType string) bool {
+	_, ok := entitySnapshots[entityType]
+	return ok
+}
+
+// Snapshot возвращает текущее состояние сущности или nil, если ее нет
+func (s *AuditService) Snapshot(entityType string, id int64) (json.RawMessage, error) {
+	query, ok := entitySnapshots[entityType]
+	if !ok {
+		return nil, nil
+	}
+
+	var data []byte
+	err := s.db.QueryRow(query, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения состояния сущности: %w", err)
+	}
+
+	return data, nil
+}
+
+// Record добавляет запись в конец цепочки журнала
+func (s *AuditService) Record(e *models.AuditEntry) error {
+	var err error
+	if e.Before, err = canonicalJSON(e.Before); err != nil {
+		return err
+	}
+	if e.After, err = canonicalJSON(e.After); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return fmt.Errorf("ошибка получения блокировки журнала: %w", err)
+	}
+
+	err = tx.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("ошибка получения последней записи журнала: %w", err)
+	}
+
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	e.Hash, err = entryHash(e)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO audit_log (
+            created_at, request_id, user_id, api_key_id, ip, method, path, action,
+            entity_type, entity_id, status_code, before, after, prev_hash, hash
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        RETURNING id
+    `,
+		e.CreatedAt, e.RequestID, e.UserID, e.APIKeyID, e.IP, e.Method, e.Path, e.Action,
+		e.EntityType, e.EntityID, e.StatusCode, nullJSON(e.Before), nullJSON(e.After),
+		e.PrevHash, e.Hash,
+	).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал аудита: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Filter - условия выборки журнала. Пустые поля не ограничивают выборку.
+type Filter struct {
+	EntityType string
+	EntityID   *int64
+	UserID     *int64
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// Query возвращает записи журнала, начиная с последних
+func (s *AuditService) Query(f Filter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var params []any
+	add := func(cond string, value any) {
+		params = append(params, value)
+		conditions = append(conditions, fmt.Sprintf(cond, len(params)))
+	}
+
+	if f.EntityType != "" {
+		add("entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != nil {
+		add("entity_id = $%d", *f.EntityID)
+	}
+	if f.UserID != nil {
+		add("user_id = $%d", *f.UserID)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", f.From.UTC())
+	}
+	if f.To != nil {
+		add("created_at < $%d", f.To.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 100
+	}
+	params = append(params, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(`
+        SELECT `+entryColumns+`
+        FROM audit_log
+        `+where+`
+        ORDER BY id DESC
+        LIMIT $`+fmt.Sprint(len(params)-1)+` OFFSET $`+fmt.Sprint(len(params)), params...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала аудита: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+// VerifyResult - итог проверки цепочки журнала. HeadID и HeadHash - последняя
+// проверенная запись; ее хеш стоит сохранить вне БД и передать в следующую
+// проверку.
+type VerifyResult struct {
+	Checked  int    `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenID int64  `json:"broken_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	HeadID   int64  `json:"head_id,omitempty"`
+	HeadHash string `json:"head_hash,omitempty"`
+}
+
+// Verify пересчитывает хеши всех записей и проверяет связи между ними.
+// Останавливается на первой записи, которая не сходится.
+//
+// Цепочка сама по себе не выдает удаление последних записей. Если передан
+// anchor - сохраненный ранее хеш головы цепочки, - запись с этим хешем
+// должна найтись в журнале.
+func (s *AuditService) Verify(anchor string) (*VerifyResult, error) {
+	rows, err := s.db.Query(`SELECT ` + entryColumns + ` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+	defer rows.Close()
+
+	result := &VerifyResult{Valid: true}
+	prevHash := ""
+	anchorFound := anchor == ""
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Checked++
+
+		if e.PrevHash != prevHash {
+			result.Valid, result.BrokenID = false, e.ID
+			result.Reason = "ссылка на предыдущую запись не совпадает: запись удалена или изменена"
+			return result, nil
+		}
+
+		hash, err := entryHash(e)
+		if err != nil {
+			return nil, err
+		}
+		if hash != e.Hash {
+			result.Valid, result.BrokenID = false, e.ID
+			result.Reason = "хеш записи не совпадает: содержимое изменено"
+			return result, nil
+		}
+
+		prevHash = e.Hash
+		result.HeadID, result.HeadHash = e.ID, e.Hash
+		if e.Hash == anchor {
+			anchorFound = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+
+	if !anchorFound {
+		result.Valid = false
+		result.Reason = "запись с сохраненным хешем головы не найдена: последние записи удалены"
+	}
+
+	return result, nil
+}
+
+const entryColumns = `
+    id, created_at, request_id, user_id, api_key_id, ip, method, path, action,
+    entity_type, entity_id, status_code, before, after, prev_hash, hash
+`
+
+func scanEntry(row interface{ Scan(...any) error }) (*models.AuditEntry, error) {
+	var e models.AuditEntry
+	var before, after []byte
+
+	err := row.Scan(
+		&e.ID, &e.CreatedAt, &e.RequestID, &e.UserID, &e.APIKeyID, &e.IP, &e.Method, &e.Path, &e.Action,
+		&e.EntityType, &e.EntityID, &e.StatusCode, &before, &after, &e.PrevHash, &e.Hash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сканирования записи журнала: %w", err)
+	}
+
+	if e.Before, err = canonicalJSON(before); err != nil {
+		return nil, err
+	}
+	if e.After, err = canonicalJSON(after); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// entryHash - SHA-256 от хеша предыдущей записи и полей записи
+func entryHash(e *models.AuditEntry) (string, error) {
+	payload, err := json.Marshal(struct {
+		CreatedAt  string          `json:"created_at"`
+		RequestID  string          `json:"request_id"`
+		UserID     *int64          `json:"user_id"`
+		APIKeyID   *int64          `json:"api_key_id"`
+		IP         string          `json:"ip"`
+		Method     string          `json:"method"`
+		Path       string          `json:"path"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   *int64          `json:"entity_id"`
+		StatusCode int             `json:"status_code"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+	}{
+		CreatedAt:  e.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		RequestID:  e.RequestID,
+		UserID:     e.UserID,
+		APIKeyID:   e.APIKeyID,
+		IP:         e.IP,
+		Method:     e.Method,
+		Path:       e.Path,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		StatusCode: e.StatusCode,
+		Before:     orNull(e.Before),
+		After:      orNull(e.After),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации записи журнала: %w", err)
+	}
+
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), payload...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON приводит JSON к виду, который не меняется после хранения
+// в jsonb: ключи по алфавиту, без лишних пробелов
+func canonicalJSON(data []byte) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("ошибка разбора состояния сущности: %w", err)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации состояния сущности: %w", err)
+	}
+	return out, nil
+}
+
+func orNull(data json.RawMessage) json.RawMessage {
+	if len(data) == 0 {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
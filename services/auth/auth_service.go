@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"document-approval/models"
+	"document-approval/pkg/jwt"
+	"document-approval/services/user"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("недействительный refresh токен")
+	ErrRefreshTokenReused  = errors.New("refresh токен уже был использован")
+)
+
+type Config struct {
+	Algorithm     string
+	Secret        []byte
+	PrivateKeyPEM []byte
+	PublicKeyPEM  []byte
+	Issuer        string
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+}
+
+// Tokens - пара токенов, выдаваемая при входе и обновлении сессии
+type Tokens struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int64     `json:"expires_in"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type AuthService struct {
+	db          *sql.DB
+	userService *user.UserService
+	codec       *jwt.Codec
+	issuer      string
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+func NewAuthService(db *sql.DB, userService *user.UserService, cfg Config) (*AuthService, error) {
+	var codec *jwt.Codec
+	var err error
+
+	switch cfg.Algorithm {
+	case "", jwt.AlgHS256:
+		codec, err = jwt.NewHS256(cfg.Secret)
+	case jwt.AlgRS256:
+		codec, err = jwt.NewRS256(cfg.PrivateKeyPEM, cfg.PublicKeyPEM)
+	default:
+		err = fmt.Errorf("неподдерживаемый алгоритм подписи: %s", cfg.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки подписи токенов: %w", err)
+	}
+
+	codec.SetIssuer(cfg.Issuer)
+
+	if cfg.AccessTTL == 0 {
+		cfg.AccessTTL = 15 * time.Minute
+	}
+	if cfg.RefreshTTL == 0 {
+		cfg.RefreshTTL = 30 * 24 * time.Hour
+	}
+
+	return &AuthService{
+		db:          db,
+		userService: userService,
+		codec:       codec,
+		issuer:      cfg.Issuer,
+		accessTTL:   cfg.AccessTTL,
+		refreshTTL:  cfg.RefreshTTL,
+	}, nil
+}
+
+// IssueTokens выдает новую пару токенов и открывает новое семейство refresh токенов
+func (s *AuthService) IssueTokens(u *models.User) (*Tokens, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	tokens, err := s.issue(tx, u.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения токена: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Refresh обменивает refresh токен на новую пару (ротация).
+// Повторное использование уже замененного токена отзывает все семейство.
+func (s *AuthService) Refresh(refreshToken string) (*Tokens, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id        int64
+		userID    int64
+		familyID  string
+		expiresAt time.Time
+		revokedAt sql.NullTime
+	)
+	err = tx.QueryRow(`
+        SELECT id, user_id, family_id, expires_at, revoked_at
+        FROM refresh_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, hashToken(refreshToken)).Scan(&id, &userID, &familyID, &expiresAt, &revokedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки refresh токена: %w", err)
+	}
+
+	if revokedAt.Valid {
+		// Токен уже был отозван: вероятна кража, отзываем всю цепочку
+		if _, err := tx.Exec(`
+            UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+            WHERE family_id = $1 AND revoked_at IS NULL
+        `, familyID); err != nil {
+			return nil, fmt.Errorf("ошибка отзыва токенов: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("ошибка отзыва токенов: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Отключенный пользователь не может продлить сессию
+	u, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsActive {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokens, err := s.issue(tx, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE refresh_tokens
+        SET revoked_at = CURRENT_TIMESTAMP,
+            replaced_by = (SELECT id FROM refresh_tokens WHERE token_hash = $1)
+        WHERE id = $2
+    `, hashToken(tokens.RefreshToken), id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка ротации refresh токена: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка ротации refresh токена: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Revoke отзывает семейство, к которому относится refresh токен (выход из сессии)
+func (s *AuthService) Revoke(refreshToken string) error {
+	_, err := s.db.Exec(`
+        UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+        WHERE revoked_at IS NULL AND family_id = (
+            SELECT family_id FROM refresh_tokens WHERE token_hash = $1
+        )
+    `, hashToken(refreshToken))
+
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва токена: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllForUser завершает все сессии пользователя
+func (s *AuthService) RevokeAllForUser(userID int64) error {
+	_, err := s.db.Exec(`
+        UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `, userID)
+
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва токенов пользователя: %w", err)
+	}
+
+	return nil
+}
+
+// ParseAccessToken проверяет подпись, издателя и срок действия токена доступа
+func (s *AuthService) ParseAccessToken(token string) (*jwt.Claims, error) {
+	return s.codec.Parse(token)
+}
+
+func (s *AuthService) issue(tx *sql.Tx, userID int64, familyID string) (*Tokens, error) {
+	roles, err := s.userService.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessExpiresAt := now.Add(s.accessTTL)
+	refreshExpiresAt := now.Add(s.refreshTTL)
+
+	accessToken, err := s.codec.Sign(jwt.Claims{
+		UserID:    userID,
+		Roles:     roles,
+		Issuer:    s.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: accessExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+        VALUES ($1, $2, $3, $4)
+    `, userID, hashToken(refreshToken), familyID, refreshExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения refresh токена: %w", err)
+	}
+
+	return &Tokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.accessTTL.Seconds()),
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
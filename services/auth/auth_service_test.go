@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"document-approval/pkg/sqltest"
+	"document-approval/services/user"
+)
+
+var tokenColumns = []string{"id", "user_id", "family_id", "expires_at", "revoked_at"}
+
+var userColumns = []string{"id", "esia_id", "first_name", "last_name", "email", "position", "manager_id", "is_active", "created_at"}
+
+func newTestService(t *testing.T) (*AuthService, *sqltest.Mock) {
+	t.Helper()
+	db, mock := sqltest.Open(t)
+	s, err := NewAuthService(db, user.NewUserService(db), Config{
+		Secret: []byte("0123456789abcdef0123456789abcdef"),
+		Issuer: "document-approval",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	return s, mock
+}
+
+func expectUser(mock *sqltest.Mock, id int64, active bool) {
+	mock.ExpectQuery("FROM users WHERE id = $1").WithArgs(id).
+		WillReturnRows(userColumns, []any{id, "", "Иван", "Иванов", "ivanov@pomau.ru", "Юрист", nil, active, time.Now()})
+}
+
+func TestRefreshRotates(t *testing.T) {
+	s, mock := newTestService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE").WithArgs(hashToken("old")).
+		WillReturnRows(tokenColumns, []any{int64(10), int64(7), "family", time.Now().Add(time.Hour), nil})
+	expectUser(mock, 7, true)
+	mock.ExpectQuery("SELECT role FROM user_roles WHERE user_id = $1").WithArgs(int64(7)).
+		WillReturnRows([]string{"role"}, []any{"approver"})
+	mock.ExpectExec("INSERT INTO refresh_tokens").WithArgs(int64(7), sqltest.Any, "family", sqltest.Any)
+	mock.ExpectExec("SET revoked_at = CURRENT_TIMESTAMP, replaced_by").WithArgs(sqltest.Any, int64(10))
+	mock.ExpectCommit()
+
+	tokens, err := s.Refresh("old")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if tokens.RefreshToken == "" || tokens.RefreshToken == "old" {
+		t.Errorf("refresh токен не заменен: %q", tokens.RefreshToken)
+	}
+
+	claims, err := s.ParseAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims.UserID != 7 || len(claims.Roles) != 1 || claims.Roles[0] != "approver" {
+		t.Errorf("claims %+v", claims)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	s, mock := newTestService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE").WithArgs(hashToken("stolen")).
+		WillReturnRows(tokenColumns, []any{int64(10), int64(7), "family", time.Now().Add(time.Hour), time.Now().Add(-time.Minute)})
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL").
+		WithArgs("family").WillReturnResult(2)
+	mock.ExpectCommit()
+
+	if _, err := s.Refresh("stolen"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, ErrRefreshTokenReused)
+	}
+}
+
+func TestRefreshRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(mock *sqltest.Mock)
+	}{
+		{"неизвестный токен", func(mock *sqltest.Mock) {
+			mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1").WillReturnRows(tokenColumns)
+		}},
+		{"истек", func(mock *sqltest.Mock) {
+			mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1").
+				WillReturnRows(tokenColumns, []any{int64(10), int64(7), "family", time.Now().Add(-time.Minute), nil})
+		}},
+		{"пользователь отключен", func(mock *sqltest.Mock) {
+			mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1").
+				WillReturnRows(tokenColumns, []any{int64(10), int64(7), "family", time.Now().Add(time.Hour), nil})
+			expectUser(mock, 7, false)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newTestService(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+			// Новый токен не выдается, транзакция откатывается
+			mock.ExpectRollback()
+
+			if _, err := s.Refresh("token"); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, ErrInvalidRefreshToken)
+			}
+		})
+	}
+}
+
+func TestRefreshDatabaseError(t *testing.T) {
+	s, mock := newTestService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens WHERE token_hash = $1").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	_, err := s.Refresh("token")
+	if err == nil || errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("ошибка БД не должна выдаваться за неверный токен: %v", err)
+	}
+}
+
+func TestNewAuthServiceRejectsUnknownAlgorithm(t *testing.T) {
+	db, _ := sqltest.Open(t)
+	if _, err := NewAuthService(db, user.NewUserService(db), Config{Algorithm: "none"}); err == nil {
+		t.Fatal("алгоритм none должен отклоняться")
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"regexp"
 	"strings"
 
 	"document-approval/models"
+	"document-approval/services/events"
+	"document-approval/services/notification"
 	"document-approval/services/storage"
+	"document-approval/services/webhook"
 )
 
 type DocumentService struct {
@@ -25,28 +27,26 @@ func NewDocumentService(db *sql.DB, storage storage.StorageService) *DocumentSer
 	}
 }
 
-func (s *DocumentService) CreateDocument(doc *models.Document, file io.Reader, filename string) error {
+func (s *DocumentService) CreateDocument(doc *models.Document, file io.Reader, filename string, actorID int64) (err error) {
 	// Валидация обязательных полей
 	if err := s.validateDocument(doc); err != nil {
 		return err
 	}
 
-	// Сохраняем файл если есть
+	// Сохраняем файл если есть, он станет первой версией документа
+	var version *models.DocumentVersion
 	var filePath string
-	var fileContent string
-	var err error
 	if file != nil {
-		filePath, err = s.storage.SaveFile(file, filename)
+		version, err = StoreFile(s.storage, file, filename)
 		if err != nil {
-			return fmt.Errorf("ошибка сохранения файла: %w", err)
-		}
-
-		// Извлекаем текст из файла
-		fileContent, err = s.storage.ExtractText(filePath)
-		if err != nil {
-			// Логируем ошибку, но продолжаем выполнение
-			log.Printf("ошибка извлечения текста из файла: %v", err)
+			return err
 		}
+		filePath = version.FilePath
+		defer func() {
+			if err != nil {
+				DiscardFile(s.storage, version)
+			}
+		}()
 	}
 
 	// Если метаданные не указаны, используем пустой объект
@@ -60,18 +60,23 @@ func (s *DocumentService) CreateDocument(doc *models.Document, file io.Reader, f
 		return fmt.Errorf("ошибка сериализации метаданных: %w", err)
 	}
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Сохраняем документ в БД
 	query := `
         INSERT INTO documents (
             title, receipt_date, deadline_date, incoming_number,
             contact_person, kopuk, museum_name, founder, 
-            founder_inn, file_path, status, document_type, metadata,
-            file_content
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+            founder_inn, file_path, status, document_type, metadata
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
         RETURNING id
     `
 
-	err = s.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		doc.Title,
 		doc.ReceiptDate,
@@ -83,16 +88,37 @@ func (s *DocumentService) CreateDocument(doc *models.Document, file io.Reader, f
 		doc.Founder,
 		doc.FounderINN,
 		filePath,
-		"Черновик",
+		models.StatusDraft,
 		doc.DocumentType,
 		metadataJSON,
-		fileContent,
 	).Scan(&doc.ID)
 
 	if err != nil {
 		return fmt.Errorf("ошибка сохранения документа: %w", err)
 	}
 
+	if version != nil {
+		if err := AddVersion(tx, doc.ID, version, actorID); err != nil {
+			return err
+		}
+	}
+
+	err = events.Record(tx, doc.ID, models.EventCreated, &actorID, nil, map[string]any{
+		"title":     doc.Title,
+		"file_path": filePath,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := webhook.EmitDocument(tx, doc.ID, models.WebhookDocumentCreated, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка сохранения документа: %w", err)
+	}
+
 	return nil
 }
 
@@ -177,8 +203,12 @@ func (s *DocumentService) SearchDocuments(query string, filters map[string]inter
 			statuses := strings.Split(value.(string), ",")
 			placeholders := make([]string, len(statuses))
 			for i := range statuses {
+				code, ok := models.NormalizeDocumentStatus(statuses[i])
+				if !ok {
+					return nil, fmt.Errorf("неизвестный статус документа: %s", statuses[i])
+				}
 				placeholders[i] = fmt.Sprintf("$%d", paramCount)
-				params = append(params, statuses[i])
+				params = append(params, code)
 				paramCount++
 			}
 			baseQuery += fmt.Sprintf(" AND status = ANY(ARRAY[%s])", strings.Join(placeholders, ","))
@@ -251,132 +281,6 @@ func (s *DocumentService) SearchDocuments(query string, filters map[string]inter
 	return documents, nil
 }
 
-func (s *DocumentService) StartApprovalProcess(documentID int64, approverIDs []int64) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("ошибка начала транзакции: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Создаем процесс утверждения
-	var processID int64
-	err = tx.QueryRow(`
-        INSERT INTO approval_processes (document_id, status)
-        VALUES ($1, 'В процессе')
-        RETURNING id
-    `, documentID).Scan(&processID)
-
-	if err != nil {
-		return fmt.Errorf("ошибка создания процесса утверждения: %w", err)
-	}
-
-	// Добавляем утверждающих
-	for _, approverID := range approverIDs {
-		_, err = tx.Exec(`
-            INSERT INTO approvers (process_id, user_id, status)
-            VALUES ($1, $2, 'Ожидает')
-        `, processID, approverID)
-
-		if err != nil {
-			return fmt.Errorf("ошибка добавления утверждающего: %w", err)
-		}
-	}
-
-	// Обновляем статус документа
-	_, err = tx.Exec(`
-        UPDATE documents 
-        SET status = 'Рассматривается'
-        WHERE id = $1
-    `, documentID)
-
-	if err != nil {
-		return fmt.Errorf("ошибка обновления статуса документа: %w", err)
-	}
-
-	return tx.Commit()
-}
-
-func (s *DocumentService) ApproveDocument(processID int64, userID int64, approved bool, comment string) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("ошибка начала транзакции: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Обновляем статус утверждающего
-	status := "Утверждено"
-	if !approved {
-		status = "Отклонено"
-	}
-
-	_, err = tx.Exec(`
-        UPDATE approvers
-        SET status = $1, comment = $2, approved_at = CURRENT_TIMESTAMP
-        WHERE process_id = $3 AND user_id = $4
-    `, status, comment, processID, userID)
-
-	if err != nil {
-		return fmt.Errorf("ошибка обновления статуса утверждающего: %w", err)
-	}
-
-	// Проверяем, все ли утвердили документ
-	var allApproved bool
-	err = tx.QueryRow(`
-        SELECT NOT EXISTS (
-            SELECT 1 FROM approvers 
-            WHERE process_id = $1 AND status = 'Ожидает'
-        )
-    `, processID).Scan(&allApproved)
-
-	if err != nil {
-		return fmt.Errorf("ошибка проверки статусов утверждающих: %w", err)
-	}
-
-	if allApproved {
-		// Проверяем, есть ли отклонения
-		var hasRejections bool
-		err = tx.QueryRow(`
-            SELECT EXISTS (
-                SELECT 1 FROM approvers 
-                WHERE process_id = $1 AND status = 'Отклонено'
-            )
-        `, processID).Scan(&hasRejections)
-
-		if err != nil {
-			return fmt.Errorf("ошибка проверки отклонений: %w", err)
-		}
-
-		// Обновляем статус процесса и документа
-		finalStatus := "Утвержден"
-		if hasRejections {
-			finalStatus = "Отклонен"
-		}
-
-		_, err = tx.Exec(`
-            UPDATE approval_processes
-            SET status = 'Завершен'
-            WHERE id = $1
-        `, processID)
-
-		if err != nil {
-			return fmt.Errorf("ошибка обновления статуса процесса: %w", err)
-		}
-
-		_, err = tx.Exec(`
-            UPDATE documents d
-            SET status = $1
-            FROM approval_processes ap
-            WHERE ap.id = $2 AND ap.document_id = d.id
-        `, finalStatus, processID)
-
-		if err != nil {
-			return fmt.Errorf("ошибка обновления статуса документа: %w", err)
-		}
-	}
-
-	return tx.Commit()
-}
-
 func (s *DocumentService) GetDocument(id int64) (*models.Document, error) {
 	var doc models.Document
 	var metadataBytes []byte
@@ -387,7 +291,8 @@ func (s *DocumentService) GetDocument(id int64) (*models.Document, error) {
             d.id, d.title, d.receipt_date, d.deadline_date, d.completion_date,
             d.incoming_number, d.contact_person, d.kopuk, d.museum_name,
             d.founder, d.founder_inn, d.status, d.file_path, d.created_at,
-            d.document_type, d.metadata
+            d.document_type, d.metadata, d.current_version,
+            (SELECT COUNT(*) FROM document_versions v WHERE v.document_id = d.id)
         FROM documents d
         LEFT JOIN folder_documents fd ON d.id = fd.document_id
         WHERE d.id = $1
@@ -396,7 +301,7 @@ func (s *DocumentService) GetDocument(id int64) (*models.Document, error) {
 		&doc.CompletionDate, &doc.IncomingNumber, &doc.ContactPerson,
 		&doc.Kopuk, &doc.MuseumName, &doc.Founder, &doc.FounderINN,
 		&doc.Status, &doc.FilePath, &doc.CreatedAt,
-		&documentType, &metadataBytes,
+		&documentType, &metadataBytes, &doc.CurrentVersion, &doc.VersionCount,
 	)
 
 	if err == sql.ErrNoRows {
@@ -425,17 +330,49 @@ func (s *DocumentService) GetDocument(id int64) (*models.Document, error) {
 	return &doc, nil
 }
 
-func (s *DocumentService) UpdateDocument(doc *models.Document) (*models.Document, error) {
+func (s *DocumentService) UpdateDocument(doc *models.Document, actorID int64) (*models.Document, error) {
 	// Преобразуем map в JSON для metadata
 	metadataJSON, err := json.Marshal(doc.Metadata)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка сериализации метаданных: %w", err)
 	}
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Прежние значения нужны для записи изменений в историю
+	var before models.Document
+	var beforeMetadata []byte
+	var beforeType sql.NullString
+	err = tx.QueryRow(`
+        SELECT title, receipt_date, deadline_date, incoming_number, contact_person,
+               kopuk, museum_name, founder, founder_inn, document_type, metadata, status
+        FROM documents WHERE id = $1
+        FOR UPDATE
+    `, doc.ID).Scan(
+		&before.Title, &before.ReceiptDate, &before.DeadlineDate,
+		&before.IncomingNumber, &before.ContactPerson, &before.Kopuk,
+		&before.MuseumName, &before.Founder, &before.FounderINN,
+		&beforeType, &beforeMetadata, &before.Status,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("документ не найден")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if err := checkEditable(before.Status); err != nil {
+		return nil, err
+	}
+	before.DocumentType = beforeType.String
+
 	// Временная переменная для хранения JSON метаданных
 	var metadataBytes []byte
 
-	err = s.db.QueryRow(`
+	err = tx.QueryRow(`
         UPDATE documents SET
             title = $1,
             receipt_date = $2,
@@ -467,14 +404,11 @@ func (s *DocumentService) UpdateDocument(doc *models.Document) (*models.Document
 		&doc.DocumentType, &metadataBytes,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("документ не найден")
-	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка обновления документа: %w", err)
 	}
 
-	// Преоб��азуем JSON обратно в map
+	// Преобразуем JSON обратно в map
 	if metadataBytes != nil {
 		var metadata map[string]interface{}
 		if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
@@ -483,51 +417,219 @@ func (s *DocumentService) UpdateDocument(doc *models.Document) (*models.Document
 		doc.Metadata = metadata
 	}
 
+	if beforeMetadata != nil {
+		if err := json.Unmarshal(beforeMetadata, &before.Metadata); err != nil {
+			return nil, fmt.Errorf("ошибка десериализации метаданных: %w", err)
+		}
+	}
+
+	if changes := diffDocuments(&before, doc); len(changes) > 0 {
+		err = events.Record(tx, doc.ID, models.EventEdited, &actorID, nil, map[string]any{
+			"changes": changes,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = webhook.EmitDocument(tx, doc.ID, models.WebhookDocumentUpdated, map[string]any{
+			"changes": changes,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := notifyDocumentChanged(tx, doc.ID, actorID, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения документа: %w", err)
+	}
+
 	return doc, nil
 }
 
-func (s *DocumentService) SaveFile(doc *models.Document, file io.Reader) error {
+// ReplaceFile загружает новую версию файла документа: версия N+1 становится
+// текущей, прежние остаются доступными. Замена записывается в историю.
+// Файл меняется только в черновике и после отклонения.
+func (s *DocumentService) ReplaceFile(documentID int64, file io.Reader, filename, comment string, actorID int64) (_ *models.Document, err error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("ошибка начала транзакции: %w", err)
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Сохраняем файл
-	filePath, err := s.storage.SaveFile(file, doc.FilePath)
+	// Блокировка документа не дает двум загрузкам получить один номер версии
+	var previousPath sql.NullString
+	var status string
+	err = tx.QueryRow(`
+        SELECT file_path, status FROM documents WHERE id = $1 FOR UPDATE
+    `, documentID).Scan(&previousPath, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("документ не найден")
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения файла: %w", err)
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if err := checkEditable(status); err != nil {
+		return nil, err
 	}
 
-	// Обновляем путь к файлу после сохранения
-	doc.FilePath = filePath
+	version, err := StoreFile(s.storage, file, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			DiscardFile(s.storage, version)
+		}
+	}()
+	version.Comment = strings.TrimSpace(comment)
 
-	// Сохраняем документ в БД с привязкой к папке
-	err = tx.QueryRow(`
-        WITH inserted_doc AS (
-            INSERT INTO documents (
-                title, receipt_date, deadline_date, incoming_number,
-                contact_person, kopuk, museum_name, founder,
-                founder_inn, status, file_path, document_type, metadata
-            ) VALUES (
-                $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
-            )
-            RETURNING id, created_at
+	if err := AddVersion(tx, documentID, version, actorID); err != nil {
+		return nil, err
+	}
+
+	err = events.Record(tx, documentID, models.EventFileReplaced, &actorID, nil, map[string]any{
+		"from":     previousPath.String,
+		"to":       version.FilePath,
+		"version":  version.Version,
+		"checksum": *version.Checksum,
+		"comment":  version.Comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = webhook.EmitDocument(tx, documentID, models.WebhookDocumentUpdated, map[string]any{
+		"file_replaced": true,
+		"version":       version.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := notifyDocumentChanged(tx, documentID, actorID, &version.Version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения документа: %w", err)
+	}
+	// Файл теперь принадлежит зафиксированной версии и не удаляется,
+	// даже если не удастся прочитать документ
+	version = nil
+
+	return s.GetDocument(documentID)
+}
+
+// notifyDocumentChanged уведомляет об изменении документа тех, кого оно
+// касается: автора документа, инициатора и утверждающих его последнего
+// согласования - идущего или отклонившего документ, после которого документ
+// дорабатывают. version - номер загруженной версии, nil - изменены поля.
+func notifyDocumentChanged(tx *sql.Tx, documentID, actorID int64, version *int) error {
+	rows, err := tx.Query(`
+        WITH latest AS (
+            SELECT id, initiated_by FROM approval_processes
+            WHERE document_id = $1
+            ORDER BY id DESC
+            LIMIT 1
         )
-        INSERT INTO folder_documents (folder_id, document_id)
-        SELECT $14, id FROM inserted_doc
-        RETURNING (SELECT id FROM inserted_doc), (SELECT created_at FROM inserted_doc)
-    `,
-		doc.Title, doc.ReceiptDate, doc.DeadlineDate,
-		doc.IncomingNumber, doc.ContactPerson, doc.Kopuk,
-		doc.MuseumName, doc.Founder, doc.FounderINN,
-		doc.Status, doc.FilePath, doc.DocumentType, doc.Metadata,
-		doc.FolderID,
-	).Scan(&doc.ID, &doc.CreatedAt)
+        SELECT a.user_id FROM approvers a JOIN latest ON latest.id = a.process_id
+        UNION
+        SELECT initiated_by FROM latest WHERE initiated_by IS NOT NULL
+        UNION
+        SELECT actor_id FROM document_events
+        WHERE document_id = $1 AND event_type = $2 AND actor_id IS NOT NULL
+        ORDER BY 1
+    `, documentID, models.EventCreated)
+	if err != nil {
+		return fmt.Errorf("ошибка получения участников документа: %w", err)
+	}
+
+	var recipients []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования участника документа: %w", err)
+		}
+		if id != actorID {
+			recipients = append(recipients, id)
+		}
+	}
+	rows.Close()
 
+	data := map[string]any{}
+	if version != nil {
+		data["version"] = *version
+	}
+	return notification.CreateEach(tx, recipients, models.Notification{
+		Type:       models.NotificationDocumentChanged,
+		DocumentID: &documentID,
+		ActorID:    &actorID,
+		Data:       data,
+	})
+}
+
+// GetDocumentHistory возвращает хронологию событий документа
+func (s *DocumentService) GetDocumentHistory(documentID int64) ([]models.DocumentEvent, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1)`, documentID).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения документа: %w", err)
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("документ не найден")
+	}
+
+	return events.List(s.db, documentID)
+}
+
+// diffDocuments возвращает изменившиеся редактируемые поля документа
+// в виде {"поле": {"from": ..., "to": ...}}
+func diffDocuments(before, after *models.Document) map[string]any {
+	const dateLayout = "2006-01-02"
+
+	fields := []struct {
+		name     string
+		from, to any
+	}{
+		{"title", before.Title, after.Title},
+		{"receipt_date", before.ReceiptDate.Format(dateLayout), after.ReceiptDate.Format(dateLayout)},
+		{"deadline_date", before.DeadlineDate.Format(dateLayout), after.DeadlineDate.Format(dateLayout)},
+		{"incoming_number", before.IncomingNumber, after.IncomingNumber},
+		{"contact_person", before.ContactPerson, after.ContactPerson},
+		{"kopuk", before.Kopuk, after.Kopuk},
+		{"museum_name", before.MuseumName, after.MuseumName},
+		{"founder", before.Founder, after.Founder},
+		{"founder_inn", before.FounderINN, after.FounderINN},
+		{"document_type", before.DocumentType, after.DocumentType},
+	}
+
+	changes := map[string]any{}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes[f.name] = map[string]any{"from": f.from, "to": f.to}
+		}
+	}
+
+	// Метаданные сравниваем по ключам
+	keys := map[string]bool{}
+	for k := range before.Metadata {
+		keys[k] = true
+	}
+	for k := range after.Metadata {
+		keys[k] = true
+	}
+	for k := range keys {
+		from, _ := json.Marshal(before.Metadata[k])
+		to, _ := json.Marshal(after.Metadata[k])
+		if string(from) != string(to) {
+			changes["metadata."+k] = map[string]any{"from": before.Metadata[k], "to": after.Metadata[k]}
+		}
 	}
 
-	return tx.Commit()
+	return changes
 }
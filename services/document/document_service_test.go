@@ -0,0 +1,36 @@
+package document
+
+import (
+	"testing"
+
+	"document-approval/models"
+	"document-approval/pkg/sqltest"
+)
+
+func TestNotifyDocumentChanged(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	version := 3
+
+	mock.ExpectBegin()
+	// Автор 5, инициатор 9 (он же загрузил версию) и утверждающий 12
+	// последнего согласования
+	mock.ExpectQuery("WITH latest AS").WithArgs(int64(1), models.EventCreated).
+		WillReturnRows([]string{"user_id"}, []any{int64(5)}, []any{int64(9)}, []any{int64(12)})
+	// Уведомления получают все, кроме изменившего; получатели не найдены,
+	// поэтому дальше первого запроса Create не идет
+	for _, id := range []int64{5, 12} {
+		mock.ExpectQuery("SELECT u.is_active, COALESCE(p.language, 'ru')").WithArgs(id).
+			WillReturnRows([]string{"is_active", "language"})
+	}
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if err := notifyDocumentChanged(tx, 1, 9, &version); err != nil {
+		t.Fatalf("notifyDocumentChanged: %v", err)
+	}
+}
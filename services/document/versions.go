@@ -0,0 +1,192 @@
+package document
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"document-approval/models"
+	"document-approval/services/storage"
+)
+
+var (
+	ErrVersionNotFound = errors.New("версия документа не найдена")
+	ErrDocumentLocked  = errors.New("документ на согласовании или утвержден, изменить его нельзя")
+)
+
+// StoreFile сохраняет файл в хранилище и готовит его версию: считает SHA-256
+// и размер по мере записи и извлекает текст для поиска. Файл записывается до
+// фиксации транзакции, которая на него сошлется; если она не зафиксирована,
+// файл удаляется вызовом DiscardFile.
+func StoreFile(store storage.StorageService, file io.Reader, filename string) (*models.DocumentVersion, error) {
+	h := sha256.New()
+	var size sizeCounter
+	filePath, err := store.SaveFile(io.TeeReader(file, io.MultiWriter(h, &size)), filename)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения файла: %w", err)
+	}
+
+	fileContent, err := store.ExtractText(filePath)
+	if err != nil {
+		// Логируем ошибку, но продолжаем выполнение
+		log.Printf("ошибка извлечения текста из файла: %v", err)
+	}
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	n := int64(size)
+	return &models.DocumentVersion{
+		FileName:    filepath.Base(filename),
+		FilePath:    filePath,
+		Checksum:    &checksum,
+		Size:        &n,
+		FileContent: fileContent,
+	}, nil
+}
+
+// DiscardFile удаляет файл версии, на который не сослалась ни одна
+// зафиксированная транзакция. Ошибка только логируется: лишний файл
+// в хранилище не мешает работе.
+func DiscardFile(store storage.StorageService, v *models.DocumentVersion) {
+	if v == nil {
+		return
+	}
+	if err := store.DeleteFile(v.FilePath); err != nil {
+		log.Printf("Файл %s не удален после отмены сохранения: %v", v.FilePath, err)
+	}
+}
+
+// checkEditable разрешает менять документ и его файл только в черновике и
+// после отклонения. Документ на согласовании и утвержденный не меняются:
+// утверждающие должны решать по тому, что видят.
+func checkEditable(status string) error {
+	if status != models.StatusDraft && status != models.StatusRejected {
+		return ErrDocumentLocked
+	}
+	return nil
+}
+
+type sizeCounter int64
+
+func (c *sizeCounter) Write(p []byte) (int, error) {
+	*c += sizeCounter(len(p))
+	return len(p), nil
+}
+
+// AddVersion добавляет документу следующую версию файла и делает ее текущей.
+// Вызывается в транзакции, строка документа должна быть заблокирована или
+// создана в этой же транзакции.
+func AddVersion(tx *sql.Tx, documentID int64, v *models.DocumentVersion, actorID int64) error {
+	v.DocumentID = documentID
+	v.CreatedBy = &actorID
+
+	err := tx.QueryRow(`
+        INSERT INTO document_versions (document_id, version, file_path, file_name, checksum,
+                                       size, file_content, comment, created_by)
+        SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7, $8
+        FROM document_versions WHERE document_id = $1
+        RETURNING id, version, created_at
+    `, documentID, v.FilePath, v.FileName, v.Checksum, v.Size, v.FileContent, v.Comment, actorID).
+		Scan(&v.ID, &v.Version, &v.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания версии документа: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        UPDATE documents SET current_version = $1, file_path = $2, file_content = $3
+        WHERE id = $4
+    `, v.Version, v.FilePath, v.FileContent, documentID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления текущей версии документа: %w", err)
+	}
+
+	return nil
+}
+
+// ListVersions возвращает версии файла документа, начиная с последней
+func (s *DocumentService) ListVersions(documentID int64) ([]models.DocumentVersion, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1)`, documentID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения документа: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("документ не найден")
+	}
+
+	rows, err := s.db.Query(`
+        SELECT `+versionColumns+`
+        FROM document_versions v
+        LEFT JOIN users u ON u.id = v.created_by
+        WHERE v.document_id = $1
+        ORDER BY v.version DESC
+    `, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения версий документа: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.DocumentVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+
+	return versions, nil
+}
+
+// GetVersion возвращает версию файла документа по номеру
+func (s *DocumentService) GetVersion(documentID int64, version int) (*models.DocumentVersion, error) {
+	v, err := scanVersion(s.db.QueryRow(`
+        SELECT `+versionColumns+`
+        FROM document_versions v
+        LEFT JOIN users u ON u.id = v.created_by
+        WHERE v.document_id = $1 AND v.version = $2
+    `, documentID, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVersionNotFound
+	}
+	return v, err
+}
+
+// GetVersionFile открывает файл версии документа. Файл нужно закрыть.
+func (s *DocumentService) GetVersionFile(documentID int64, version int) (*models.DocumentVersion, io.ReadCloser, error) {
+	v, err := s.GetVersion(documentID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.storage.GetFile(v.FilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения файла версии: %w", err)
+	}
+
+	return v, file, nil
+}
+
+// versionColumns - поля версии для scanVersion
+const versionColumns = `
+    v.id, v.document_id, v.version, v.file_name, v.checksum, v.size,
+    v.comment, v.created_by, COALESCE(TRIM(u.last_name || ' ' || u.first_name), ''),
+    v.created_at, v.file_path
+`
+
+func scanVersion(row interface{ Scan(...any) error }) (*models.DocumentVersion, error) {
+	var v models.DocumentVersion
+	err := row.Scan(&v.ID, &v.DocumentID, &v.Version, &v.FileName, &v.Checksum, &v.Size,
+		&v.Comment, &v.CreatedBy, &v.CreatedByName, &v.CreatedAt, &v.FilePath)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сканирования версии документа: %w", err)
+	}
+	return &v, nil
+}
@@ -0,0 +1,94 @@
+package document
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"document-approval/models"
+	"document-approval/services/storage"
+)
+
+func TestCheckEditable(t *testing.T) {
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{models.StatusDraft, nil},
+		{models.StatusRejected, nil},
+		{models.StatusInReview, ErrDocumentLocked},
+		{models.StatusApproved, ErrDocumentLocked},
+	}
+	for _, tt := range tests {
+		if err := checkEditable(tt.status); !errors.Is(err, tt.want) {
+			t.Errorf("checkEditable(%s) = %v, ожидалось %v", tt.status, err, tt.want)
+		}
+	}
+}
+
+func TestStoreAndDiscardFile(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewGlusterStorage(dir)
+	content := "Текст приказа"
+
+	v, err := StoreFile(store, strings.NewReader(content), "order.txt")
+	if err != nil {
+		t.Fatalf("StoreFile: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	if *v.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum %s", *v.Checksum)
+	}
+	if *v.Size != int64(len(content)) {
+		t.Errorf("size %d, ожидалось %d", *v.Size, len(content))
+	}
+	if v.FileName != "order.txt" {
+		t.Errorf("file name %q", v.FileName)
+	}
+
+	full := filepath.Join(dir, v.FilePath)
+	if _, err := os.Stat(full); err != nil {
+		t.Fatalf("файл не сохранен: %v", err)
+	}
+
+	DiscardFile(store, v)
+	if _, err := os.Stat(full); !os.IsNotExist(err) {
+		t.Fatalf("файл не удален: %v", err)
+	}
+
+	// Повторное удаление и отсутствие версии не ошибка
+	DiscardFile(store, v)
+	DiscardFile(store, nil)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("обрыв загрузки")
+}
+
+func TestStoreFileRemovesPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewGlusterStorage(dir)
+
+	_, err := StoreFile(store, io.MultiReader(strings.NewReader("начало"), failingReader{}), "order.txt")
+	if err == nil {
+		t.Fatal("ожидалась ошибка сохранения")
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			t.Errorf("остался недописанный файл %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
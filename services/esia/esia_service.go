@@ -1,17 +1,31 @@
 package esia
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
+// StateTTL - время жизни параметра state между редиректом в ЕСИА и возвратом
+const StateTTL = 10 * time.Minute
+
+var ErrInvalidState = errors.New("неверный или просроченный параметр state")
+
 type ESIAService struct {
+	db           *sql.DB
+	httpClient   *http.Client
 	clientID     string
 	clientSecret string
 	redirectURI  string
 	esiaURL      string
+	scope        string
 }
 
 type UserInfo struct {
@@ -21,24 +35,90 @@ type UserInfo struct {
 	Email     string `json:"email"`
 }
 
-func NewESIAService(clientID, clientSecret, redirectURI string) *ESIAService {
+func NewESIAService(db *sql.DB, clientID, clientSecret, redirectURI string) *ESIAService {
 	return &ESIAService{
+		db:           db,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		redirectURI:  redirectURI,
 		esiaURL:      "https://esia.gosuslugi.ru",
+		scope:        "openid fullname email",
 	}
 }
 
-func (s *ESIAService) GetAuthURL() string {
+// SetBaseURL меняет адрес ЕСИА (тестовый контур или локальная заглушка)
+func (s *ESIAService) SetBaseURL(baseURL string) {
+	s.esiaURL = strings.TrimRight(baseURL, "/")
+}
+
+// SetHTTPClient задает HTTP клиент для обращений к ЕСИА
+func (s *ESIAService) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+func (s *ESIAService) GetAuthURL(state string) string {
 	params := url.Values{}
 	params.Add("client_id", s.clientID)
 	params.Add("response_type", "code")
 	params.Add("redirect_uri", s.redirectURI)
+	params.Add("scope", s.scope)
+	params.Add("state", state)
 
 	return fmt.Sprintf("%s/aas/oauth2/authorize?%s", s.esiaURL, params.Encode())
 }
 
+// CreateState генерирует одноразовый state и сохраняет его до возврата из ЕСИА
+func (s *ESIAService) CreateState() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации state: %w", err)
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	_, err := s.db.Exec(`
+        INSERT INTO esia_login_states (state, expires_at)
+        VALUES ($1, $2)
+    `, state, time.Now().Add(StateTTL))
+	if err != nil {
+		return "", fmt.Errorf("ошибка сохранения state: %w", err)
+	}
+
+	return state, nil
+}
+
+// ConsumeState проверяет state и удаляет его, чтобы исключить повторное использование
+func (s *ESIAService) ConsumeState(state string) error {
+	if state == "" {
+		return ErrInvalidState
+	}
+
+	var expiresAt time.Time
+	err := s.db.QueryRow(`
+        DELETE FROM esia_login_states
+        WHERE state = $1
+        RETURNING expires_at
+    `, state).Scan(&expiresAt)
+
+	if err == sql.ErrNoRows {
+		return ErrInvalidState
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки state: %w", err)
+	}
+
+	if time.Now().After(expiresAt) {
+		return ErrInvalidState
+	}
+
+	// Попутно чистим просроченные записи
+	if _, err := s.db.Exec(`DELETE FROM esia_login_states WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return fmt.Errorf("ошибка очистки state: %w", err)
+	}
+
+	return nil
+}
+
 func (s *ESIAService) GetToken(authCode string) (string, error) {
 	params := url.Values{}
 	params.Add("client_id", s.clientID)
@@ -47,7 +127,7 @@ func (s *ESIAService) GetToken(authCode string) (string, error) {
 	params.Add("code", authCode)
 	params.Add("redirect_uri", s.redirectURI)
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/aas/oauth2/te", s.esiaURL), params)
+	resp, err := s.httpClient.PostForm(fmt.Sprintf("%s/aas/oauth2/te", s.esiaURL), params)
 	if err != nil {
 		return "", fmt.Errorf("ошибка запроса токена: %w", err)
 	}
@@ -66,6 +146,10 @@ func (s *ESIAService) GetToken(authCode string) (string, error) {
 		return "", fmt.Errorf("ошибка получения токена: %s", result.Error)
 	}
 
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("ошибка получения токена: статус %d", resp.StatusCode)
+	}
+
 	return result.AccessToken, nil
 }
 
@@ -77,16 +161,24 @@ func (s *ESIAService) GetUserInfo(accessToken string) (*UserInfo, error) {
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса информации о пользователе: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка запроса информации о пользователе: статус %d", resp.StatusCode)
+	}
+
 	var userInfo UserInfo
 	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
 		return nil, fmt.Errorf("ошибка декодирования информации о пользователе: %w", err)
 	}
 
+	if userInfo.ID == "" {
+		return nil, fmt.Errorf("ЕСИА не вернула идентификатор пользователя")
+	}
+
 	return &userInfo, nil
 }
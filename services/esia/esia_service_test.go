@@ -0,0 +1,153 @@
+package esia
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	testClientID     = "pomau"
+	testClientSecret = "secret"
+	testRedirectURI  = "https://pomau.ru/api/auth/esia/callback"
+	testCode         = "auth-code"
+	testAccessToken  = "esia-access-token"
+)
+
+// newFakeESIA поднимает заглушку ЕСИА с обменом кода и выдачей данных пользователя
+func newFakeESIA(t *testing.T, info map[string]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/aas/oauth2/te", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		form := r.PostForm
+		if form.Get("client_id") != testClientID || form.Get("client_secret") != testClientSecret ||
+			form.Get("grant_type") != "authorization_code" || form.Get("redirect_uri") != testRedirectURI {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if form.Get("code") != testCode {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"access_token": testAccessToken, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/rs/prns/v1/info", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestService(baseURL string) *ESIAService {
+	s := NewESIAService(nil, testClientID, testClientSecret, testRedirectURI)
+	s.SetBaseURL(baseURL + "/")
+	return s
+}
+
+func TestGetAuthURL(t *testing.T) {
+	s := newTestService("https://esia-portal1.test.gosuslugi.ru")
+
+	u, err := url.Parse(s.GetAuthURL("state-123"))
+	if err != nil {
+		t.Fatalf("неверный URL: %v", err)
+	}
+	if u.Host != "esia-portal1.test.gosuslugi.ru" || u.Path != "/aas/oauth2/authorize" {
+		t.Fatalf("неверный адрес авторизации: %s", u)
+	}
+
+	want := map[string]string{
+		"client_id":     testClientID,
+		"response_type": "code",
+		"redirect_uri":  testRedirectURI,
+		"state":         "state-123",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, ожидалось %q", k, got, v)
+		}
+	}
+}
+
+func TestLoginFlowWithFakeESIA(t *testing.T) {
+	server := newFakeESIA(t, map[string]string{
+		"id":        "1000299654",
+		"firstName": "Иван",
+		"lastName":  "Иванов",
+		"email":     "ivanov@pomau.ru",
+	})
+	s := newTestService(server.URL)
+
+	token, err := s.GetToken(testCode)
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != testAccessToken {
+		t.Fatalf("токен = %q, ожидался %q", token, testAccessToken)
+	}
+
+	info, err := s.GetUserInfo(token)
+	if err != nil {
+		t.Fatalf("GetUserInfo: %v", err)
+	}
+	if info.ID != "1000299654" || info.FirstName != "Иван" || info.LastName != "Иванов" || info.Email != "ivanov@pomau.ru" {
+		t.Fatalf("неверные данные пользователя: %+v", info)
+	}
+}
+
+func TestGetTokenRejectedCode(t *testing.T) {
+	s := newTestService(newFakeESIA(t, nil).URL)
+
+	_, err := s.GetToken("stolen-code")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("ожидалась ошибка invalid_grant, получено %v", err)
+	}
+}
+
+func TestGetUserInfoErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  map[string]string
+		token string
+	}{
+		{name: "чужой токен", info: map[string]string{"id": "1"}, token: "other"},
+		{name: "нет идентификатора", info: map[string]string{"firstName": "Иван"}, token: testAccessToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(newFakeESIA(t, tt.info).URL)
+			if info, err := s.GetUserInfo(tt.token); err == nil {
+				t.Fatalf("ожидалась ошибка, получено %+v", info)
+			}
+		})
+	}
+}
+
+func TestConsumeStateRejectsEmpty(t *testing.T) {
+	s := newTestService("http://localhost")
+	if err := s.ConsumeState(""); err != ErrInvalidState {
+		t.Fatalf("ожидалась ErrInvalidState, получено %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"document-approval/models"
+)
+
+// Record добавляет событие в историю документа. Вызывается внутри транзакции
+// операции, чтобы событие фиксировалось вместе с изменением.
+func Record(tx *sql.Tx, documentID int64, eventType string, actorID, processID *int64, details map[string]any) error {
+	data, err := marshalDetails(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO document_events (document_id, event_type, actor_id, process_id, details)
+        VALUES ($1, $2, $3, $4, $5)
+    `, documentID, eventType, actorID, processID, data)
+	if err != nil {
+		return fmt.Errorf("ошибка записи истории документа: %w", err)
+	}
+
+	return nil
+}
+
+// RecordForProcess добавляет событие процесса согласования в историю его документа
+func RecordForProcess(tx *sql.Tx, processID int64, eventType string, actorID *int64, details map[string]any) error {
+	data, err := marshalDetails(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO document_events (document_id, event_type, actor_id, process_id, details)
+        SELECT document_id, $2, $3, id, $4 FROM approval_processes WHERE id = $1
+    `, processID, eventType, actorID, data)
+	if err != nil {
+		return fmt.Errorf("ошибка записи истории документа: %w", err)
+	}
+
+	return nil
+}
+
+// List возвращает историю документа в хронологическом порядке
+func List(db *sql.DB, documentID int64) ([]models.DocumentEvent, error) {
+	rows, err := db.Query(`
+        SELECT e.id, e.document_id, e.event_type, e.actor_id,
+               COALESCE(TRIM(u.last_name || ' ' || u.first_name), ''),
+               e.process_id, e.details, e.created_at
+        FROM document_events e
+        LEFT JOIN users u ON u.id = e.actor_id
+        WHERE e.document_id = $1
+        ORDER BY e.created_at, e.id
+    `, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории документа: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.DocumentEvent{}
+	for rows.Next() {
+		var e models.DocumentEvent
+		var details []byte
+
+		err := rows.Scan(
+			&e.ID, &e.DocumentID, &e.EventType, &e.ActorID,
+			&e.ActorName, &e.ProcessID, &details, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования истории документа: %w", err)
+		}
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			return nil, fmt.Errorf("ошибка разбора истории документа: %w", err)
+		}
+		history = append(history, e)
+	}
+
+	return history, nil
+}
+
+func marshalDetails(details map[string]any) ([]byte, error) {
+	if details == nil {
+		details = map[string]any{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации события: %w", err)
+	}
+	return data, nil
+}
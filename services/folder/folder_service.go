@@ -10,7 +10,12 @@ import (
 	"strings"
 
 	"document-approval/models"
+	"document-approval/services/document"
+	"document-approval/services/events"
+	"document-approval/services/notification"
 	"document-approval/services/storage"
+	"document-approval/services/stream"
+	"document-approval/services/webhook"
 )
 
 type FolderService struct {
@@ -42,7 +47,13 @@ func (s *FolderService) CreateFolder(name string, parentID *int64) (*models.Fold
 	// Создаем новый путь
 	path := filepath.Join(parentPath, name)
 
-	err := s.db.QueryRow(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
         INSERT INTO folders (name, parent_id, path)
         VALUES ($1, $2, $3)
         RETURNING id, name, parent_id, path, created_at
@@ -55,6 +66,14 @@ func (s *FolderService) CreateFolder(name string, parentID *int64) (*models.Fold
 		return nil, fmt.Errorf("ошибка создания папки: %w", err)
 	}
 
+	if err := publishFolderChanged(tx, folder.ID, "created"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка создания папки: %w", err)
+	}
+
 	return &folder, nil
 }
 
@@ -181,7 +200,13 @@ func (s *FolderService) getDocumentsForFolder(folderID int64) ([]models.Document
 }
 
 func (s *FolderService) RenameFolder(id int64, newName string) error {
-	_, err := s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
         UPDATE folders
         SET name = $1
         WHERE id = $2
@@ -191,11 +216,21 @@ func (s *FolderService) RenameFolder(id int64, newName string) error {
 		return fmt.Errorf("ошибка переименования папки: %w", err)
 	}
 
-	return nil
+	if err := publishFolderChanged(tx, id, "renamed"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *FolderService) DeleteFolder(id int64) error {
-	_, err := s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
         DELETE FROM folders
         WHERE id = $1
     `, id)
@@ -204,7 +239,19 @@ func (s *FolderService) DeleteFolder(id int64) error {
 		return fmt.Errorf("ошибка удаления папки: %w", err)
 	}
 
-	return nil
+	if err := publishFolderChanged(tx, id, "deleted"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// publishFolderChanged сообщает всем клиентам, что дерево папок изменилось
+func publishFolderChanged(tx *sql.Tx, folderID int64, action string) error {
+	return stream.Publish(tx, nil, models.StreamFolderChanged, map[string]any{
+		"folder_id": folderID,
+		"action":    action,
+	})
 }
 
 func (s *FolderService) GetFolderByID(id int64) (*models.Folder, error) {
@@ -227,28 +274,26 @@ func (s *FolderService) GetFolderByID(id int64) (*models.Folder, error) {
 	return &folder, nil
 }
 
-func (s *FolderService) SaveFile(doc *models.Document, file io.Reader) error {
+func (s *FolderService) SaveFile(doc *models.Document, file io.Reader, actorID int64) (err error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("ошибка начала транзакции: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Сохраняем файл
-	filePath, err := s.storage.SaveFile(file, doc.FilePath)
+	// Сохраняем файл, он станет первой версией документа
+	version, err := document.StoreFile(s.storage, file, doc.FilePath)
 	if err != nil {
-		return fmt.Errorf("ошибка сохранения файла: %w", err)
+		return err
 	}
+	defer func() {
+		if err != nil {
+			document.DiscardFile(s.storage, version)
+		}
+	}()
 
 	// Обновляем путь к файлу после сохранения
-	doc.FilePath = filePath
-
-	// Извлекаем текст из файла
-	fileContent, err := s.storage.ExtractText(filePath)
-	if err != nil {
-		// Логируем ошибку, но продолжаем выполнение
-		log.Printf("ошибка извлечения текста из файла: %v", err)
-	}
+	doc.FilePath = version.FilePath
 
 	// Преобразуем metadata в JSON
 	metadataJSON, err := json.Marshal(doc.Metadata)
@@ -262,31 +307,102 @@ func (s *FolderService) SaveFile(doc *models.Document, file io.Reader) error {
             INSERT INTO documents (
                 title, receipt_date, deadline_date, incoming_number,
                 contact_person, kopuk, museum_name, founder,
-                founder_inn, status, file_path, document_type, metadata,
-                file_content
+                founder_inn, status, file_path, document_type, metadata
             ) VALUES (
-                $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13::jsonb, $14
+                $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13::jsonb
             )
             RETURNING id, created_at
         )
         INSERT INTO folder_documents (folder_id, document_id)
-        SELECT $15, id FROM inserted_doc
+        SELECT $14, id FROM inserted_doc
         RETURNING (SELECT id FROM inserted_doc), (SELECT created_at FROM inserted_doc)
     `,
 		doc.Title, doc.ReceiptDate, doc.DeadlineDate,
 		doc.IncomingNumber, doc.ContactPerson, doc.Kopuk,
 		doc.MuseumName, doc.Founder, doc.FounderINN,
 		doc.Status, doc.FilePath, doc.DocumentType, metadataJSON,
-		fileContent, doc.FolderID,
+		doc.FolderID,
 	).Scan(&doc.ID, &doc.CreatedAt)
 
 	if err != nil {
 		return fmt.Errorf("ошибка сохранения документа: %w", err)
 	}
 
+	if err := document.AddVersion(tx, doc.ID, version, actorID); err != nil {
+		return err
+	}
+	doc.CurrentVersion = &version.Version
+	doc.VersionCount = 1
+
+	err = events.Record(tx, doc.ID, models.EventCreated, &actorID, nil, map[string]any{
+		"title":     doc.Title,
+		"file_path": doc.FilePath,
+		"folder_id": doc.FolderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = webhook.EmitDocument(tx, doc.ID, models.WebhookDocumentCreated, map[string]any{
+		"folder_id": doc.FolderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := publishFolderChanged(tx, doc.FolderID, "file_added"); err != nil {
+		return err
+	}
+
+	if err := notifyFileAdded(tx, doc.FolderID, doc.ID, actorID); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// notifyFileAdded уведомляет о новом документе в папке тех, кто в ней
+// работает: авторов ее документов
+func notifyFileAdded(tx *sql.Tx, folderID, documentID, actorID int64) error {
+	rows, err := tx.Query(`
+        SELECT DISTINCT e.actor_id FROM document_events e
+        JOIN folder_documents fd ON fd.document_id = e.document_id
+        WHERE fd.folder_id = $1 AND e.event_type = $2
+          AND e.actor_id IS NOT NULL AND e.actor_id <> $3
+        ORDER BY e.actor_id
+    `, folderID, models.EventCreated, actorID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения авторов папки: %w", err)
+	}
+
+	var recipients []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования автора папки: %w", err)
+		}
+		recipients = append(recipients, id)
+	}
+	rows.Close()
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	var name string
+	if err := tx.QueryRow(`SELECT name FROM folders WHERE id = $1`, folderID).Scan(&name); err != nil {
+		return fmt.Errorf("ошибка получения папки: %w", err)
+	}
+
+	return notification.CreateEach(tx, recipients, models.Notification{
+		Type:       models.NotificationFolderFileAdded,
+		DocumentID: &documentID,
+		ActorID:    &actorID,
+		Data:       map[string]any{"folder_id": folderID, "folder_name": name},
+	})
+}
+
 func (s *FolderService) GetFile(fileID int64) (*models.Document, io.ReadCloser, error) {
 	var doc models.Document
 	err := s.db.QueryRow(`
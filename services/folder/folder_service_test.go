@@ -0,0 +1,52 @@
+package folder
+
+import (
+	"testing"
+
+	"document-approval/models"
+	"document-approval/pkg/sqltest"
+)
+
+func TestNotifyFileAdded(t *testing.T) {
+	t.Run("авторы папки", func(t *testing.T) {
+		db, mock := sqltest.Open(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("JOIN folder_documents fd").WithArgs(int64(2), models.EventCreated, int64(9)).
+			WillReturnRows([]string{"actor_id"}, []any{int64(5)})
+		mock.ExpectQuery("SELECT name FROM folders WHERE id = $1").WithArgs(int64(2)).
+			WillReturnRows([]string{"name"}, []any{"Договоры"})
+		mock.ExpectQuery("SELECT u.is_active, COALESCE(p.language, 'ru')").WithArgs(int64(5)).
+			WillReturnRows([]string{"is_active", "language"})
+		mock.ExpectRollback()
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		if err := notifyFileAdded(tx, 2, 40, 9); err != nil {
+			t.Fatalf("notifyFileAdded: %v", err)
+		}
+	})
+
+	t.Run("первый документ папки", func(t *testing.T) {
+		db, mock := sqltest.Open(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("JOIN folder_documents fd").WithArgs(int64(2), models.EventCreated, int64(9)).
+			WillReturnRows([]string{"actor_id"})
+		mock.ExpectRollback()
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		if err := notifyFileAdded(tx, 2, 40, 9); err != nil {
+			t.Fatalf("notifyFileAdded: %v", err)
+		}
+	})
+}
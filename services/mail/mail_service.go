@@ -0,0 +1,315 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"document-approval/models"
+)
+
+// Параметры повторной отправки
+const (
+	batchSize   = 50
+	maxAttempts = 8
+	maxBackoff  = 6 * time.Hour
+
+	// claimTimeout - на сколько письмо закрепляется за отправителем
+	claimTimeout = 10 * time.Minute
+)
+
+// Config - настройки SMTP и отправки писем
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	BaseURL  string        // адрес интерфейса для ссылок в письмах
+	Interval time.Duration // как часто разбирать очередь
+}
+
+// MailService отправляет письма из очереди и хранит настройки уведомлений
+type MailService struct {
+	db  *sql.DB
+	cfg Config
+}
+
+func NewMailService(db *sql.DB, cfg Config) *MailService {
+	return &MailService{db: db, cfg: cfg}
+}
+
+// Enabled сообщает, настроен ли SMTP сервер
+func (s *MailService) Enabled() bool {
+	return s.cfg.Host != ""
+}
+
+// RunSender периодически отправляет письма из очереди, пока не отменен контекст
+func (s *MailService) RunSender(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendPending(); err != nil {
+			log.Printf("Ошибка отправки писем: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type outboxMessage struct {
+	id        int64
+	event     string
+	language  string
+	recipient string
+	data      map[string]any
+	attempts  int
+}
+
+// SendPending отправляет очередную порцию писем. Письма сначала закрепляются
+// за отправителем коротким запросом, затем отправляются вне транзакции, и
+// только после этого в очередь записывается результат: медленный SMTP не
+// держит блокировки строк. Неудачная отправка откладывается с растущей
+// паузой, после maxAttempts попыток письмо помечается как неотправленное.
+func (s *MailService) SendPending() error {
+	messages, err := s.claim()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		sendErr := s.send(m)
+		if sendErr != nil {
+			log.Printf("Письмо %d не отправлено (попытка %d): %v", m.id, m.attempts, sendErr)
+		}
+		if err := s.record(m.id, deliveryOutcome(m, sendErr)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// claim закрепляет порцию писем за этим отправителем на claimTimeout и
+// засчитывает попытку. Письма блокируются с SKIP LOCKED, поэтому реплики
+// не берут одно письмо дважды. Если отправитель упал, не записав результат,
+// письмо снова берется в работу по истечении claimTimeout.
+func (s *MailService) claim() ([]outboxMessage, error) {
+	rows, err := s.db.Query(`
+        UPDATE email_outbox o
+        SET status = $1, attempts = o.attempts + 1,
+            next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        FROM (
+            SELECT id FROM email_outbox
+            WHERE status IN ($3, $1) AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY id
+            LIMIT $4
+            FOR UPDATE SKIP LOCKED
+        ) c
+        WHERE o.id = c.id
+        RETURNING o.id, o.event, o.language, o.recipient, o.data, o.attempts
+    `, statusSending, claimTimeout.Seconds(), statusPending, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения очереди писем: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []outboxMessage
+	for rows.Next() {
+		var m outboxMessage
+		var data []byte
+		if err := rows.Scan(&m.id, &m.event, &m.language, &m.recipient, &data, &m.attempts); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования письма: %w", err)
+		}
+		if err := json.Unmarshal(data, &m.data); err != nil {
+			return nil, fmt.Errorf("ошибка разбора письма %d: %w", m.id, err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения очереди писем: %w", err)
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].id < messages[j].id })
+	return messages, nil
+}
+
+// outcome - результат попытки отправки для записи в очередь
+type outcome struct {
+	status  string
+	err     error
+	retryIn time.Duration // пауза до следующей попытки, если письмо осталось в очереди
+}
+
+// deliveryOutcome определяет, что делать с письмом после попытки отправки.
+// m.attempts уже включает эту попытку.
+func deliveryOutcome(m outboxMessage, sendErr error) outcome {
+	switch {
+	case sendErr == nil:
+		return outcome{status: statusSent}
+	case m.attempts >= maxAttempts:
+		return outcome{status: statusFailed, err: sendErr}
+	default:
+		return outcome{status: statusPending, err: sendErr, retryIn: backoff(m.attempts)}
+	}
+}
+
+// record записывает результат отправки закрепленного письма
+func (s *MailService) record(id int64, o outcome) error {
+	var err error
+	if o.err == nil {
+		_, err = s.db.Exec(`
+            UPDATE email_outbox
+            SET status = $1, last_error = NULL, sent_at = CURRENT_TIMESTAMP
+            WHERE id = $2 AND status = $3
+        `, o.status, id, statusSending)
+	} else {
+		_, err = s.db.Exec(`
+            UPDATE email_outbox
+            SET status = $1, last_error = $2,
+                next_attempt_at = CURRENT_TIMESTAMP + $3 * INTERVAL '1 second'
+            WHERE id = $4 AND status = $5
+        `, o.status, o.err.Error(), o.retryIn.Seconds(), id, statusSending)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления очереди писем: %w", err)
+	}
+	return nil
+}
+
+// backoff - пауза перед следующей попыткой: 1, 2, 4... минут, не больше maxBackoff
+func backoff(attempts int) time.Duration {
+	d := time.Minute << (attempts - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func (s *MailService) send(m outboxMessage) error {
+	m.data["link"] = s.link(m.event, m.data)
+
+	subject, body, err := render(m.event, m.language, m.data)
+	if err != nil {
+		return err
+	}
+
+	from, err := netmail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("неверный адрес отправителя %q: %w", s.cfg.From, err)
+	}
+
+	msg, err := compose(m.id, from, m.recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{m.recipient}, msg)
+}
+
+// link - ссылка на страницу интерфейса, где пользователь продолжит работу
+func (s *MailService) link(event string, data map[string]any) string {
+	base := strings.TrimRight(s.cfg.BaseURL, "/")
+	switch event {
+	case models.NotifyAssigned, models.NotifyReminder:
+		return base + "/approvals"
+	default:
+		return fmt.Sprintf("%s/documents/%v", base, data["document_id"])
+	}
+}
+
+// compose собирает письмо в формате RFC 5322 с текстом в quoted-printable
+func compose(id int64, from *netmail.Address, to, subject, body string) ([]byte, error) {
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <outbox-%d@%s>\r\n", id, domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("ошибка формирования письма: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, fmt.Errorf("ошибка формирования письма: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// GetPreferences возвращает настройки уведомлений пользователя,
+// а если он их не менял - значения по умолчанию
+func (s *MailService) GetPreferences(userID int64) (*models.NotificationPreferences, error) {
+	p := &models.NotificationPreferences{
+		Language:     models.LangRU,
+		EmailEnabled: true,
+		Assigned:     true,
+		Reminder:     true,
+		Completed:    true,
+		Rejected:     true,
+	}
+
+	err := s.db.QueryRow(`
+        SELECT language, email_enabled, assigned, reminder, completed, rejected, updated_at
+        FROM notification_preferences WHERE user_id = $1
+    `, userID).Scan(&p.Language, &p.EmailEnabled, &p.Assigned, &p.Reminder, &p.Completed, &p.Rejected, &p.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("ошибка получения настроек уведомлений: %w", err)
+	}
+
+	return p, nil
+}
+
+// UpdatePreferences сохраняет настройки уведомлений пользователя
+func (s *MailService) UpdatePreferences(userID int64, p *models.NotificationPreferences) error {
+	if p.Language != models.LangRU && p.Language != models.LangEN {
+		return fmt.Errorf("язык уведомлений должен быть %s или %s", models.LangRU, models.LangEN)
+	}
+
+	err := s.db.QueryRow(`
+        INSERT INTO notification_preferences (user_id, language, email_enabled, assigned, reminder, completed, rejected)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (user_id) DO UPDATE SET
+            language = EXCLUDED.language,
+            email_enabled = EXCLUDED.email_enabled,
+            assigned = EXCLUDED.assigned,
+            reminder = EXCLUDED.reminder,
+            completed = EXCLUDED.completed,
+            rejected = EXCLUDED.rejected,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING updated_at
+    `, userID, p.Language, p.EmailEnabled, p.Assigned, p.Reminder, p.Completed, p.Rejected).Scan(&p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настроек уведомлений: %w", err)
+	}
+
+	return nil
+}
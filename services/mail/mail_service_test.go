@@ -0,0 +1,353 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"document-approval/models"
+)
+
+// smtpServer - минимальный SMTP сервер, который сохраняет принятые письма.
+// Получателей из reject он отклоняет с временной ошибкой 451.
+type smtpServer struct {
+	ln     net.Listener
+	reject map[string]bool
+
+	mu       sync.Mutex
+	messages []*netmail.Message
+	rcpt     []string
+}
+
+func startSMTP(t *testing.T, reject ...string) *smtpServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	s := &smtpServer{ln: ln, reject: map[string]bool{}}
+	for _, r := range reject {
+		s.reject[r] = true
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 test")
+		case "MAIL", "RSET", "NOOP":
+			reply("250 OK")
+		case "RCPT":
+			addr := strings.Trim(cmd[strings.Index(cmd, ":")+1:], "<> ")
+			if s.reject[addr] {
+				reply("451 mailbox temporarily unavailable")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, addr)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := io.ReadAll(&dotReader{r: r})
+			if err != nil {
+				return
+			}
+			msg, err := netmail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				reply("554 malformed message")
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) received() []*netmail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*netmail.Message(nil), s.messages...)
+}
+
+func (s *smtpServer) recipients() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.rcpt...)
+}
+
+// dotReader читает тело DATA до строки из одной точки и снимает удвоение точек
+type dotReader struct {
+	r    *bufio.Reader
+	buf  []byte
+	done bool
+}
+
+func (d *dotReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		line, err := d.r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if line == ".\r\n" {
+			d.done = true
+			continue
+		}
+		d.buf = []byte(strings.TrimPrefix(line, "."))
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func testService(srv *smtpServer) *MailService {
+	return NewMailService(nil, Config{
+		Host:    "127.0.0.1",
+		Port:    srv.port(),
+		From:    "Согласование <noreply@pomau.ru>",
+		BaseURL: "https://docs.pomau.ru/",
+	})
+}
+
+func decodeMessage(t *testing.T, msg *netmail.Message) (subject, body string) {
+	t.Helper()
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("тема письма: %v", err)
+	}
+	raw, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("текст письма: %v", err)
+	}
+	return subject, strings.ReplaceAll(string(raw), "\r\n", "\n")
+}
+
+func TestSendTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        outboxMessage
+		subject  string
+		contains []string
+	}{
+		{
+			name: "назначение на русском",
+			m: outboxMessage{id: 1, event: models.NotifyAssigned, language: models.LangRU, recipient: "ivanov@pomau.ru",
+				data: map[string]any{"document_title": "Приказ № 5", "due_at": "20.10.2026 18:00"}},
+			subject:  "Документ на согласование: Приказ № 5",
+			contains: []string{"Вам назначено согласование документа «Приказ № 5».", "Срок решения: 20.10.2026 18:00.", "https://docs.pomau.ru/approvals"},
+		},
+		{
+			name: "назначение на английском",
+			m: outboxMessage{id: 2, event: models.NotifyAssigned, language: models.LangEN, recipient: "smith@pomau.ru",
+				data: map[string]any{"document_title": "Order 5"}},
+			subject:  "Document awaiting your approval: Order 5",
+			contains: []string{`You have been asked to approve the document "Order 5".`, "https://docs.pomau.ru/approvals"},
+		},
+		{
+			name: "отклонение на русском",
+			m: outboxMessage{id: 3, event: models.NotifyRejected, language: models.LangRU, recipient: "ivanov@pomau.ru",
+				data: map[string]any{"document_title": "Приказ № 5", "document_id": 42, "comment": "Нет подписи"}},
+			subject:  "Документ отклонен: Приказ № 5",
+			contains: []string{"Комментарий: Нет подписи", "https://docs.pomau.ru/documents/42"},
+		},
+		{
+			name: "утверждение на английском",
+			m: outboxMessage{id: 4, event: models.NotifyCompleted, language: models.LangEN, recipient: "smith@pomau.ru",
+				data: map[string]any{"document_title": "Order 5", "document_id": 42}},
+			subject:  "Document approved: Order 5",
+			contains: []string{"The document has been approved.", "https://docs.pomau.ru/documents/42"},
+		},
+		{
+			name: "неизвестный язык - русский шаблон",
+			m: outboxMessage{id: 5, event: models.NotifyReminder, language: "de", recipient: "ivanov@pomau.ru",
+				data: map[string]any{"document_title": "Приказ № 5", "due_at": "20.10.2026 18:00"}},
+			subject:  "Напоминание: срок согласования «Приказ № 5»",
+			contains: []string{"нужно принять до 20.10.2026 18:00."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := startSMTP(t)
+			if err := testService(srv).send(tt.m); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			got := srv.received()
+			if len(got) != 1 {
+				t.Fatalf("принято писем: %d, ожидалось 1", len(got))
+			}
+			if rcpt := srv.recipients(); len(rcpt) != 1 || rcpt[0] != tt.m.recipient {
+				t.Errorf("получатели %q, ожидался %q", rcpt, tt.m.recipient)
+			}
+			if to := got[0].Header.Get("To"); to != tt.m.recipient {
+				t.Errorf("To: %q, ожидался %q", to, tt.m.recipient)
+			}
+			if id := got[0].Header.Get("Message-ID"); !strings.HasPrefix(id, "<outbox-") || !strings.HasSuffix(id, "@pomau.ru>") {
+				t.Errorf("Message-ID: %q", id)
+			}
+
+			subject, body := decodeMessage(t, got[0])
+			if subject != tt.subject {
+				t.Errorf("тема %q, ожидалась %q", subject, tt.subject)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("в тексте нет %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestSendRetryOnFailure(t *testing.T) {
+	srv := startSMTP(t, "busy@pomau.ru")
+	s := testService(srv)
+
+	messages := []outboxMessage{
+		{id: 1, event: models.NotifyAssigned, language: models.LangRU, recipient: "ivanov@pomau.ru", attempts: 1,
+			data: map[string]any{"document_title": "Приказ"}},
+		{id: 2, event: models.NotifyAssigned, language: models.LangRU, recipient: "busy@pomau.ru", attempts: 1,
+			data: map[string]any{"document_title": "Приказ"}},
+		{id: 3, event: models.NotifyAssigned, language: models.LangRU, recipient: "busy@pomau.ru", attempts: maxAttempts,
+			data: map[string]any{"document_title": "Приказ"}},
+	}
+
+	var outcomes []outcome
+	for _, m := range messages {
+		outcomes = append(outcomes, deliveryOutcome(m, s.send(m)))
+	}
+
+	if got := srv.received(); len(got) != 1 {
+		t.Fatalf("принято писем: %d, ожидалось 1", len(got))
+	}
+	if o := outcomes[0]; o.status != statusSent || o.err != nil {
+		t.Errorf("отправленное письмо: %+v", o)
+	}
+	if o := outcomes[1]; o.status != statusPending || o.err == nil || o.retryIn != time.Minute {
+		t.Errorf("письмо после первой неудачи должно остаться в очереди на минуту: %+v", o)
+	} else if !strings.Contains(o.err.Error(), "451") {
+		t.Errorf("ошибка не содержит ответ сервера: %v", o.err)
+	}
+	if o := outcomes[2]; o.status != statusFailed || o.err == nil {
+		t.Errorf("письмо после maxAttempts попыток должно быть неотправленным: %+v", o)
+	}
+}
+
+func TestSendServerUnavailable(t *testing.T) {
+	srv := startSMTP(t)
+	s := testService(srv)
+	srv.ln.Close()
+
+	m := outboxMessage{id: 1, event: models.NotifyAssigned, language: models.LangRU, recipient: "ivanov@pomau.ru", attempts: 3,
+		data: map[string]any{"document_title": "Приказ"}}
+	err := s.send(m)
+	if err == nil {
+		t.Fatal("отправка на закрытый порт должна завершиться ошибкой")
+	}
+	if o := deliveryOutcome(m, err); o.status != statusPending || o.retryIn != 4*time.Minute {
+		t.Errorf("результат: %+v", o)
+	}
+}
+
+func TestSendUnknownEvent(t *testing.T) {
+	srv := startSMTP(t)
+	m := outboxMessage{id: 1, event: "unknown", language: models.LangRU, recipient: "ivanov@pomau.ru", data: map[string]any{}}
+	if err := testService(srv).send(m); err == nil {
+		t.Fatal("ожидалась ошибка для события без шаблона")
+	}
+	if len(srv.received()) != 0 {
+		t.Error("письмо без шаблона не должно отправляться")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, ожидалось %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryOutcome(t *testing.T) {
+	failure := errors.New("451 busy")
+	for attempts := 1; attempts <= maxAttempts; attempts++ {
+		o := deliveryOutcome(outboxMessage{attempts: attempts}, failure)
+		want := statusPending
+		if attempts == maxAttempts {
+			want = statusFailed
+		}
+		if o.status != want {
+			t.Errorf("попытка %d: статус %s, ожидался %s", attempts, o.status, want)
+		}
+		if o.err != failure {
+			t.Errorf("попытка %d: ошибка %v", attempts, o.err)
+		}
+	}
+	if o := deliveryOutcome(outboxMessage{attempts: maxAttempts}, nil); o.status != statusSent {
+		t.Errorf("успешная последняя попытка: статус %s", o.status)
+	}
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"document-approval/models"
+)
+
+// Статусы писем в очереди
+const (
+	statusPending = "pending"
+	statusSending = "sending" // закреплено за отправителем, результат еще не записан
+	statusSent    = "sent"
+	statusFailed  = "failed"
+)
+
+// eventColumns - столбцы notification_preferences, которыми пользователь
+// включает и выключает письма о событии
+var eventColumns = map[string]string{
+	models.NotifyAssigned:  "assigned",
+	models.NotifyReminder:  "reminder",
+	models.NotifyCompleted: "completed",
+	models.NotifyRejected:  "rejected",
+}
+
+// Enqueue ставит в очередь письмо пользователю о событии процесса согласования.
+// Вызывается внутри транзакции события: письмо уходит, только если событие
+// зафиксировано, а отправляет его RunSender. Пользователь без email, отключенный
+// или отказавшийся от таких писем пропускается.
+func Enqueue(tx *sql.Tx, userID, processID int64, event string, data map[string]any) error {
+	column, ok := eventColumns[event]
+	if !ok {
+		return fmt.Errorf("неизвестное событие уведомления: %s", event)
+	}
+
+	var email, language string
+	var enabled bool
+	err := tx.QueryRow(`
+        SELECT COALESCE(u.email, ''), COALESCE(p.language, 'ru'),
+               u.is_active AND COALESCE(p.email_enabled AND p.`+column+`, TRUE)
+        FROM users u
+        LEFT JOIN notification_preferences p ON p.user_id = u.id
+        WHERE u.id = $1
+    `, userID).Scan(&email, &language, &enabled)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения настроек уведомлений: %w", err)
+	}
+	if !enabled || email == "" {
+		return nil
+	}
+
+	payload := map[string]any{"process_id": processID}
+	for k, v := range data {
+		payload[k] = v
+	}
+
+	var documentID int64
+	var title string
+	err = tx.QueryRow(`
+        SELECT d.id, d.title FROM approval_processes ap
+        JOIN documents d ON d.id = ap.document_id
+        WHERE ap.id = $1
+    `, processID).Scan(&documentID, &title)
+	if err != nil {
+		return fmt.Errorf("ошибка получения документа для уведомления: %w", err)
+	}
+	payload["document_id"] = documentID
+	payload["document_title"] = title
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации уведомления: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO email_outbox (user_id, event, language, recipient, data)
+        VALUES ($1, $2, $3, $4, $5)
+    `, userID, event, language, email, raw)
+	if err != nil {
+		return fmt.Errorf("ошибка постановки письма в очередь: %w", err)
+	}
+
+	return nil
+}
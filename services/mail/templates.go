@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"document-approval/models"
+)
+
+type mailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// templates - шаблоны писем по событию и языку. В шаблон передаются данные
+// письма из очереди и ссылка link на страницу в интерфейсе.
+var templates = map[string]map[string]mailTemplate{
+	models.NotifyAssigned: {
+		models.LangRU: parse(
+			`Документ на согласование: {{.document_title}}`,
+			`Здравствуйте!
+
+Вам назначено согласование документа «{{.document_title}}».
+{{- if .due_at}}
+Срок решения: {{.due_at}}.
+{{- end}}
+
+Открыть согласования: {{.link}}
+`),
+		models.LangEN: parse(
+			`Document awaiting your approval: {{.document_title}}`,
+			`Hello,
+
+You have been asked to approve the document "{{.document_title}}".
+{{- if .due_at}}
+Decision due: {{.due_at}}.
+{{- end}}
+
+Open approvals: {{.link}}
+`),
+	},
+	models.NotifyReminder: {
+		models.LangRU: parse(
+			`Напоминание: срок согласования «{{.document_title}}»`,
+			`Здравствуйте!
+
+Напоминаем, что решение по документу «{{.document_title}}» нужно принять до {{.due_at}}.
+После срока решение будет передано руководителю.
+
+Открыть согласования: {{.link}}
+`),
+		models.LangEN: parse(
+			`Reminder: approval of "{{.document_title}}" is due`,
+			`Hello,
+
+This is a reminder that your decision on the document "{{.document_title}}" is due by {{.due_at}}.
+After the deadline the decision will be escalated to your manager.
+
+Open approvals: {{.link}}
+`),
+	},
+	models.NotifyCompleted: {
+		models.LangRU: parse(
+			`Документ утвержден: {{.document_title}}`,
+			`Здравствуйте!
+
+Согласование документа «{{.document_title}}» завершено, документ утвержден.
+
+Открыть документ: {{.link}}
+`),
+		models.LangEN: parse(
+			`Document approved: {{.document_title}}`,
+			`Hello,
+
+The approval of the document "{{.document_title}}" is complete. The document has been approved.
+
+Open document: {{.link}}
+`),
+	},
+	models.NotifyRejected: {
+		models.LangRU: parse(
+			`Документ отклонен: {{.document_title}}`,
+			`Здравствуйте!
+
+Документ «{{.document_title}}» отклонен при согласовании.
+{{- if .comment}}
+Комментарий: {{.comment}}
+{{- end}}
+
+Открыть документ: {{.link}}
+`),
+		models.LangEN: parse(
+			`Document rejected: {{.document_title}}`,
+			`Hello,
+
+The document "{{.document_title}}" has been rejected.
+{{- if .comment}}
+Comment: {{.comment}}
+{{- end}}
+
+Open document: {{.link}}
+`),
+	},
+}
+
+func parse(subject, body string) mailTemplate {
+	return mailTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// render возвращает тему и текст письма. Для неизвестного языка берется русский.
+func render(event, language string, data map[string]any) (string, string, error) {
+	byLang, ok := templates[event]
+	if !ok {
+		return "", "", fmt.Errorf("нет шаблона письма для события %s", event)
+	}
+	t, ok := byLang[language]
+	if !ok {
+		t = byLang[models.LangRU]
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("ошибка формирования темы письма: %w", err)
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("ошибка формирования текста письма: %w", err)
+	}
+	return subject.String(), body.String(), nil
+}
@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"document-approval/models"
+)
+
+var ErrNotFound = errors.New("уведомление не найдено")
+
+// NotificationService - входящие уведомления пользователя в приложении.
+// Уведомления создают сервисы согласования и документов через Create.
+type NotificationService struct {
+	db *sql.DB
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{
+		db: db,
+	}
+}
+
+// List возвращает уведомления пользователя, начиная с новых.
+// unreadOnly оставляет только непрочитанные.
+func (s *NotificationService) List(userID int64, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, type, title, document_id, process_id, actor_id, data, read_at, created_at
+        FROM notifications
+        WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $3 OFFSET $4
+    `, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения уведомлений: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var data []byte
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.DocumentID, &n.ProcessID,
+			&n.ActorID, &data, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования уведомления: %w", err)
+		}
+		if err := json.Unmarshal(data, &n.Data); err != nil {
+			return nil, fmt.Errorf("ошибка разбора уведомления: %w", err)
+		}
+		list = append(list, n)
+	}
+
+	return list, nil
+}
+
+// UnreadCount возвращает число непрочитанных уведомлений пользователя
+func (s *NotificationService) UnreadCount(userID int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+    `, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета непрочитанных уведомлений: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead отмечает уведомление пользователя прочитанным. Повторная отметка
+// не меняет время прочтения.
+func (s *NotificationService) MarkRead(userID, id int64) error {
+	result, err := s.db.Exec(`
+        UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+        WHERE id = $1 AND user_id = $2
+    `, id, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка отметки уведомления: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead отмечает прочитанными все уведомления пользователя и
+// возвращает, сколько их было непрочитанных
+func (s *NotificationService) MarkAllRead(userID int64) (int64, error) {
+	result, err := s.db.Exec(`
+        UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND read_at IS NULL
+    `, userID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отметки уведомлений: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
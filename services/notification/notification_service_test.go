@@ -0,0 +1,202 @@
+package notification
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"document-approval/models"
+	"document-approval/pkg/sqltest"
+)
+
+// inTx выполняет fn в транзакции тестовой базы и фиксирует ее
+func inTx(t *testing.T, db *sql.DB, mock *sqltest.Mock, fn func(tx *sql.Tx) error) error {
+	t.Helper()
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func expectRecipient(mock *sqltest.Mock, userID int64, active bool, language string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.is_active, COALESCE(p.language, 'ru')").WithArgs(userID).
+		WillReturnRows([]string{"is_active", "language"}, []any{active, language})
+}
+
+// expectStored ждет запись уведомления с текстом title и его публикацию в поток
+func expectStored(mock *sqltest.Mock, userID int64, kind, title string, unread int) {
+	mock.ExpectQuery("INSERT INTO notifications").
+		WithArgs(userID, kind, title, sqltest.Any, sqltest.Any, sqltest.Any, sqltest.Any).
+		WillReturnRows([]string{"id", "created_at"}, []any{int64(100), time.Now()})
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL").WithArgs(userID).
+		WillReturnRows([]string{"count"}, []any{unread})
+	mock.ExpectQuery("INSERT INTO stream_events").WithArgs(userID, models.StreamNotification, sqltest.Any).
+		WillReturnRows([]string{"id"}, []any{int64(500)})
+	mock.ExpectExec("SELECT pg_notify($1, $2)").WithArgs(sqltest.Any, "500")
+}
+
+func TestCreate(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	documentID, actorID := int64(3), int64(9)
+
+	expectRecipient(mock, 7, true, models.LangRU)
+	mock.ExpectQuery("SELECT title FROM documents WHERE id = $1").WithArgs(documentID).
+		WillReturnRows([]string{"title"}, []any{"Отчет за 2 квартал"})
+	mock.ExpectQuery("FROM users WHERE id = $1").WithArgs(actorID).
+		WillReturnRows([]string{"name"}, []any{"Петров Петр"})
+	expectStored(mock, 7, models.NotificationDocumentChanged,
+		"Петров Петр загрузил(а) версию 3 документа «Отчет за 2 квартал»", 4)
+
+	err := inTx(t, db, mock, func(tx *sql.Tx) error {
+		return Create(tx, models.Notification{
+			UserID:     7,
+			Type:       models.NotificationDocumentChanged,
+			DocumentID: &documentID,
+			ActorID:    &actorID,
+			Data:       map[string]any{"version": 3},
+		})
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+func TestCreateRecipientPreferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(mock *sqltest.Mock)
+	}{
+		{
+			name: "язык получателя",
+			expect: func(mock *sqltest.Mock) {
+				expectRecipient(mock, 7, true, models.LangEN)
+				expectStored(mock, 7, models.NotificationReminder, "Reminder: your decision on \"Отчет\" is due by 01.07.2026 18:00", 1)
+			},
+		},
+		{
+			name: "неизвестный язык - русский текст",
+			expect: func(mock *sqltest.Mock) {
+				expectRecipient(mock, 7, true, "de")
+				expectStored(mock, 7, models.NotificationReminder, "Напоминание: решение по документу «Отчет» нужно принять до 01.07.2026 18:00", 1)
+			},
+		},
+		{
+			name: "отключенный пользователь",
+			expect: func(mock *sqltest.Mock) {
+				expectRecipient(mock, 7, false, models.LangRU)
+			},
+		},
+		{
+			name: "пользователь удален",
+			expect: func(mock *sqltest.Mock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT u.is_active, COALESCE(p.language, 'ru')").WithArgs(int64(7)).
+					WillReturnRows([]string{"is_active", "language"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := sqltest.Open(t)
+			tt.expect(mock)
+
+			err := inTx(t, db, mock, func(tx *sql.Tx) error {
+				return Create(tx, models.Notification{
+					UserID: 7,
+					Type:   models.NotificationReminder,
+					Data:   map[string]any{"document_title": "Отчет", "due_at": "01.07.2026 18:00"},
+				})
+			})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		})
+	}
+}
+
+func TestMarkReadOwnership(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewNotificationService(db)
+
+	// Уведомление 10 принадлежит пользователю 2: отметка от имени пользователя 1
+	// ничего не меняет и выглядит как отсутствующее уведомление
+	mock.ExpectExec("WHERE id = $1 AND user_id = $2").WithArgs(int64(10), int64(1)).WillReturnResult(0)
+	mock.ExpectExec("WHERE id = $1 AND user_id = $2").WithArgs(int64(10), int64(2)).WillReturnResult(1)
+
+	if err := s.MarkRead(1, 10); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("чужое уведомление: ошибка %v, ожидалась %v", err, ErrNotFound)
+	}
+	if err := s.MarkRead(2, 10); err != nil {
+		t.Fatalf("свое уведомление: %v", err)
+	}
+}
+
+func TestMarkAllRead(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewNotificationService(db)
+
+	mock.ExpectExec("WHERE user_id = $1 AND read_at IS NULL").WithArgs(int64(1)).WillReturnResult(4)
+
+	n, err := s.MarkAllRead(1)
+	if err != nil {
+		t.Fatalf("MarkAllRead: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("отмечено %d, ожидалось 4", n)
+	}
+}
+
+func TestUnreadCount(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewNotificationService(db)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL").WithArgs(int64(1)).
+		WillReturnRows([]string{"count"}, []any{5})
+
+	n, err := s.UnreadCount(1)
+	if err != nil {
+		t.Fatalf("UnreadCount: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("непрочитанных %d, ожидалось 5", n)
+	}
+}
+
+func TestRenderAllTypes(t *testing.T) {
+	data := map[string]any{
+		"document_title": "Отчет",
+		"actor_name":     "Петров Петр",
+		"due_at":         "01.07.2026 18:00",
+		"decision":       "approved",
+		"result":         "approved",
+		"version":        2,
+		"folder_name":    "Договоры",
+	}
+
+	for kind, byLang := range titles {
+		for lang := range byLang {
+			title, err := render(kind, lang, data)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", kind, lang, err)
+			}
+			if title == "" || strings.Contains(title, "<no value>") {
+				t.Errorf("%s/%s: %q", kind, lang, title)
+			}
+		}
+	}
+
+	if _, err := render("unknown", models.LangRU, data); err == nil {
+		t.Error("неизвестный тип должен отклоняться")
+	}
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"document-approval/models"
+	"document-approval/services/stream"
+
+	"github.com/lib/pq"
+)
+
+// mentionPattern - упоминание в комментарии: @ и email пользователя,
+// например @ivanov@pomau.ru
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Create записывает уведомление пользователю и публикует его в поток
+// обновлений. Вызывается внутри транзакции события. Текст формируется на
+// языке получателя; отключенным пользователям уведомления не создаются.
+func Create(tx *sql.Tx, n models.Notification) error {
+	var active bool
+	var language string
+	err := tx.QueryRow(`
+        SELECT u.is_active, COALESCE(p.language, 'ru')
+        FROM users u
+        LEFT JOIN notification_preferences p ON p.user_id = u.id
+        WHERE u.id = $1
+    `, n.UserID).Scan(&active, &language)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения получателя уведомления: %w", err)
+	}
+	if !active {
+		return nil
+	}
+
+	data := map[string]any{}
+	for k, v := range n.Data {
+		data[k] = v
+	}
+	if n.DocumentID != nil {
+		var title string
+		err := tx.QueryRow(`SELECT title FROM documents WHERE id = $1`, *n.DocumentID).Scan(&title)
+		if err != nil {
+			return fmt.Errorf("ошибка получения документа для уведомления: %w", err)
+		}
+		data["document_title"] = title
+	}
+	if n.ActorID != nil {
+		var name string
+		err := tx.QueryRow(`
+            SELECT COALESCE(NULLIF(TRIM(last_name || ' ' || first_name), ''), email)
+            FROM users WHERE id = $1
+        `, *n.ActorID).Scan(&name)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("ошибка получения автора уведомления: %w", err)
+		}
+		data["actor_name"] = name
+	}
+
+	n.Title, err = render(n.Type, language, data)
+	if err != nil {
+		return err
+	}
+	n.Data = data
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации уведомления: %w", err)
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO notifications (user_id, type, title, document_id, process_id, actor_id, data)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `, n.UserID, n.Type, n.Title, n.DocumentID, n.ProcessID, n.ActorID, raw).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания уведомления: %w", err)
+	}
+
+	var unread int
+	err = tx.QueryRow(`
+        SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+    `, n.UserID).Scan(&unread)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчета непрочитанных уведомлений: %w", err)
+	}
+
+	return stream.Publish(tx, &n.UserID, models.StreamNotification, map[string]any{
+		"notification": n,
+		"unread":       unread,
+	})
+}
+
+// CreateEach создает одинаковое уведомление каждому из пользователей
+func CreateEach(tx *sql.Tx, userIDs []int64, n models.Notification) error {
+	for _, id := range userIDs {
+		n.UserID = id
+		if err := Create(tx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mentioned возвращает активных пользователей, упомянутых в тексте
+func Mentioned(tx *sql.Tx, text string) ([]int64, error) {
+	var emails []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		emails = append(emails, strings.ToLower(m[1]))
+	}
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT id FROM users WHERE is_active AND LOWER(email) = ANY($1) ORDER BY id
+    `, pq.Array(emails))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска упомянутых пользователей: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования пользователя: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"document-approval/models"
+)
+
+// titles - тексты уведомлений по типу и языку. В шаблон передаются данные
+// уведомления, название документа document_title и имя автора actor_name.
+var titles = map[string]map[string]*template.Template{
+	models.NotificationAssigned: {
+		models.LangRU: parse(`Вам назначено согласование документа «{{.document_title}}»{{if .due_at}}, срок решения {{.due_at}}{{end}}`),
+		models.LangEN: parse(`You have been asked to approve "{{.document_title}}"{{if .due_at}}, decision due {{.due_at}}{{end}}`),
+	},
+	models.NotificationReminder: {
+		models.LangRU: parse(`Напоминание: решение по документу «{{.document_title}}» нужно принять до {{.due_at}}`),
+		models.LangEN: parse(`Reminder: your decision on "{{.document_title}}" is due by {{.due_at}}`),
+	},
+	models.NotificationDecided: {
+		models.LangRU: parse(`{{.actor_name}} {{if eq .decision "approved"}}согласовал(а){{else}}отклонил(а){{end}} документ «{{.document_title}}»`),
+		models.LangEN: parse(`{{.actor_name}} {{if eq .decision "approved"}}approved{{else}}rejected{{end}} "{{.document_title}}"`),
+	},
+	models.NotificationCompleted: {
+		models.LangRU: parse(`Согласование документа «{{.document_title}}» завершено: {{if eq .result "approved"}}утвержден{{else}}отклонен{{end}}`),
+		models.LangEN: parse(`Approval of "{{.document_title}}" finished: {{if eq .result "approved"}}approved{{else}}rejected{{end}}`),
+	},
+	models.NotificationCanceled: {
+		models.LangRU: parse(`{{.actor_name}} отменил(а) согласование документа «{{.document_title}}»`),
+		models.LangEN: parse(`{{.actor_name}} canceled the approval of "{{.document_title}}"`),
+	},
+	models.NotificationMentioned: {
+		models.LangRU: parse(`{{.actor_name}} упомянул(а) вас в комментарии к документу «{{.document_title}}»`),
+		models.LangEN: parse(`{{.actor_name}} mentioned you in a comment on "{{.document_title}}"`),
+	},
+	models.NotificationDocumentChanged: {
+		models.LangRU: parse(`{{.actor_name}} {{if .version}}загрузил(а) версию {{.version}} документа{{else}}изменил(а) документ{{end}} «{{.document_title}}»`),
+		models.LangEN: parse(`{{.actor_name}} {{if .version}}uploaded version {{.version}} of{{else}}edited{{end}} "{{.document_title}}"`),
+	},
+	models.NotificationFolderFileAdded: {
+		models.LangRU: parse(`{{.actor_name}} добавил(а) документ «{{.document_title}}» в папку «{{.folder_name}}»`),
+		models.LangEN: parse(`{{.actor_name}} added "{{.document_title}}" to the folder "{{.folder_name}}"`),
+	},
+}
+
+func parse(text string) *template.Template {
+	return template.Must(template.New("").Parse(text))
+}
+
+// render формирует текст уведомления. Для языка без текста используется русский.
+func render(kind, lang string, data map[string]any) (string, error) {
+	byLang, ok := titles[kind]
+	if !ok {
+		return "", fmt.Errorf("неизвестный тип уведомления: %s", kind)
+	}
+	tpl, ok := byLang[lang]
+	if !ok {
+		tpl = byLang[models.LangRU]
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("ошибка формирования уведомления: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,307 @@
+package sheet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"document-approval/config"
+	"document-approval/models"
+	"document-approval/pkg/pdfgen"
+	"document-approval/services/approval"
+	"document-approval/services/document"
+	"document-approval/services/storage"
+)
+
+var (
+	ErrNotCompleted = errors.New("лист согласования формируется только по завершенному согласованию")
+	ErrNotPDF       = errors.New("исходный файл документа не является PDF")
+)
+
+// SheetService формирует лист согласования завершенного процесса
+type SheetService struct {
+	approvalService *approval.ApprovalService
+	documentService *document.DocumentService
+	storage         storage.StorageService
+
+	fontPath string
+	fontOnce sync.Once
+	font     *pdfgen.Font
+	fontErr  error
+}
+
+// NewSheetService создает сервис листов согласования. Шрифт с кириллицей
+// загружается из fontPath при первом формировании листа.
+func NewSheetService(approvalService *approval.ApprovalService, documentService *document.DocumentService, storage storage.StorageService, fontPath string) *SheetService {
+	return &SheetService{
+		approvalService: approvalService,
+		documentService: documentService,
+		storage:         storage,
+		fontPath:        fontPath,
+	}
+}
+
+// Render возвращает PDF листа согласования и имя файла для скачивания.
+// С withOriginal лист дописывается в конец исходного PDF документа.
+func (s *SheetService) Render(processID int64, withOriginal bool) ([]byte, string, error) {
+	process, err := s.approvalService.GetApprovalDetails(processID)
+	if err != nil {
+		return nil, "", err
+	}
+	if process.Status != models.ProcessStatusCompleted {
+		return nil, "", ErrNotCompleted
+	}
+
+	doc, err := s.documentService.GetDocument(process.DocumentID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	font, err := s.loadFont()
+	if err != nil {
+		return nil, "", err
+	}
+
+	sheet := pdfgen.New(font)
+	newSheetLayout(sheet).render(doc, process)
+
+	filename := fmt.Sprintf("approval_sheet_%d.pdf", process.ID)
+	if !withOriginal {
+		data, err := sheet.Bytes()
+		return data, filename, err
+	}
+
+	// Лист дописывается к согласованной версии файла, а не к текущей
+	filePath := process.Document.FilePath
+	if !strings.EqualFold(fileExt(filePath), ".pdf") {
+		return nil, "", ErrNotPDF
+	}
+
+	file, err := s.storage.GetFile(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения файла документа: %w", err)
+	}
+	defer file.Close()
+
+	original, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка чтения файла документа: %w", err)
+	}
+
+	data, err := sheet.AppendTo(original)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNotPDF, err)
+	}
+
+	return data, fmt.Sprintf("document_%d_with_sheet.pdf", doc.ID), nil
+}
+
+func (s *SheetService) loadFont() (*pdfgen.Font, error) {
+	s.fontOnce.Do(func() {
+		s.font, s.fontErr = pdfgen.LoadFont(s.fontPath)
+	})
+	if s.fontErr != nil {
+		return nil, fmt.Errorf("шрифт листа согласования недоступен: %w", s.fontErr)
+	}
+	return s.font, nil
+}
+
+func fileExt(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[i:]
+	}
+	return ""
+}
+
+// Параметры разметки листа, в пунктах
+const (
+	marginX      = 50.0
+	marginTop    = 60.0
+	marginBottom = 60.0
+	contentWidth = pdfgen.PageWidth - 2*marginX
+	bodySize     = 10.0
+	lineHeight   = 13.0
+	cellPadding  = 4.0
+	dateLayout   = "02.01.2006"
+	timeLayout   = "02.01.2006 15:04"
+)
+
+// sheetLayout выводит содержимое сверху вниз и переносит его на новую
+// страницу, когда место заканчивается
+type sheetLayout struct {
+	doc  *pdfgen.Document
+	font *pdfgen.Font
+	page *pdfgen.Page
+	y    float64
+}
+
+func newSheetLayout(doc *pdfgen.Document) *sheetLayout {
+	l := &sheetLayout{doc: doc, font: doc.Font()}
+	l.newPage()
+	return l
+}
+
+func (l *sheetLayout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = marginTop
+}
+
+func (l *sheetLayout) ensure(height float64) {
+	if l.y+height > pdfgen.PageHeight-marginBottom {
+		l.newPage()
+	}
+}
+
+func (l *sheetLayout) render(doc *models.Document, process *models.ApprovalProcess) {
+	labels := models.StatusLabelsFor(models.LangRU)
+
+	l.centered("ЛИСТ СОГЛАСОВАНИЯ", 16)
+	l.y += 6
+	for _, line := range l.font.Wrap(doc.Title, 12, contentWidth) {
+		l.centered(line, 12)
+	}
+	l.y += 10
+
+	l.heading("Реквизиты документа")
+	l.table([]float64{170, contentWidth - 170}, nil, [][]string{
+		{"Входящий номер", doc.IncomingNumber},
+		{"Дата поступления", formatDate(doc.ReceiptDate)},
+		{"Срок исполнения", formatDate(doc.DeadlineDate)},
+		{"Тип документа", documentTypeName(doc.DocumentType)},
+		{"Музей", doc.MuseumName},
+		{"КОПУК", strconv.Itoa(doc.Kopuk)},
+		{"Учредитель", doc.Founder},
+		{"ИНН учредителя", doc.FounderINN},
+		{"Контактное лицо", doc.ContactPerson},
+	})
+
+	l.y += 10
+	l.heading("Согласование")
+	summary := [][]string{
+		{"Номер процесса", fmt.Sprintf("%d (круг %d)", process.ID, process.Round)},
+		{"Начато", process.CreatedAt.Format(timeLayout)},
+	}
+	if process.DocumentVersion != nil {
+		summary = append(summary, []string{"Версия файла", strconv.Itoa(*process.DocumentVersion)})
+	}
+	summary = append(summary, []string{"Итог", labels["document"][doc.Status]})
+	l.table([]float64{170, contentWidth - 170}, nil, summary)
+
+	l.y += 10
+	l.heading("Решения")
+	rows := make([][]string, 0, len(process.Approvers))
+	for _, a := range process.Approvers {
+		decidedAt := ""
+		if a.ApprovedAt != nil {
+			decidedAt = a.ApprovedAt.Format(timeLayout)
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(a.Stage),
+			approverName(a),
+			labels["approver"][a.Status],
+			decidedAt,
+			a.Comment,
+		})
+	}
+	l.table(
+		[]float64{40, 150, 80, 80, contentWidth - 350},
+		[]string{"Этап", "Утверждающий", "Решение", "Дата", "Комментарий"},
+		rows,
+	)
+
+	l.y += 16
+	l.ensure(lineHeight)
+	l.page.Text(marginX, l.y, 8, "Лист сформирован "+time.Now().Format(timeLayout))
+}
+
+func (l *sheetLayout) centered(text string, size float64) {
+	l.ensure(size + 4)
+	l.y += size
+	l.page.Text((pdfgen.PageWidth-l.font.TextWidth(text, size))/2, l.y, size, text)
+	l.y += 4
+}
+
+func (l *sheetLayout) heading(text string) {
+	l.ensure(3*lineHeight + 6)
+	l.y += 12
+	l.page.Text(marginX, l.y, 12, text)
+	l.y += 6
+}
+
+// table выводит таблицу с переносом текста в ячейках. Шапка повторяется
+// на каждой странице, строка таблицы не разрывается между страницами.
+func (l *sheetLayout) table(widths []float64, header []string, rows [][]string) {
+	if header != nil {
+		first := 0.0
+		if len(rows) > 0 {
+			first = l.rowHeight(widths, rows[0], bodySize)
+		}
+		l.ensure(l.rowHeight(widths, header, 9) + first)
+		l.row(widths, header, 9)
+	}
+
+	for _, cells := range rows {
+		height := l.rowHeight(widths, cells, bodySize)
+		if l.y+height > pdfgen.PageHeight-marginBottom {
+			l.newPage()
+			if header != nil {
+				l.row(widths, header, 9)
+			}
+		}
+		l.row(widths, cells, bodySize)
+	}
+}
+
+func (l *sheetLayout) row(widths []float64, cells []string, size float64) {
+	height := l.rowHeight(widths, cells, size)
+	x := marginX
+	for i, w := range widths {
+		l.page.Rect(x, l.y, w, height, 0.5)
+		for j, line := range l.font.Wrap(cells[i], size, w-2*cellPadding) {
+			l.page.Text(x+cellPadding, l.y+cellPadding+size+float64(j)*lineHeight, size, line)
+		}
+		x += w
+	}
+	l.y += height
+}
+
+func (l *sheetLayout) rowHeight(widths []float64, row []string, size float64) float64 {
+	lines := 1
+	for i, w := range widths {
+		if n := len(l.font.Wrap(row[i], size, w-2*cellPadding)); n > lines {
+			lines = n
+		}
+	}
+	return float64(lines-1)*lineHeight + size + 2*cellPadding + 3
+}
+
+func approverName(a models.Approver) string {
+	name := ""
+	if a.User != nil {
+		name = strings.TrimSpace(a.User.LastName + " " + a.User.FirstName)
+	}
+	if a.ActedByUser != nil && a.ActedBy != nil && *a.ActedBy != a.UserID {
+		name += " (решение принял " + strings.TrimSpace(a.ActedByUser.LastName+" "+a.ActedByUser.FirstName) + ")"
+	}
+	return name
+}
+
+func documentTypeName(id string) string {
+	for _, t := range config.DocumentTypes {
+		if t.ID == id {
+			return t.Name
+		}
+	}
+	return id
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
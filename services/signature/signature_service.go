@@ -0,0 +1,365 @@
+package signature
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"document-approval/models"
+	"document-approval/pkg/cms"
+	"document-approval/services/approval"
+	"document-approval/services/storage"
+)
+
+var (
+	ErrDisabled       = errors.New("подпись решений не настроена: не задано хранилище доверенных сертификатов")
+	ErrSignerMismatch = errors.New("сертификат подписи выдан не пользователю, принимающему решение")
+	ErrInvalid        = errors.New("подпись решения не прошла проверку")
+)
+
+// SignatureService проверяет подписи CMS решений утверждающих и собирает
+// комплект подписей процесса
+type SignatureService struct {
+	db      *sql.DB
+	storage storage.StorageService
+	roots   *x509.CertPool
+}
+
+// NewSignatureService загружает корневые сертификаты в PEM из файла или
+// каталога trustStorePath. С пустым путем подпись решений отключена.
+func NewSignatureService(db *sql.DB, storage storage.StorageService, trustStorePath string) (*SignatureService, error) {
+	s := &SignatureService{db: db, storage: storage}
+	if trustStorePath == "" {
+		return s, nil
+	}
+
+	roots, err := loadTrustStore(trustStorePath)
+	if err != nil {
+		return nil, err
+	}
+	s.roots = roots
+	return s, nil
+}
+
+// Enabled сообщает, настроено ли хранилище доверенных сертификатов
+func (s *SignatureService) Enabled() bool {
+	return s.roots != nil
+}
+
+func loadTrustStore(path string) (*x509.CertPool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения хранилища доверенных сертификатов: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения хранилища доверенных сертификатов: %w", err)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	roots := x509.NewCertPool()
+	count := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения сертификата %s: %w", file, err)
+		}
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка разбора сертификата %s: %w", file, err)
+			}
+			roots.AddCert(cert)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("в хранилище %s нет сертификатов", path)
+	}
+	return roots, nil
+}
+
+// SigningPayload - текст, который утверждающий подписывает вместе с решением
+type SigningPayload struct {
+	Payload    string `json:"payload"`
+	FileSHA256 string `json:"file_sha256"`
+}
+
+// Payload формирует текст решения пользователя userID по процессу.
+// В него входит хеш версии файла, закрепленной за процессом при запуске,
+// поэтому подпись под решением относится именно к этой версии.
+func (s *SignatureService) Payload(processID, userID int64, decision string) (*SigningPayload, error) {
+	if decision != models.ApproverStatusApproved && decision != models.ApproverStatusRejected {
+		return nil, fmt.Errorf("решение должно быть %s или %s", models.ApproverStatusApproved, models.ApproverStatusRejected)
+	}
+
+	documentID, filePath, err := s.processDocument(processID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileHash, err := s.fileHash(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := fmt.Sprintf(
+		"document-approval decision v1\nprocess: %d\ndocument: %d\nuser: %d\ndecision: %s\nfile-sha256: %s\n",
+		processID, documentID, userID, decision, fileHash,
+	)
+	return &SigningPayload{Payload: payload, FileSHA256: fileHash}, nil
+}
+
+// VerifyDecision проверяет подпись решения пользователя: подпись должна
+// сходиться с текстом решения, сертификат - быть выдан доверенным центром
+// на email пользователя.
+func (s *SignatureService) VerifyDecision(processID, userID int64, approved bool, signature []byte) (*models.DecisionSignature, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	decision := models.ApproverStatusApproved
+	if !approved {
+		decision = models.ApproverStatusRejected
+	}
+	payload, err := s.Payload(processID, userID, decision)
+	if err != nil {
+		return nil, err
+	}
+
+	var email string
+	err = s.db.QueryRow("SELECT COALESCE(email, '') FROM users WHERE id = $1", userID).Scan(&email)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	return verifySigned(payload, email, signature, s.roots)
+}
+
+// verifySigned проверяет подпись текста решения и то, что сертификат выдан
+// на email утверждающего
+func verifySigned(payload *SigningPayload, email string, signature []byte, roots *x509.CertPool) (*models.DecisionSignature, error) {
+	signer, err := cms.VerifyDetached(signature, []byte(payload.Payload), roots)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if !certificateHasEmail(signer.Certificate, email) {
+		return nil, ErrSignerMismatch
+	}
+
+	return &models.DecisionSignature{
+		Signature:     signature,
+		Payload:       payload.Payload,
+		FileHash:      payload.FileSHA256,
+		SignerSubject: signer.Certificate.Subject.String(),
+		SignerSerial:  signer.Certificate.SerialNumber.Text(16),
+		SignedAt:      time.Now(),
+	}, nil
+}
+
+func certificateHasEmail(cert *x509.Certificate, email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, e := range cert.EmailAddresses {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	// Старые сертификаты указывают email только в поле субъекта
+	for _, name := range cert.Subject.Names {
+		if name.Type.String() == "1.2.840.113549.1.9.1" {
+			if v, ok := name.Value.(string); ok && strings.EqualFold(v, email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// processDocument возвращает документ процесса и путь к файлу закрепленной
+// за процессом версии: подписывается именно она
+func (s *SignatureService) processDocument(processID int64) (int64, string, error) {
+	var documentID int64
+	var filePath string
+	err := s.db.QueryRow(`
+        SELECT d.id, COALESCE(v.file_path, d.file_path, '')
+        FROM approval_processes ap
+        JOIN documents d ON d.id = ap.document_id
+        LEFT JOIN document_versions v ON v.document_id = ap.document_id AND v.version = ap.document_version
+        WHERE ap.id = $1
+    `, processID).Scan(&documentID, &filePath)
+	if err == sql.ErrNoRows {
+		return 0, "", approval.ErrProcessNotFound
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("ошибка получения документа процесса: %w", err)
+	}
+	return documentID, filePath, nil
+}
+
+func (s *SignatureService) fileHash(path string) (string, error) {
+	file, err := s.storage.GetFile(path)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения файла документа: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("ошибка чтения файла документа: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bundleEntry - подписанное решение в манифесте комплекта
+type bundleEntry struct {
+	ApproverID    int64     `json:"approver_id"`
+	UserID        int64     `json:"user_id"`
+	ActedBy       *int64    `json:"acted_by,omitempty"`
+	Stage         int       `json:"stage"`
+	Decision      string    `json:"decision"`
+	FileSHA256    string    `json:"file_sha256"`
+	SignerSubject string    `json:"signer_subject"`
+	SignerSerial  string    `json:"signer_serial"`
+	SignedAt      time.Time `json:"signed_at"`
+	Signature     string    `json:"signature"` // имя файла подписи в архиве
+	Payload       string    `json:"payload"`   // имя файла подписанного текста в архиве
+}
+
+// Bundle собирает zip с файлом документа и подписями решений процесса:
+// для каждого решения - подпись approver_<id>.p7s и подписанный текст
+// approver_<id>.txt, которые проверяются, например, openssl cms -verify.
+func (s *SignatureService) Bundle(processID int64) ([]byte, string, error) {
+	documentID, filePath, err := s.processDocument(processID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT id, user_id, acted_by, stage, status, signature, signature_payload,
+               signature_file_hash, signer_subject, signer_serial, signed_at
+        FROM approvers
+        WHERE process_id = $1 AND signature IS NOT NULL
+        ORDER BY stage, id
+    `, processID)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения подписей: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []bundleEntry
+	var signatures, payloads [][]byte
+	for rows.Next() {
+		var e bundleEntry
+		var sig []byte
+		var payload string
+		err := rows.Scan(&e.ApproverID, &e.UserID, &e.ActedBy, &e.Stage, &e.Decision, &sig, &payload,
+			&e.FileSHA256, &e.SignerSubject, &e.SignerSerial, &e.SignedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("ошибка сканирования подписи: %w", err)
+		}
+		e.Signature = fmt.Sprintf("approver_%d.p7s", e.ApproverID)
+		e.Payload = fmt.Sprintf("approver_%d.txt", e.ApproverID)
+		entries = append(entries, e)
+		signatures = append(signatures, sig)
+		payloads = append(payloads, []byte(payload))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("ошибка получения подписей: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	documentName := "document" + strings.ToLower(filepath.Ext(filePath))
+	if err := s.addDocument(zw, documentName, filePath); err != nil {
+		return nil, "", err
+	}
+
+	for i, e := range entries {
+		if err := addZipFile(zw, e.Signature, signatures[i]); err != nil {
+			return nil, "", err
+		}
+		if err := addZipFile(zw, e.Payload, payloads[i]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	fileHash, err := s.fileHash(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	manifest, err := json.MarshalIndent(map[string]any{
+		"process_id":  processID,
+		"document_id": documentID,
+		"document":    documentName,
+		"file_sha256": fileHash,
+		"signatures":  entries,
+	}, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка формирования манифеста: %w", err)
+	}
+	if err := addZipFile(zw, "manifest.json", manifest); err != nil {
+		return nil, "", err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, "", fmt.Errorf("ошибка формирования архива: %w", err)
+	}
+
+	return buf.Bytes(), fmt.Sprintf("approval_%d_signatures.zip", processID), nil
+}
+
+func (s *SignatureService) addDocument(zw *zip.Writer, name, path string) error {
+	file, err := s.storage.GetFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка получения файла документа: %w", err)
+	}
+	defer file.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("ошибка формирования архива: %w", err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("ошибка чтения файла документа: %w", err)
+	}
+	return nil
+}
+
+func addZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("ошибка формирования архива: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("ошибка формирования архива: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package signature
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"document-approval/pkg/cms"
+	"document-approval/pkg/cms/cmstest"
+)
+
+func TestVerifySigned(t *testing.T) {
+	root := cmstest.NewAuthority(t, "Тестовый УЦ")
+	other := cmstest.NewAuthority(t, "Чужой УЦ")
+	signer := root.Issue(t, "Ivanov@pomau.ru")
+
+	payload := &SigningPayload{
+		Payload:    "document-approval decision v1\nprocess: 7\ndocument: 3\nuser: 5\ndecision: approved\nfile-sha256: abc\n",
+		FileSHA256: "abc",
+	}
+	tampered := &SigningPayload{
+		Payload:    "document-approval decision v1\nprocess: 7\ndocument: 3\nuser: 5\ndecision: rejected\nfile-sha256: abc\n",
+		FileSHA256: "abc",
+	}
+
+	tests := []struct {
+		name      string
+		payload   *SigningPayload
+		email     string
+		signature []byte
+		wantErr   error
+	}{
+		{"с подписанными атрибутами", payload, "ivanov@pomau.ru", signer.Sign(t, []byte(payload.Payload), true), nil},
+		{"без подписанных атрибутов", payload, "ivanov@pomau.ru", signer.Sign(t, []byte(payload.Payload), false), nil},
+		{"подписано другое решение", tampered, "ivanov@pomau.ru", signer.Sign(t, []byte(payload.Payload), true), cms.ErrInvalidSignature},
+		{"недоверенный корень", payload, "ivanov@pomau.ru", other.Issue(t, "ivanov@pomau.ru").Sign(t, []byte(payload.Payload), true), cms.ErrUntrusted},
+		{"сертификат другого пользователя", payload, "petrov@pomau.ru", signer.Sign(t, []byte(payload.Payload), true), ErrSignerMismatch},
+		{"у пользователя нет email", payload, "", signer.Sign(t, []byte(payload.Payload), true), ErrSignerMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifySigned(tt.payload, tt.email, tt.signature, root.Pool())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, tt.wantErr)
+				}
+				if errors.Is(tt.wantErr, cms.ErrInvalidSignature) || errors.Is(tt.wantErr, cms.ErrUntrusted) {
+					if !errors.Is(err, ErrInvalid) {
+						t.Fatalf("ошибка проверки подписи должна совпадать с ErrInvalid: %v", err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifySigned: %v", err)
+			}
+
+			if got.Payload != tt.payload.Payload || got.FileHash != tt.payload.FileSHA256 {
+				t.Errorf("в результате не тот текст решения: %+v", got)
+			}
+			if got.SignerSerial != signer.Certificate.SerialNumber.Text(16) {
+				t.Errorf("серийный номер %s, ожидался %s", got.SignerSerial, signer.Certificate.SerialNumber.Text(16))
+			}
+			if string(got.Signature) != string(tt.signature) {
+				t.Error("в результате не та подпись")
+			}
+		})
+	}
+}
+
+func TestVerifyDecisionDisabled(t *testing.T) {
+	s := &SignatureService{}
+	if _, err := s.VerifyDecision(1, 1, true, []byte("signature")); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("ошибка %v, ожидалась ErrDisabled", err)
+	}
+}
+
+func TestCertificateHasEmail(t *testing.T) {
+	oidEmail := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+	legacy := &x509.Certificate{Subject: pkix.Name{
+		Names: []pkix.AttributeTypeAndValue{{Type: oidEmail, Value: "Petrov@pomau.ru"}},
+	}}
+
+	tests := []struct {
+		name  string
+		cert  *x509.Certificate
+		email string
+		want  bool
+	}{
+		{"email в SAN без учета регистра", &x509.Certificate{EmailAddresses: []string{"Ivanov@pomau.ru"}}, "ivanov@pomau.ru", true},
+		{"другой email", &x509.Certificate{EmailAddresses: []string{"ivanov@pomau.ru"}}, "petrov@pomau.ru", false},
+		{"email в субъекте", legacy, "petrov@pomau.ru", true},
+		{"пустой email", &x509.Certificate{EmailAddresses: []string{""}}, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := certificateHasEmail(tt.cert, tt.email); got != tt.want {
+			t.Errorf("%s: %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -88,8 +88,10 @@ func (s *GlusterStorage) SaveFile(file io.Reader, filename string) (string, erro
 	}
 	defer dst.Close()
 
-	// Копируем содержимое
+	// Копируем содержимое, недописанный файл удаляем
 	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		os.Remove(fullPath)
 		return "", fmt.Errorf("ошибка копирования файла: %w", err)
 	}
 
@@ -105,6 +107,14 @@ func (s *GlusterStorage) GetFile(path string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// DeleteFile удаляет файл. Отсутствующий файл ошибкой не считается.
+func (s *GlusterStorage) DeleteFile(path string) error {
+	if err := os.Remove(filepath.Join(s.mountPoint, path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления файла: %w", err)
+	}
+	return nil
+}
+
 func (s *GlusterStorage) generatePath(filename string) string {
 	// Генерируем путь на основе текущей даты
 	now := time.Now()
@@ -6,4 +6,5 @@ type StorageService interface {
 	SaveFile(file io.Reader, filename string) (string, error)
 	GetFile(path string) (io.ReadCloser, error)
 	ExtractText(filePath string) (string, error)
+	DeleteFile(path string) error
 }
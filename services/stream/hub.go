@@ -0,0 +1,301 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"document-approval/models"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// retention - сколько хранятся события; переподключившийся позже клиент
+	// получит не все пропущенное и должен перечитать данные целиком
+	retention = 24 * time.Hour
+	// replayLimit - сколько пропущенных событий отдается при переподключении.
+	// Если пропущено больше, клиент получает stream.reload.
+	replayLimit = 500
+	// lookBack - окно повторного чтения перед позицией. ID события выдается
+	// при вставке, а видно оно после фиксации транзакции, поэтому событие с
+	// меньшим ID может появиться позже большего. Догонка перечитывает события,
+	// записанные за lookBack до события на позиции, и отсеивает уже отданные.
+	lookBack = 5 * time.Minute
+	// bufferSize - очередь событий подписчика. Подписчик, который не успевает
+	// их читать, отключается и догоняет по Last-Event-ID.
+	bufferSize = 64
+)
+
+// Hub раздает события потока подключенным клиентам этой реплики. События
+// записывает Publish в любой реплике, о новых событиях Hub узнает через
+// LISTEN/NOTIFY и читает их из таблицы stream_events.
+type Hub struct {
+	db  *sql.DB
+	dsn string
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	lastID int64               // последнее полученное событие, для догонки после обрыва LISTEN
+	seen   map[int64]time.Time // уже разосланные события и время рассылки
+}
+
+// Subscription - подписка одного подключения на события пользователя
+type Subscription struct {
+	hub    *Hub
+	userID int64
+	events chan models.StreamEvent
+	closed bool
+}
+
+func NewHub(db *sql.DB, dsn string) *Hub {
+	return &Hub{
+		db:   db,
+		dsn:  dsn,
+		subs: make(map[*Subscription]struct{}),
+		seen: make(map[int64]time.Time),
+	}
+}
+
+// Run слушает уведомления о новых событиях и удаляет устаревшие, пока не
+// отменен контекст
+func (h *Hub) Run(ctx context.Context) {
+	if err := h.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM stream_events`).Scan(&h.lastID); err != nil {
+		log.Printf("Ошибка получения последнего события потока: %v", err)
+	}
+
+	listener := pq.NewListener(h.dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Поток событий: ошибка соединения LISTEN: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		log.Printf("Ошибка подписки на канал %s: %v", channel, err)
+		return
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n := <-listener.Notify:
+			// nil приходит после переподключения: уведомления за время
+			// обрыва потеряны, догоняем по таблице
+			if n == nil {
+				h.catchUp()
+				continue
+			}
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				log.Printf("Поток событий: неверное уведомление %q", n.Extra)
+				continue
+			}
+			event, err := h.get(id)
+			if err != nil {
+				log.Printf("Поток событий: %v", err)
+				continue
+			}
+			h.dispatch(*event)
+
+		case <-ping.C:
+			go listener.Ping()
+			h.forget(time.Now().Add(-2 * lookBack))
+
+		case <-cleanup.C:
+			_, err := h.db.Exec(`
+                DELETE FROM stream_events WHERE created_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+            `, retention.Seconds())
+			if err != nil {
+				log.Printf("Ошибка удаления старых событий потока: %v", err)
+			}
+		}
+	}
+}
+
+// Subscribe подписывает подключение на события пользователя и общие события.
+// Подписку нужно закрыть вызовом Close.
+func (h *Hub) Subscribe(userID int64) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		userID: userID,
+		events: make(chan models.StreamEvent, bufferSize),
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// Events - канал событий подписки. Канал закрывается, если подписчик отстал.
+func (s *Subscription) Events() <-chan models.StreamEvent {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove вызывается под h.mu
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subs, sub)
+	close(sub.events)
+}
+
+// LastID возвращает ID последнего записанного события. С него начинает
+// поток клиент, который подключается впервые.
+func (h *Hub) LastID() (int64, error) {
+	var id int64
+	if err := h.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM stream_events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("ошибка получения последнего события потока: %w", err)
+	}
+	return id, nil
+}
+
+// Since возвращает события пользователя, которые клиент мог пропустить после
+// afterID: события с большим ID и события из окна lookBack перед afterID,
+// зафиксированные позже него. Часть событий окна клиент мог уже получить,
+// повторы он отсеивает по ID. truncated - пропущено больше replayLimit
+// событий, клиенту нужно перечитать данные целиком.
+func (h *Hub) Since(userID, afterID int64) (events []models.StreamEvent, truncated bool, err error) {
+	rows, err := h.db.Query(`
+        SELECT id, user_id, event_type, data, created_at
+        FROM stream_events
+        WHERE (user_id = $2 OR user_id IS NULL)
+          AND (id > $1 OR created_at >= (
+              SELECT created_at - $4 * INTERVAL '1 second' FROM stream_events WHERE id = $1
+          ))
+        ORDER BY id
+        LIMIT $3
+    `, afterID, userID, replayLimit+1, lookBack.Seconds())
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка получения пропущенных событий: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		events = append(events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("ошибка получения пропущенных событий: %w", err)
+	}
+
+	if len(events) > replayLimit {
+		return events[:replayLimit], true, nil
+	}
+	return events, false, nil
+}
+
+func (h *Hub) get(id int64) (*models.StreamEvent, error) {
+	return scanEvent(h.db.QueryRow(`
+        SELECT id, user_id, event_type, data, created_at
+        FROM stream_events WHERE id = $1
+    `, id))
+}
+
+// catchUp раздает события, пропущенные за время обрыва LISTEN: записанные
+// после последнего полученного и зафиксированные позже него из окна lookBack.
+// Уже разосланные события dispatch пропускает.
+func (h *Hub) catchUp() {
+	h.mu.Lock()
+	lastID := h.lastID
+	h.mu.Unlock()
+
+	rows, err := h.db.Query(`
+        SELECT id, user_id, event_type, data, created_at
+        FROM stream_events
+        WHERE id > $1 OR created_at >= (
+            SELECT created_at - $2 * INTERVAL '1 second' FROM stream_events WHERE id = $1
+        )
+        ORDER BY id
+    `, lastID, lookBack.Seconds())
+	if err != nil {
+		log.Printf("Ошибка получения событий потока после переподключения: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			log.Printf("Поток событий: %v", err)
+			return
+		}
+		h.dispatch(*e)
+	}
+}
+
+// dispatch передает событие подписчикам, если оно еще не разослано.
+// Подписчик с заполненной очередью отключается, чтобы медленный клиент
+// не задерживал остальных.
+func (h *Hub) dispatch(e models.StreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.seen[e.ID]; ok {
+		return
+	}
+	h.seen[e.ID] = time.Now()
+	if e.ID > h.lastID {
+		h.lastID = e.ID
+	}
+
+	for sub := range h.subs {
+		if e.UserID != nil && *e.UserID != sub.userID {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// forget удаляет из списка разосланных события, разосланные до before
+func (h *Hub) forget(before time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, at := range h.seen {
+		if at.Before(before) {
+			delete(h.seen, id)
+		}
+	}
+}
+
+func scanEvent(row interface{ Scan(...any) error }) (*models.StreamEvent, error) {
+	var e models.StreamEvent
+	var data []byte
+	if err := row.Scan(&e.ID, &e.UserID, &e.Type, &data, &e.CreatedAt); err != nil {
+		return nil, fmt.Errorf("ошибка сканирования события потока: %w", err)
+	}
+	if err := json.Unmarshal(data, &e.Data); err != nil {
+		return nil, fmt.Errorf("ошибка разбора события потока: %w", err)
+	}
+	return &e, nil
+}
@@ -0,0 +1,51 @@
+package stream
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// channel - канал LISTEN/NOTIFY, по которому реплики узнают о новых событиях
+const channel = "stream_events"
+
+// Publish записывает событие потока для пользователя, а при userID == nil -
+// для всех. Вызывается внутри транзакции операции: уведомление NOTIFY
+// отправляется только при фиксации транзакции.
+func Publish(tx *sql.Tx, userID *int64, eventType string, data map[string]any) error {
+	if data == nil {
+		data = map[string]any{}
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события потока: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO stream_events (user_id, event_type, data)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `, userID, eventType, payload).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("ошибка записи события потока: %w", err)
+	}
+
+	if _, err := tx.Exec(`SELECT pg_notify($1, $2)`, channel, strconv.FormatInt(id, 10)); err != nil {
+		return fmt.Errorf("ошибка уведомления о событии потока: %w", err)
+	}
+
+	return nil
+}
+
+// PublishEach записывает одно и то же событие для каждого из пользователей
+func PublishEach(tx *sql.Tx, userIDs []int64, eventType string, data map[string]any) error {
+	for _, id := range userIDs {
+		id := id
+		if err := Publish(tx, &id, eventType, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,11 +1,38 @@
 package user
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"document-approval/models"
+	"document-approval/pkg/password"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Количество неудачных попыток входа до блокировки
+	maxFailedLogins = 5
+	lockoutDuration = 15 * time.Minute
 )
 
+var (
+	ErrInvalidCredentials = errors.New("неверный email или пароль")
+	ErrUserDeactivated    = errors.New("учетная запись отключена")
+	ErrUserNotFound       = errors.New("пользователь не найден")
+	ErrESIALinkRequired   = errors.New("пользователь с таким email уже существует, привязку к ЕСИА должен подтвердить администратор")
+)
+
+// UserFilter - параметры поиска пользователей
+type UserFilter struct {
+	Query           string
+	Role            models.Role
+	IncludeInactive bool
+}
+
 type UserService struct {
 	db *sql.DB
 }
@@ -16,44 +43,492 @@ func NewUserService(db *sql.DB) *UserService {
 	}
 }
 
-func (s *UserService) Authenticate(email, password string) (*models.User, error) {
+func (s *UserService) Authenticate(email, pass string) (*models.User, error) {
 	var user models.User
+	var (
+		passwordHash   sql.NullString
+		legacyPassword sql.NullString
+		lockedUntil    sql.NullTime
+	)
 	err := s.db.QueryRow(`
-        SELECT id, first_name, last_name, email, created_at
-        FROM users WHERE email = $1 AND password = $2
-    `, email, password).Scan(
+        SELECT id, first_name, last_name, email, position, is_active, created_at,
+               password_hash, password, locked_until
+        FROM users WHERE lower(email) = lower($1)
+    `, email).Scan(
 		&user.ID, &user.FirstName,
-		&user.LastName, &user.Email, &user.CreatedAt,
+		&user.LastName, &user.Email, &user.Position, &user.IsActive, &user.CreatedAt,
+		&passwordHash, &legacyPassword, &lockedUntil,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("неверный email или пароль")
+		password.VerifyDummy(pass)
+		return nil, ErrInvalidCredentials
 	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка аутентификации: %w", err)
 	}
 
+	// Во время блокировки пароль не проверяем и отвечаем как на неверный
+	// пароль: иначе подбор продолжился бы, а ответ выдавал бы блокировку
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		password.VerifyDummy(pass)
+		return nil, ErrInvalidCredentials
+	}
+
+	var ok, needsRehash bool
+	if passwordHash.Valid {
+		ok, needsRehash = password.Verify(pass, passwordHash.String)
+	} else if legacyPassword.Valid {
+		// Пароль из старой схемы хранится открытым текстом
+		ok = subtle.ConstantTimeCompare([]byte(pass), []byte(legacyPassword.String)) == 1
+		needsRehash = true
+	} else {
+		password.VerifyDummy(pass)
+	}
+
+	if !ok {
+		if err := s.registerFailedLogin(user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// Об отключении сообщаем только тому, кто знает пароль
+	if !user.IsActive {
+		return nil, ErrUserDeactivated
+	}
+
+	if needsRehash {
+		if err := s.SetPassword(user.ID, pass); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.db.Exec(`
+        UPDATE users SET failed_login_attempts = 0, locked_until = NULL
+        WHERE id = $1
+    `, user.ID); err != nil {
+		return nil, fmt.Errorf("ошибка сброса счетчика попыток входа: %w", err)
+	}
+
 	return &user, nil
 }
 
-func (s *UserService) GetUserByID(id int64) (*models.User, error) {
-	var user models.User
+// registerFailedLogin увеличивает счетчик неудачных попыток
+// и блокирует учетную запись при превышении лимита. После истечения
+// блокировки счетчик начинается заново.
+func (s *UserService) registerFailedLogin(userID int64) error {
+	_, err := s.db.Exec(`
+        UPDATE users SET
+            failed_login_attempts = attempts.n,
+            locked_until = CASE
+                WHEN attempts.n >= $2
+                THEN CURRENT_TIMESTAMP + make_interval(secs => $3)
+            END
+        FROM (
+            SELECT CASE WHEN locked_until <= CURRENT_TIMESTAMP THEN 0 ELSE failed_login_attempts END + 1 AS n
+            FROM users WHERE id = $1
+        ) attempts
+        WHERE id = $1
+    `, userID, maxFailedLogins, lockoutDuration.Seconds())
+
+	if err != nil {
+		return fmt.Errorf("ошибка учета неудачной попытки входа: %w", err)
+	}
+
+	return nil
+}
+
+// ChangePassword меняет пароль пользователя после проверки текущего
+func (s *UserService) ChangePassword(userID int64, currentPassword, newPassword string) error {
+	var passwordHash, legacyPassword sql.NullString
 	err := s.db.QueryRow(`
-        SELECT id, first_name, last_name, email, created_at
-        FROM users WHERE id = $1
-    `, id).Scan(
-		&user.ID, &user.FirstName,
-		&user.LastName, &user.Email, &user.CreatedAt,
+        SELECT password_hash, password FROM users WHERE id = $1
+    `, userID).Scan(&passwordHash, &legacyPassword)
+
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	var ok bool
+	if passwordHash.Valid {
+		ok, _ = password.Verify(currentPassword, passwordHash.String)
+	} else if legacyPassword.Valid {
+		ok = subtle.ConstantTimeCompare([]byte(currentPassword), []byte(legacyPassword.String)) == 1
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	return s.SetPassword(userID, newPassword)
+}
+
+// SetPassword сохраняет новый пароль без проверки текущего (сброс администратором)
+// и снимает блокировку учетной записи
+func (s *UserService) SetPassword(userID int64, newPassword string) error {
+	if err := password.Validate(newPassword); err != nil {
+		return err
+	}
+
+	hash, err := password.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+        UPDATE users SET
+            password_hash = $1,
+            password = NULL,
+            password_changed_at = CURRENT_TIMESTAMP,
+            failed_login_attempts = 0,
+            locked_until = NULL
+        WHERE id = $2
+    `, hash, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения пароля: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+const userColumns = `id, COALESCE(esia_id, ''), first_name, last_name, email, position, manager_id, is_active, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.ESIAID, &user.FirstName, &user.LastName,
+		&user.Email, &user.Position, &user.ManagerID, &user.IsActive, &user.CreatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *UserService) GetUserByID(id int64) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`
+        SELECT `+userColumns+`
+        FROM users WHERE id = $1
+    `, id))
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("пользователь не найден")
+		return nil, ErrUserNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+// ListUsers ищет пользователей по имени или email. Роли подгружаются для каждого пользователя.
+func (s *UserService) ListUsers(filter UserFilter) ([]models.User, error) {
+	query := `
+        SELECT ` + userColumns + `
+        FROM users u
+        WHERE 1=1
+    `
+	params := []interface{}{}
+
+	if !filter.IncludeInactive {
+		query += " AND u.is_active"
+	}
+
+	if filter.Query != "" {
+		params = append(params, "%"+strings.ToLower(filter.Query)+"%")
+		query += fmt.Sprintf(` AND (
+            lower(u.first_name) LIKE $%[1]d
+            OR lower(u.last_name) LIKE $%[1]d
+            OR lower(u.first_name || ' ' || u.last_name) LIKE $%[1]d
+            OR lower(u.last_name || ' ' || u.first_name) LIKE $%[1]d
+            OR lower(u.email) LIKE $%[1]d
+        )`, len(params))
+	}
+
+	if filter.Role != "" {
+		params = append(params, filter.Role)
+		query += fmt.Sprintf(` AND EXISTS (
+            SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role = $%d
+        )`, len(params))
+	}
+
+	query += " ORDER BY u.last_name, u.first_name, u.id"
+
+	rows, err := s.db.Query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования пользователя: %w", err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+
+	for i := range users {
+		roles, err := s.GetUserRoles(users[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		users[i].Roles = roles
+	}
+
+	return users, nil
+}
+
+// CreateUser создает пользователя с паролем (может быть пустым для входа только через ЕСИА)
+func (s *UserService) CreateUser(user *models.User, pass string, roles []models.Role) error {
+	if err := validateUser(user); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if !role.Valid() {
+			return fmt.Errorf("неизвестная роль: %s", role)
+		}
+	}
+
+	var passwordHash sql.NullString
+	if pass != "" {
+		if err := password.Validate(pass); err != nil {
+			return err
+		}
+		hash, err := password.Hash(pass)
+		if err != nil {
+			return err
+		}
+		passwordHash = sql.NullString{String: hash, Valid: true}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+        INSERT INTO users (first_name, last_name, email, position, password_hash, password_changed_at)
+        VALUES ($1, $2, $3, $4, $5, CASE WHEN $5::text IS NULL THEN NULL ELSE CURRENT_TIMESTAMP END)
+        RETURNING id, is_active, created_at
+    `, user.FirstName, user.LastName, user.Email, user.Position, passwordHash).Scan(
+		&user.ID, &user.IsActive, &user.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка создания пользователя: %w", err)
+	}
+
+	for _, role := range roles {
+		if _, err := tx.Exec(`
+            INSERT INTO user_roles (user_id, role)
+            VALUES ($1, $2)
+            ON CONFLICT (user_id, role) DO NOTHING
+        `, user.ID, role); err != nil {
+			return fmt.Errorf("ошибка назначения роли: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка создания пользователя: %w", err)
+	}
+
+	user.Roles = make([]string, len(roles))
+	for i, role := range roles {
+		user.Roles[i] = string(role)
+	}
+
+	return nil
+}
+
+// UserUpdate - изменения профиля пользователя. Поля nil не меняются;
+// SetManager с ManagerID = nil снимает руководителя.
+type UserUpdate struct {
+	FirstName  *string
+	LastName   *string
+	Email      *string
+	Position   *string
+	SetManager bool
+	ManagerID  *int64
+}
+
+// UpdateUser изменяет переданные поля профиля и возвращает пользователя
+func (s *UserService) UpdateUser(userID int64, upd UserUpdate) (*models.User, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upd.FirstName != nil {
+		user.FirstName = *upd.FirstName
+	}
+	if upd.LastName != nil {
+		user.LastName = *upd.LastName
+	}
+	if upd.Email != nil {
+		user.Email = *upd.Email
+	}
+	if upd.Position != nil {
+		user.Position = *upd.Position
+	}
+	if upd.SetManager {
+		user.ManagerID = upd.ManagerID
+	}
+
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	if user.ManagerID != nil && *user.ManagerID == user.ID {
+		return nil, fmt.Errorf("пользователь не может быть своим руководителем")
+	}
+
+	updated, err := scanUser(s.db.QueryRow(`
+        UPDATE users SET first_name = $1, last_name = $2, email = $3, position = $4, manager_id = $5
+        WHERE id = $6
+        RETURNING `+userColumns,
+		user.FirstName, user.LastName, user.Email, user.Position, user.ManagerID, user.ID,
+	))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления пользователя: %w", err)
+	}
+
+	return updated, nil
+}
+
+// SetActive включает или отключает учетную запись.
+// Отключенный пользователь не может войти и не предлагается в качестве утверждающего.
+func (s *UserService) SetActive(userID int64, active bool) error {
+	result, err := s.db.Exec(`
+        UPDATE users SET
+            is_active = $1,
+            deactivated_at = CASE WHEN $1 THEN NULL ELSE CURRENT_TIMESTAMP END
+        WHERE id = $2
+    `, active, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения статуса пользователя: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func validateUser(user *models.User) error {
+	if strings.TrimSpace(user.FirstName) == "" || strings.TrimSpace(user.LastName) == "" {
+		return fmt.Errorf("имя и фамилия обязательны")
+	}
+	if user.Email != "" && !strings.Contains(user.Email, "@") {
+		return fmt.Errorf("неверный формат email")
+	}
+	return nil
+}
+
+// FindOrCreateByESIA возвращает пользователя, привязанного к учетной записи ЕСИА,
+// или создает нового. Существующего пользователя с тем же email автоматически
+// не привязываем: email в ЕСИА не доказывает владение учетной записью, привязку
+// подтверждает администратор через LinkESIA.
+func (s *UserService) FindOrCreateByESIA(esiaID, firstName, lastName, email string) (*models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRow(`
+        SELECT `+userColumns+`
+        FROM users WHERE esia_id = $1
+    `, esiaID))
+	if err == nil {
+		if !user.IsActive {
+			return nil, ErrUserDeactivated
+		}
+		return user, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("ошибка поиска пользователя ЕСИА: %w", err)
+	}
+
+	if email != "" {
+		var exists bool
+		err := tx.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))
+        `, email).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка поиска пользователя ЕСИА: %w", err)
+		}
+		if exists {
+			return nil, ErrESIALinkRequired
+		}
+	}
+
+	user, err = scanUser(tx.QueryRow(`
+        INSERT INTO users (esia_id, first_name, last_name, email)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+userColumns,
+		esiaID, firstName, lastName, email,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания пользователя ЕСИА: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO user_roles (user_id, role)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id, role) DO NOTHING
+    `, user.ID, models.RoleEmployee); err != nil {
+		return nil, fmt.Errorf("ошибка назначения роли: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения пользователя ЕСИА: %w", err)
+	}
+
+	return user, nil
+}
+
+// LinkESIA привязывает пользователя к учетной записи ЕСИА по решению
+// администратора. Пустой esiaID снимает привязку.
+func (s *UserService) LinkESIA(userID int64, esiaID string) error {
+	var value sql.NullString
+	if esiaID != "" {
+		value = sql.NullString{String: esiaID, Valid: true}
+	}
+
+	result, err := s.db.Exec(`UPDATE users SET esia_id = $1 WHERE id = $2`, value, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("учетная запись ЕСИА уже привязана к другому пользователю")
+		}
+		return fmt.Errorf("ошибка привязки пользователя ЕСИА: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
 }
 
 func (s *UserService) GetUserRoles(userID int64) ([]string, error) {
@@ -90,3 +565,15 @@ func (s *UserService) AssignRole(userID int64, role models.Role) error {
 
 	return nil
 }
+
+func (s *UserService) RemoveRole(userID int64, role models.Role) error {
+	_, err := s.db.Exec(`
+        DELETE FROM user_roles WHERE user_id = $1 AND role = $2
+    `, userID, role)
+
+	if err != nil {
+		return fmt.Errorf("ошибка снятия роли: %w", err)
+	}
+
+	return nil
+}
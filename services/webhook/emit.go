@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EmitDocument создает доставки события документа всем подписчикам.
+// Вызывается внутри транзакции операции, поэтому доставка появляется только
+// вместе с изменением. В данные события добавляется сам документ.
+func EmitDocument(tx *sql.Tx, documentID int64, event string, data map[string]any) error {
+	subscribed, err := hasSubscribers(tx, event)
+	if err != nil || !subscribed {
+		return err
+	}
+
+	document, err := documentData(tx, documentID)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{"document": document}
+	for k, v := range data {
+		payload[k] = v
+	}
+	return emit(tx, event, payload)
+}
+
+// EmitForProcess создает доставки события процесса согласования. В данные
+// события добавляются процесс и его документ.
+func EmitForProcess(tx *sql.Tx, processID int64, event string, data map[string]any) error {
+	subscribed, err := hasSubscribers(tx, event)
+	if err != nil || !subscribed {
+		return err
+	}
+
+	var documentID int64
+	var round int
+	err = tx.QueryRow(`
+        SELECT document_id, round FROM approval_processes WHERE id = $1
+    `, processID).Scan(&documentID, &round)
+	if err != nil {
+		return fmt.Errorf("ошибка получения процесса для вебхука: %w", err)
+	}
+
+	document, err := documentData(tx, documentID)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"process_id": processID,
+		"round":      round,
+		"document":   document,
+	}
+	for k, v := range data {
+		payload[k] = v
+	}
+	return emit(tx, event, payload)
+}
+
+func hasSubscribers(tx *sql.Tx, event string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM webhooks WHERE is_active AND $1 = ANY(event_types))
+    `, event).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки подписок на события: %w", err)
+	}
+	return exists, nil
+}
+
+// documentData - сведения о документе, которые получает подписчик
+func documentData(tx *sql.Tx, documentID int64) (map[string]any, error) {
+	var title, documentType, status, incomingNumber string
+	var metadata []byte
+	err := tx.QueryRow(`
+        SELECT title, COALESCE(document_type, ''), status, COALESCE(incoming_number, ''),
+               COALESCE(metadata, '{}'::jsonb)
+        FROM documents WHERE id = $1
+    `, documentID).Scan(&title, &documentType, &status, &incomingNumber, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения документа для вебхука: %w", err)
+	}
+
+	return map[string]any{
+		"id":              documentID,
+		"title":           title,
+		"document_type":   documentType,
+		"status":          status,
+		"incoming_number": incomingNumber,
+		"metadata":        json.RawMessage(metadata),
+	}, nil
+}
+
+// emit записывает событие в журнал доставок каждого активного подписчика.
+// У всех доставок события общий event_id, по нему подписчик отсеивает повторы.
+func emit(tx *sql.Tx, event string, data map[string]any) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("ошибка генерации ID события: %w", err)
+	}
+	eventID := hex.EncodeToString(id)
+
+	payload, err := json.Marshal(map[string]any{
+		"id":         eventID,
+		"event":      event,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"data":       data,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %w", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO webhook_deliveries (webhook_id, event_type, event_id, payload)
+        SELECT id, $1, $2, $3 FROM webhooks WHERE is_active AND $1 = ANY(event_types)
+    `, event, eventID, payload)
+	if err != nil {
+		return fmt.Errorf("ошибка записи доставки вебхука: %w", err)
+	}
+
+	return nil
+}
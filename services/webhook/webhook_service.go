@@ -0,0 +1,475 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"document-approval/models"
+
+	"github.com/lib/pq"
+)
+
+// Статусы доставок
+const (
+	StatusPending   = "pending"
+	StatusSending   = "sending" // закреплена за отправителем, результат еще не записан
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Параметры доставки
+const (
+	batchSize       = 20
+	maxAttempts     = 10
+	maxBackoff      = 12 * time.Hour
+	requestTimeout  = 10 * time.Second
+	maxResponseBody = 1024
+
+	// claimTimeout - на сколько доставка закрепляется за отправителем.
+	// Должен быть заметно больше requestTimeout на всю порцию.
+	claimTimeout = 10 * time.Minute
+)
+
+var (
+	ErrNotFound         = errors.New("вебхук не найден")
+	ErrDeliveryNotFound = errors.New("доставка не найдена")
+	ErrInactive         = errors.New("вебхук отключен")
+)
+
+// WebhookService хранит подписки на события и доставляет события подписчикам.
+//
+// Доставка - POST с JSON события. Подпись передается в заголовке
+// X-Webhook-Signature как sha256=<hex HMAC-SHA256(секрет, timestamp + "." + тело)>,
+// timestamp - в заголовке X-Webhook-Timestamp. Успешной считается доставка
+// с ответом 2xx, остальные повторяются с растущей паузой.
+type WebhookService struct {
+	db     *sql.DB
+	client *http.Client
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	return &WebhookService{
+		db:     db,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// List возвращает все подписки без секретов
+func (s *WebhookService) List() ([]models.Webhook, error) {
+	rows, err := s.db.Query(`
+        SELECT id, url, event_types, description, is_active, created_by, created_at, updated_at
+        FROM webhooks
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения вебхуков: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+
+	return webhooks, nil
+}
+
+// Get возвращает подписку без секрета
+func (s *WebhookService) Get(id int64) (*models.Webhook, error) {
+	w, err := scanWebhook(s.db.QueryRow(`
+        SELECT id, url, event_types, description, is_active, created_by, created_at, updated_at
+        FROM webhooks WHERE id = $1
+    `, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return w, err
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(&w.ID, &w.URL, pq.Array(&w.EventTypes), &w.Description, &w.IsActive,
+		&w.CreatedBy, &w.CreatedAt, &w.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сканирования вебхука: %w", err)
+	}
+	return &w, nil
+}
+
+// Create регистрирует подписку. Если секрет не указан, он генерируется;
+// в ответе секрет возвращается один раз.
+func (s *WebhookService) Create(w *models.Webhook, createdBy int64) error {
+	if err := validate(w); err != nil {
+		return err
+	}
+	if w.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+
+	w.CreatedBy = &createdBy
+	err := s.db.QueryRow(`
+        INSERT INTO webhooks (url, secret, event_types, description, is_active, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at, updated_at
+    `, w.URL, w.Secret, pq.Array(w.EventTypes), w.Description, w.IsActive, createdBy).
+		Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания вебхука: %w", err)
+	}
+
+	return nil
+}
+
+// Update меняет подписку. Непустой секрет заменяет прежний и возвращается
+// в ответе, пустой оставляет прежний.
+func (s *WebhookService) Update(w *models.Webhook) error {
+	if err := validate(w); err != nil {
+		return err
+	}
+
+	err := s.db.QueryRow(`
+        UPDATE webhooks
+        SET url = $1, event_types = $2, description = $3, is_active = $4,
+            secret = COALESCE(NULLIF($5, ''), secret), updated_at = CURRENT_TIMESTAMP
+        WHERE id = $6
+        RETURNING created_by, created_at, updated_at
+    `, w.URL, pq.Array(w.EventTypes), w.Description, w.IsActive, w.Secret, w.ID).
+		Scan(&w.CreatedBy, &w.CreatedAt, &w.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления вебхука: %w", err)
+	}
+
+	return nil
+}
+
+// Delete удаляет подписку вместе с журналом ее доставок
+func (s *WebhookService) Delete(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления вебхука: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func validate(w *models.Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("адрес вебхука должен быть абсолютным URL http или https")
+	}
+	if len(w.EventTypes) == 0 {
+		return fmt.Errorf("укажите хотя бы одно событие")
+	}
+	for _, e := range w.EventTypes {
+		if !models.ValidWebhookEvent(e) {
+			return fmt.Errorf("неизвестное событие: %s", e)
+		}
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации секрета: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ListDeliveries возвращает журнал доставок подписки, начиная с последних
+func (s *WebhookService) ListDeliveries(webhookID int64, limit, offset int) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(webhookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT id, webhook_id, event_type, event_id, payload, status, attempts, next_attempt_at,
+               response_status, COALESCE(response_body, ''), COALESCE(last_error, ''),
+               redelivery_of, created_at, delivered_at
+        FROM webhook_deliveries
+        WHERE webhook_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2 OFFSET $3
+    `, webhookID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения доставок: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.EventID, &payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.ResponseStatus, &d.ResponseBody, &d.LastError,
+			&d.RedeliveryOf, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования доставки: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver ставит событие доставки в очередь повторно. Создается новая
+// доставка с тем же событием, исходная остается в журнале как есть.
+func (s *WebhookService) Redeliver(deliveryID int64) (int64, error) {
+	var active bool
+	err := s.db.QueryRow(`
+        SELECT w.is_active FROM webhook_deliveries d
+        JOIN webhooks w ON w.id = d.webhook_id
+        WHERE d.id = $1
+    `, deliveryID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return 0, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения доставки: %w", err)
+	}
+	if !active {
+		return 0, ErrInactive
+	}
+
+	var id int64
+	err = s.db.QueryRow(`
+        INSERT INTO webhook_deliveries (webhook_id, event_type, event_id, payload, redelivery_of)
+        SELECT webhook_id, event_type, event_id, payload, id FROM webhook_deliveries WHERE id = $1
+        RETURNING id
+    `, deliveryID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка повторной доставки: %w", err)
+	}
+
+	return id, nil
+}
+
+// RunDispatcher периодически доставляет события из журнала, пока не отменен контекст
+func (s *WebhookService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.DispatchPending(); err != nil {
+			log.Printf("Ошибка доставки вебхуков: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type pendingDelivery struct {
+	id       int64
+	event    string
+	eventID  string
+	url      string
+	secret   string
+	payload  []byte
+	attempts int
+}
+
+// DispatchPending отправляет очередную порцию доставок. Доставки сначала
+// закрепляются за отправителем коротким запросом, затем отправляются вне
+// транзакции, и результат каждой записывается отдельной командой: медленный
+// подписчик не держит блокировки строк, а ошибка записи одной доставки не
+// отменяет остальные. После maxAttempts неудачных попыток доставка
+// помечается как неудавшаяся.
+func (s *WebhookService) DispatchPending() error {
+	pending, err := s.claim()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range pending {
+		code, body, sendErr := s.deliver(d)
+		if sendErr != nil {
+			log.Printf("Вебхук: доставка %d не удалась (попытка %d): %v", d.id, d.attempts, sendErr)
+		}
+		if err := s.record(d.id, deliveryOutcome(d, code, body, sendErr)); err != nil {
+			// Доставка останется закрепленной и вернется в работу по истечении claimTimeout
+			log.Printf("Вебхук: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// claim закрепляет порцию доставок за этим отправителем на claimTimeout и
+// засчитывает попытку. Доставки блокируются с SKIP LOCKED, поэтому реплики
+// не отправляют одно событие одновременно. Если отправитель упал, не записав
+// результат, доставка снова берется в работу по истечении claimTimeout.
+func (s *WebhookService) claim() ([]pendingDelivery, error) {
+	rows, err := s.db.Query(`
+        UPDATE webhook_deliveries d
+        SET status = $1, attempts = d.attempts + 1,
+            next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        FROM (
+            SELECT d.id FROM webhook_deliveries d
+            JOIN webhooks w ON w.id = d.webhook_id
+            WHERE d.status IN ($3, $1) AND d.next_attempt_at <= CURRENT_TIMESTAMP AND w.is_active
+            ORDER BY d.id
+            LIMIT $4
+            FOR UPDATE OF d SKIP LOCKED
+        ) c, webhooks w
+        WHERE d.id = c.id AND w.id = d.webhook_id
+        RETURNING d.id, d.event_type, d.event_id, w.url, w.secret, d.payload, d.attempts
+    `, StatusSending, claimTimeout.Seconds(), StatusPending, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения доставок: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.id, &d.event, &d.eventID, &d.url, &d.secret, &d.payload, &d.attempts); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования доставки: %w", err)
+		}
+		pending = append(pending, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения доставок: %w", err)
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].id < pending[j].id })
+	return pending, nil
+}
+
+// outcome - результат попытки доставки для записи в журнал
+type outcome struct {
+	status         string
+	responseStatus *int
+	responseBody   string
+	err            error
+	retryIn        time.Duration // пауза до следующей попытки, если доставка осталась в очереди
+}
+
+// deliveryOutcome определяет, что делать с доставкой после попытки.
+// d.attempts уже включает эту попытку.
+func deliveryOutcome(d pendingDelivery, code int, body string, sendErr error) outcome {
+	o := outcome{responseBody: body, err: sendErr}
+	if code != 0 {
+		o.responseStatus = &code
+	}
+
+	switch {
+	case sendErr == nil:
+		o.status = StatusDelivered
+	case d.attempts >= maxAttempts:
+		o.status = StatusFailed
+	default:
+		o.status = StatusPending
+		o.retryIn = backoff(d.attempts)
+	}
+	return o
+}
+
+// record записывает результат доставки, закрепленной за этим отправителем
+func (s *WebhookService) record(id int64, o outcome) error {
+	var err error
+	if o.err == nil {
+		_, err = s.db.Exec(`
+            UPDATE webhook_deliveries
+            SET status = $1, response_status = $2, response_body = $3,
+                last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+            WHERE id = $4 AND status = $5
+        `, o.status, o.responseStatus, o.responseBody, id, StatusSending)
+	} else {
+		_, err = s.db.Exec(`
+            UPDATE webhook_deliveries
+            SET status = $1, response_status = $2, response_body = $3, last_error = $4,
+                next_attempt_at = CURRENT_TIMESTAMP + $5 * INTERVAL '1 second'
+            WHERE id = $6 AND status = $7
+        `, o.status, o.responseStatus, o.responseBody, o.err.Error(), o.retryIn.Seconds(), id, StatusSending)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления доставки %d: %w", id, err)
+	}
+	return nil
+}
+
+// backoff - пауза перед следующей попыткой: 30 секунд, минута, 2 минуты...
+// не больше maxBackoff
+func backoff(attempts int) time.Duration {
+	d := 30 * time.Second << (attempts - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// deliver отправляет событие и возвращает код и начало тела ответа
+func (s *WebhookService) deliver(d pendingDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, "", fmt.Errorf("ошибка формирования запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "document-approval-webhooks/1")
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Event-ID", d.eventID)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(d.secret, timestamp, d.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	text := strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, text, fmt.Errorf("подписчик ответил %s", resp.Status)
+	}
+	return resp.StatusCode, text, nil
+}
+
+// Sign возвращает подпись тела события в hex. Подписчик считает ее так же
+// и сравнивает с заголовком X-Webhook-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"document-approval/pkg/sqltest"
+)
+
+var claimColumns = []string{"id", "event_type", "event_id", "url", "secret", "payload", "attempts"}
+
+func TestSign(t *testing.T) {
+	// Подпись посчитана независимо:
+	// HMAC-SHA256("whsec", "1700000000" + "." + тело) в hex
+	got := Sign("whsec", "1700000000", []byte(`{"event":"document.created"}`))
+	want := "b7015d1f62105817c25b31cd534270b8f1f9bdc8fbbfedb6ef4d764cd714c55b"
+	if got != want {
+		t.Fatalf("подпись %s, ожидалась %s", got, want)
+	}
+
+	if Sign("whsec", "1700000001", []byte(`{"event":"document.created"}`)) == want {
+		t.Error("подпись не зависит от timestamp")
+	}
+	if Sign("other", "1700000000", []byte(`{"event":"document.created"}`)) == want {
+		t.Error("подпись не зависит от секрета")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{11, 512 * time.Minute},
+		{12, maxBackoff},
+		{64, maxBackoff},
+		{1000, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, ожидалось %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryOutcome(t *testing.T) {
+	sendErr := errors.New("подписчик ответил 500")
+
+	tests := []struct {
+		name     string
+		attempts int
+		code     int
+		err      error
+		want     string
+		retryIn  time.Duration
+	}{
+		{name: "доставлено", attempts: 1, code: 200, want: StatusDelivered},
+		{name: "первая неудача", attempts: 1, code: 500, err: sendErr, want: StatusPending, retryIn: 30 * time.Second},
+		{name: "подписчик недоступен", attempts: 3, err: sendErr, want: StatusPending, retryIn: 2 * time.Minute},
+		{name: "последняя попытка", attempts: maxAttempts, code: 500, err: sendErr, want: StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := deliveryOutcome(pendingDelivery{id: 1, attempts: tt.attempts}, tt.code, "", tt.err)
+			if o.status != tt.want || o.retryIn != tt.retryIn {
+				t.Errorf("статус %s через %v, ожидался %s через %v", o.status, o.retryIn, tt.want, tt.retryIn)
+			}
+			if (tt.code == 0) != (o.responseStatus == nil) {
+				t.Errorf("код ответа %v при ответе %d", o.responseStatus, tt.code)
+			}
+		})
+	}
+}
+
+// subscriber - подписчик, который записывает полученные запросы и отвечает
+// заданным кодом
+type subscriber struct {
+	*httptest.Server
+	status int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func startSubscriber(t *testing.T, status int) *subscriber {
+	t.Helper()
+	s := &subscriber{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+		w.WriteHeader(s.status)
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *subscriber) received() ([]*http.Request, [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.bodies
+}
+
+func expectClaim(mock *sqltest.Mock, rows ...[]any) {
+	mock.ExpectQuery("UPDATE webhook_deliveries d SET status = $1, attempts = d.attempts + 1").
+		WithArgs(StatusSending, claimTimeout.Seconds(), StatusPending, batchSize).
+		WillReturnRows(claimColumns, rows...)
+}
+
+func TestRedeliver(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewWebhookService(db)
+	sub := startSubscriber(t, http.StatusOK)
+	payload := `{"event":"approval.completed","data":{"process_id":7}}`
+
+	mock.ExpectQuery("SELECT w.is_active FROM webhook_deliveries d").WithArgs(int64(41)).
+		WillReturnRows([]string{"is_active"}, []any{true})
+	mock.ExpectQuery("INSERT INTO webhook_deliveries (webhook_id, event_type, event_id, payload, redelivery_of)").
+		WithArgs(int64(41)).WillReturnRows([]string{"id"}, []any{int64(42)})
+
+	id, err := s.Redeliver(41)
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if id != 42 {
+		t.Fatalf("новая доставка %d, ожидалась 42", id)
+	}
+
+	// Новая доставка уходит подписчику при следующем проходе
+	expectClaim(mock, []any{int64(42), "approval.completed", "evt-1", sub.URL, "whsec", []byte(payload), 1})
+	mock.ExpectExec("SET status = $1, response_status = $2, response_body = $3, last_error = NULL").
+		WithArgs(StatusDelivered, 200, "ok", int64(42), StatusSending)
+
+	if err := s.DispatchPending(); err != nil {
+		t.Fatalf("DispatchPending: %v", err)
+	}
+
+	requests, bodies := sub.received()
+	if len(requests) != 1 {
+		t.Fatalf("подписчик получил %d запросов", len(requests))
+	}
+	r := requests[0]
+	if string(bodies[0]) != payload {
+		t.Errorf("тело %s", bodies[0])
+	}
+	if r.Header.Get("X-Webhook-Delivery") != "42" || r.Header.Get("X-Webhook-Event-ID") != "evt-1" {
+		t.Errorf("заголовки доставки %v", r.Header)
+	}
+	wantSig := "sha256=" + Sign("whsec", r.Header.Get("X-Webhook-Timestamp"), []byte(payload))
+	if got := r.Header.Get("X-Webhook-Signature"); got != wantSig {
+		t.Errorf("подпись %s, ожидалась %s", got, wantSig)
+	}
+}
+
+func TestRedeliverInactive(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewWebhookService(db)
+
+	mock.ExpectQuery("SELECT w.is_active FROM webhook_deliveries d").WithArgs(int64(41)).
+		WillReturnRows([]string{"is_active"}, []any{false})
+
+	if _, err := s.Redeliver(41); !errors.Is(err, ErrInactive) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, ErrInactive)
+	}
+}
+
+func TestDispatchFailsAfterMaxAttempts(t *testing.T) {
+	db, mock := sqltest.Open(t)
+	s := NewWebhookService(db)
+	sub := startSubscriber(t, http.StatusInternalServerError)
+
+	expectClaim(mock,
+		[]any{int64(1), "document.created", "evt-1", sub.URL, "whsec", []byte(`{}`), 2},
+		[]any{int64(2), "document.created", "evt-2", sub.URL, "whsec", []byte(`{}`), maxAttempts},
+	)
+	// Ошибка записи первой доставки не мешает записать вторую
+	mock.ExpectExec("last_error = $4, next_attempt_at").
+		WithArgs(StatusPending, 500, "ok", sqltest.Any, backoff(2).Seconds(), int64(1), StatusSending).
+		WillReturnError(errors.New("соединение потеряно"))
+	mock.ExpectExec("last_error = $4, next_attempt_at").
+		WithArgs(StatusFailed, 500, "ok", sqltest.Any, float64(0), int64(2), StatusSending)
+
+	if err := s.DispatchPending(); err != nil {
+		t.Fatalf("DispatchPending: %v", err)
+	}
+
+	if requests, _ := sub.received(); len(requests) != 2 {
+		t.Fatalf("подписчик получил %d запросов, ожидалось 2", len(requests))
+	}
+}